@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+const rolesNs = "roles"
+
+// Roles are ordered: each role is entitled to everything the roles
+// below it are entitled to.
+type Role int
+
+const (
+	User Role = iota
+	Trusted
+	Admin
+	Owner
+)
+
+var roleNames = []string{"user", "trusted", "admin", "owner"}
+
+func (r Role) String() string {
+	if r < User || r > Owner {
+		return "unknown"
+	}
+	return roleNames[r]
+}
+
+func ParseRole(s string) (Role, bool) {
+	s = strings.ToLower(s)
+	for i, name := range roleNames {
+		if s == name {
+			return Role(i), true
+		}
+	}
+	return User, false
+}
+
+// acl persists granted roles in a conf namespace, keyed on lowercased
+// masks that are matched against the caller's Identity. Anyone without
+// a matching entry has the User role.
+type acl struct {
+	ns conf.Namespace
+}
+
+func (a *acl) Role(id *Identity) Role {
+	if a == nil || id == nil {
+		return User
+	}
+	role := User
+	for _, e := range a.ns.All() {
+		s, _ := e.Value.(string)
+		if r, ok := ParseRole(s); ok && r > role && id.Matches(e.Key) {
+			role = r
+		}
+	}
+	return role
+}
+
+// Granted returns the role granted to exactly mask.
+func (a *acl) Granted(mask string) Role {
+	if a == nil {
+		return User
+	}
+	r, _ := ParseRole(a.ns.String(strings.ToLower(mask)))
+	return r
+}
+
+func (a *acl) Grant(mask string, r Role) {
+	if r == User {
+		a.Revoke(mask)
+		return
+	}
+	a.ns.String(strings.ToLower(mask), r.String())
+}
+
+func (a *acl) Revoke(mask string) {
+	a.ns.Delete(strings.ToLower(mask))
+}
+
+// Grants returns "mask: role" strings for every non-default grant.
+func (a *acl) Grants() []string {
+	if a == nil {
+		return nil
+	}
+	grants := []string{}
+	for _, e := range a.ns.All() {
+		if s, ok := e.Value.(string); ok {
+			grants = append(grants, e.Key+": "+s)
+		}
+	}
+	sort.Strings(grants)
+	return grants
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Role
+		ok   bool
+	}{
+		{"user", User, true},
+		{"Trusted", Trusted, true},
+		{"ADMIN", Admin, true},
+		{"owner", Owner, true},
+		{"", User, false},
+		{"wizard", User, false},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseRole(test.in)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParseRole(%q) = %s, %t; want %s, %t",
+				test.in, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestACL(t *testing.T) {
+	a := &acl{ns: conf.InMem(rolesNs)}
+	someone := &Identity{Nick: "someone", Ident: "some", Host: "one.example.com",
+		Account: "SomeOne", Known: true}
+
+	if r := a.Role(someone); r != User {
+		t.Errorf("Role of ungranted identity = %s, want user", r)
+	}
+	a.Grant("$a:someone", Admin)
+	if r := a.Role(someone); r != Admin {
+		t.Errorf("Role after grant = %s, want admin", r)
+	}
+	if r := a.Granted("$A:SOMEONE"); r != Admin {
+		t.Errorf("Granted is case sensitive, got %s, want admin", r)
+	}
+	if g := a.Grants(); len(g) != 1 || g[0] != "$a:someone: admin" {
+		t.Errorf("Grants() = %q, want [\"$a:someone: admin\"]", g)
+	}
+	// The highest matching role wins.
+	a.Grant("*!*@*.example.com", Owner)
+	if r := a.Role(someone); r != Owner {
+		t.Errorf("Role with two matching grants = %s, want owner", r)
+	}
+	a.Revoke("*!*@*.example.com")
+	a.Revoke("$a:someone")
+	if r := a.Role(someone); r != User {
+		t.Errorf("Role after revoke = %s, want user", r)
+	}
+	a.Grant("other", Trusted)
+	a.Grant("other", User)
+	if g := a.Grants(); len(g) != 0 {
+		t.Errorf("Granting user didn't remove entry, Grants() = %q", g)
+	}
+
+	// Someone who has taken the nick but isn't logged in gets nothing.
+	a.Grant("someone", Admin)
+	imposter := &Identity{Nick: "someone", Ident: "evil", Host: "evil.org", Known: true}
+	if r := a.Role(imposter); r != User {
+		t.Errorf("Role of imposter = %s, want user", r)
+	}
+
+	var nilACL *acl
+	if r := nilACL.Role(someone); r != User {
+		t.Errorf("Role from nil acl = %s, want user", r)
+	}
+}
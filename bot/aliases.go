@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+const (
+	aliasesNs = "aliases"
+	// Steps in a macro are separated by this.
+	aliasSep = ";"
+	// Replaced by whatever followed the alias in each step of a macro.
+	aliasRest     = "$*"
+	maxAliasSteps = 5
+)
+
+// An alias is another name for a command, defined at runtime. Aliases with
+// more than one step are macros, which run each of their commands in turn.
+// Whatever follows the alias replaces $* in each step, or is added to the
+// end of the last step if none of them contain $*.
+type alias struct {
+	cs    *commandSet
+	name  string
+	steps []string
+}
+
+func newAlias(name, expansion string) *alias {
+	a := &alias{name: name}
+	for _, s := range strings.Split(expansion, aliasSep) {
+		if s = strings.Join(strings.Fields(s), " "); s != "" {
+			a.steps = append(a.steps, s)
+		}
+	}
+	return a
+}
+
+func (a *alias) String() string {
+	return strings.Join(a.steps, aliasSep+" ")
+}
+
+// expand returns the commands to run when the alias is followed by rest.
+func (a *alias) expand(rest string) []string {
+	steps := make([]string, len(a.steps))
+	replaced := false
+	for i, s := range a.steps {
+		if strings.Contains(s, aliasRest) {
+			s, replaced = strings.ReplaceAll(s, aliasRest, rest), true
+		}
+		steps[i] = strings.Join(strings.Fields(s), " ")
+	}
+	if !replaced && rest != "" {
+		steps[len(steps)-1] += " " + rest
+	}
+	return steps
+}
+
+// Run runs each step of the alias as if it had been said to the bot.
+// Steps must be commands, not other aliases.
+func (a *alias) Run(ctx *Context) {
+	for _, step := range a.expand(ctx.Text()) {
+		r, ln := a.cs.matchCommand(step)
+		if r == nil {
+			ctx.ReplyN("'%s' isn't a command, so I stopped running '%s'.",
+				step, a.name)
+			return
+		}
+		sctx := &Context{Line: ctx.Line.Copy(), Addressed: true,
+			t: ctx.t, rws: ctx.rws}
+		sctx.Args[1] = step
+		a.cs.run(sctx, r, ln)
+	}
+}
+
+func (a *alias) Help() string {
+	h := fmt.Sprintf("'%s' is an alias for '%s'.", a.name, a)
+	if len(a.steps) == 1 {
+		if r, _ := a.cs.matchCommand(a.steps[0]); r != nil {
+			h += " " + r.Help()
+		}
+	}
+	return h
+}
+
+// Each step of an alias checks the caller's role when it is run.
+func (a *alias) Requires() Role {
+	return User
+}
+
+// aliasSet persists aliases in a conf namespace, keyed on lowercased
+// alias names with the alias's steps as the value.
+type aliasSet struct {
+	ns conf.Namespace
+}
+
+// match returns the alias with the longest name that txt starts with.
+func (as *aliasSet) match(txt string) (final *alias, namelen int) {
+	if as == nil {
+		return nil, 0
+	}
+	lowertxt := strings.ToLower(txt)
+	for _, e := range as.ns.All() {
+		if !strings.HasPrefix(lowertxt, e.Key) || !endsWord(txt, len(e.Key)) {
+			continue
+		}
+		if s, ok := e.Value.(string); ok && len(e.Key) > namelen {
+			final, namelen = newAlias(e.Key, s), len(e.Key)
+		}
+	}
+	return
+}
+
+func (as *aliasSet) Set(a *alias) {
+	as.ns.String(a.name, a.String())
+}
+
+func (as *aliasSet) Delete(name string) bool {
+	if as.ns.String(name) == "" {
+		return false
+	}
+	as.ns.Delete(name)
+	return true
+}
+
+// Names returns the names of all the aliases, sorted.
+func (as *aliasSet) Names() []string {
+	if as == nil {
+		return nil
+	}
+	names := []string{}
+	for _, e := range as.ns.All() {
+		names = append(names, e.Key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aliasName lowercases and compresses the spaces in an alias name, so
+// that it can be compared with the text of a line.
+func aliasName(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func defineAlias(ctx *Context) {
+	name, expansion, ok := strings.Cut(ctx.Text(), "=")
+	name = aliasName(name)
+	if !ok || name == "" {
+		ctx.ReplyN("Usage: alias <name> = <command>[; <command>...]")
+		return
+	}
+	if r, ln := bot.commands.match(name); r != nil && ln == len(name) {
+		if _, ok := r.(*alias); !ok {
+			ctx.ReplyN("'%s' is already a command.", name)
+			return
+		}
+	}
+	a := newAlias(name, expansion)
+	if len(a.steps) == 0 || len(a.steps) > maxAliasSteps {
+		ctx.ReplyN("Aliases need between 1 and %d commands.", maxAliasSteps)
+		return
+	}
+	for _, step := range a.steps {
+		if r, _ := bot.commands.match(step); r == nil {
+			ctx.ReplyN("'%s' isn't a command.", step)
+			return
+		} else if _, ok := r.(*alias); ok {
+			ctx.ReplyN("'%s' is an alias, and aliases can't use aliases.", step)
+			return
+		}
+	}
+	bot.aliases.Set(a)
+	ctx.ReplyN("'%s' is now an alias for '%s'.", a.name, a)
+}
+
+func removeAlias(ctx *Context) {
+	name := aliasName(ctx.Arg("name"))
+	if !bot.aliases.Delete(name) {
+		ctx.ReplyN("'%s' isn't an alias.", name)
+		return
+	}
+	ctx.ReplyN("'%s' is no longer an alias.", name)
+}
+
+func listAliases(ctx *Context) {
+	names := bot.aliases.Names()
+	if len(names) == 0 {
+		ctx.ReplyN("There aren't any aliases.")
+		return
+	}
+	ctx.ReplyN("Aliases: %s. Use 'help <alias>' to see what one does.",
+		strings.Join(names, ", "))
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestAliasMatch(t *testing.T) {
+	cs := newCommandSet()
+	cs.Add(&command{help: "quote help"}, "quote")
+	cs.Add(&command{help: "quote add help"}, "quote add")
+	as := &aliasSet{ns: conf.InMem(aliasesNs)}
+	as.Set(newAlias("q", "quote"))
+	as.Set(newAlias("qa", "quote add"))
+	as.Set(newAlias("quote a", "say here nope"))
+
+	tests := []struct {
+		input, want string
+		ln          int
+	}{
+		// Before aliases are set, only commands match.
+		{"q 1", "", 0},
+		{"quote 1", "quote help", 5},
+	}
+	for _, test := range tests {
+		r, ln := cs.match(test.input)
+		if (r == nil) != (test.want == "") || (r != nil && r.Help() != test.want) || ln != test.ln {
+			t.Errorf("match(%q) = %v, %d before setAliases", test.input, r, ln)
+		}
+	}
+
+	cs.setAliases(as)
+	tests = []struct {
+		input, want string
+		ln          int
+	}{
+		{"q 1", "'q' is an alias for 'quote'. quote help", 1},
+		{"Q", "'q' is an alias for 'quote'. quote help", 1},
+		{"qa hello", "'qa' is an alias for 'quote add'. quote add help", 2},
+		{"quote 1", "quote help", 5},
+		// The longest of a command or an alias wins.
+		{"quote a thing", "'quote a' is an alias for 'say here nope'.", 7},
+		{"quote add thing", "quote add help", 9},
+		{"quiet", "", 0},
+	}
+	for _, test := range tests {
+		r, ln := cs.match(test.input)
+		if test.want == "" {
+			if r != nil {
+				t.Errorf("match(%q) = %q, want no match", test.input, r.Help())
+			}
+			continue
+		}
+		if r == nil || r.Help() != test.want || ln != test.ln {
+			t.Errorf("match(%q) = %v, %d, want %q, %d",
+				test.input, r, ln, test.want, test.ln)
+		}
+	}
+
+	if poss := cs.possible("qa"); !reflect.DeepEqual(poss, []string{"qa"}) {
+		t.Errorf("possible(\"qa\") = %q, want the alias.", poss)
+	}
+	if !as.Delete("q") || as.Delete("q") {
+		t.Errorf("Delete(\"q\") should only succeed once.")
+	}
+	if got, want := as.Names(), []string{"qa", "quote a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+	var nilAliases *aliasSet
+	if a, _ := nilAliases.match("q"); a != nil || nilAliases.Names() != nil {
+		t.Errorf("nil aliasSet has aliases.")
+	}
+}
+
+func TestAliasExpand(t *testing.T) {
+	tests := []struct {
+		expansion, rest string
+		want            []string
+	}{
+		{"quote", "", []string{"quote"}},
+		{"quote", "42", []string{"quote 42"}},
+		{"  quote   add ", "hi  there", []string{"quote add hi  there"}},
+		{"say here hi; act here waves", "", []string{"say here hi", "act here waves"}},
+		{"say here hi; act here waves", "at you", []string{"say here hi", "act here waves at you"}},
+		{"say here hi $*;; act here waves at $*", "bob", []string{"say here hi bob", "act here waves at bob"}},
+		{"say here $* and $*", "", []string{"say here and"}},
+	}
+	for _, test := range tests {
+		a := newAlias("x", test.expansion)
+		if got := a.expand(test.rest); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q.expand(%q) = %q, want %q",
+				test.expansion, test.rest, got, test.want)
+		}
+	}
+	if got, want := newAlias("x", "a ;b; ; c").String(), "a; b; c"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
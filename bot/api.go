@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+// Where CI jobs, scripts and webhooks can POST messages for the bot to say.
+const apiSayPath = "/api/say"
+
+const (
+	// The most a request to the API can make the bot say.
+	maxAPILines = 5
+	maxAPIBody  = 1 << 20
+)
+
+var apiRequests = metrics.NewCounter("sp0rkle_api_requests_total",
+	"Requests to the HTTP API, by token and response code.", "token", "code")
+
+// APIConfig configures the HTTP API that lets other programs make the bot
+// say things. Requests to POST /api/say must be authenticated with one of
+// the tokens, either as "Authorization: Bearer <token>" or by signing the
+// body with it like GitHub does, and can only post to that token's
+// channels. The message is either given as "text", or made by executing
+// one of the templates with a JSON body. The request can give these, and
+// the "channel" and "network" to say it in, as query parameters, form
+// values, or in a JSON body if there's no template.
+type APIConfig struct {
+	Tokens []*APIToken `yaml:"tokens"`
+	// text/templates that turn JSON payloads into messages, keyed on
+	// name. If a GitHub webhook asks for the template "gh", "gh.<event>"
+	// is used in preference, e.g. "gh.issues". Templates that execute to
+	// nothing don't say anything.
+	Templates map[string]string `yaml:"templates"`
+
+	templates map[string]*template.Template
+}
+
+type APIToken struct {
+	// Name identifies the token in logs and metrics.
+	Name string `yaml:"name"`
+	// Token is a secret, see GetSecret.
+	Token string `yaml:"token"`
+	// The channels the token can post to, or "*" for any.
+	Channels []string `yaml:"channels"`
+	// If set, the token can only post to channels on this network.
+	Network string `yaml:"network"`
+}
+
+func (ac *APIConfig) validate() error {
+	names := map[string]bool{}
+	for i, t := range ac.Tokens {
+		switch {
+		case t.Name == "" || t.Token == "":
+			return fmt.Errorf("api token %d needs a name and token", i)
+		case len(t.Channels) == 0:
+			return fmt.Errorf("api token %q has no channels", t.Name)
+		case names[t.Name]:
+			return fmt.Errorf("api token name %q used more than once", t.Name)
+		}
+		names[t.Name] = true
+	}
+	ac.templates = make(map[string]*template.Template, len(ac.Templates))
+	for name, text := range ac.Templates {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("api template: %v", err)
+		}
+		ac.templates[name] = tmpl
+	}
+	return nil
+}
+
+// token returns the token req is authenticated with, or nil.
+func (ac *APIConfig) token(req *http.Request, body []byte) *APIToken {
+	bearer, hasBearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	sig, hasSig := strings.CutPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=")
+	for _, t := range ac.Tokens {
+		secret := GetSecret(t.Token)
+		if secret == "" {
+			continue
+		}
+		if hasBearer && subtle.ConstantTimeCompare([]byte(bearer), []byte(secret)) == 1 {
+			return t
+		}
+		if hasSig {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			if want, err := hex.DecodeString(sig); err == nil && hmac.Equal(want, mac.Sum(nil)) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// allows returns true if t can post to ch on network, or on any network
+// if that isn't given.
+func (t *APIToken) allows(network, ch string) bool {
+	if t.Network != "" && network != "" && !strings.EqualFold(t.Network, network) {
+		return false
+	}
+	for _, c := range t.Channels {
+		if c == "*" || c == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// apiMessage is what can be POSTed as JSON when there's no template.
+type apiMessage struct {
+	Channel, Network, Text string
+}
+
+// apiError is an error with an HTTP status code for the response.
+type apiError struct {
+	code int
+	msg  string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+func apiErrorf(code int, f string, args ...any) error {
+	return &apiError{code, fmt.Sprintf(f, args...)}
+}
+
+func apiSayHTTP(rw http.ResponseWriter, req *http.Request) {
+	name, err := apiSay(req)
+	code := http.StatusOK
+	if err != nil {
+		code = http.StatusInternalServerError
+		if ae, ok := err.(*apiError); ok {
+			code = ae.code
+		}
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	apiRequests.Inc(name, strconv.Itoa(code))
+	if err != nil {
+		logging.Warn("API request from %s (token %s) failed: %v", req.RemoteAddr, name, err)
+		http.Error(rw, err.Error(), code)
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
+
+// apiSay handles a request to say something, returning the name of the
+// token it was authenticated with, if any.
+func apiSay(req *http.Request) (string, error) {
+	if req.Method != "POST" {
+		return "", apiErrorf(http.StatusMethodNotAllowed, "use POST")
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, req.Body, maxAPIBody))
+	if err != nil {
+		return "", apiErrorf(http.StatusRequestEntityTooLarge, "couldn't read body: %v", err)
+	}
+	ac := currentConfig().API
+	tok := ac.token(req, body)
+	if tok == nil {
+		return "", apiErrorf(http.StatusUnauthorized, "bad or missing token")
+	}
+	msg, err := apiRequest(&ac, req, body)
+	if err != nil {
+		return tok.Name, err
+	}
+	if msg.Channel == "" {
+		return tok.Name, apiErrorf(http.StatusBadRequest, "no channel")
+	}
+	if msg.Network == "" {
+		msg.Network = tok.Network
+	}
+	ch := strings.ToLower(msg.Channel)
+	if !tok.allows(msg.Network, ch) {
+		return tok.Name, apiErrorf(http.StatusForbidden, "token %s can't post to %s", tok.Name, ch)
+	}
+	ctx := apiContext(msg.Network, ch)
+	if ctx == nil {
+		return tok.Name, apiErrorf(http.StatusServiceUnavailable, "not in %s", ch)
+	}
+	lines := apiLines(msg.Text)
+	if len(lines) > maxAPILines {
+		return tok.Name, apiErrorf(http.StatusBadRequest, "can't say more than %d lines", maxAPILines)
+	}
+	for _, line := range lines {
+		logging.Info("API: %s said %q in %s on %s.", tok.Name, line, ch, ctx.Network())
+		ctx.Privmsg(ch, line)
+	}
+	return tok.Name, nil
+}
+
+// apiRequest works out what req wants the bot to say, and where.
+func apiRequest(ac *APIConfig, req *http.Request, body []byte) (*apiMessage, error) {
+	// Form values come from the query, and the body if it's a form.
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err := req.ParseForm(); err != nil {
+		return nil, apiErrorf(http.StatusBadRequest, "bad form: %v", err)
+	}
+	msg := &apiMessage{
+		Channel: req.Form.Get("channel"),
+		Network: req.Form.Get("network"),
+		Text:    req.Form.Get("text"),
+	}
+	ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if name := req.Form.Get("template"); name != "" {
+		tmpl := ac.templates[name+"."+req.Header.Get("X-GitHub-Event")]
+		if tmpl == nil {
+			tmpl = ac.templates[name]
+		}
+		if tmpl == nil {
+			return nil, apiErrorf(http.StatusBadRequest, "no template %q", name)
+		}
+		payload := body
+		if ct == "application/x-www-form-urlencoded" {
+			// GitHub sends JSON like this unless told otherwise.
+			payload = []byte(req.PostForm.Get("payload"))
+		}
+		var data any
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return nil, apiErrorf(http.StatusBadRequest, "bad JSON: %v", err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, apiErrorf(http.StatusBadRequest, "template %s: %v", tmpl.Name(), err)
+		}
+		msg.Text = out.String()
+		return msg, nil
+	}
+	switch ct {
+	case "application/json":
+		var m apiMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			return nil, apiErrorf(http.StatusBadRequest, "bad JSON: %v", err)
+		}
+		if msg.Channel == "" {
+			msg.Channel = m.Channel
+		}
+		if msg.Network == "" {
+			msg.Network = m.Network
+		}
+		msg.Text = m.Text
+	case "text/plain":
+		msg.Text = string(body)
+	}
+	if strings.TrimSpace(msg.Text) == "" {
+		return nil, apiErrorf(http.StatusBadRequest, "no text")
+	}
+	return msg, nil
+}
+
+// apiContext returns a Context for the first network, by name, that the bot
+// is in ch on. If network is set, only that network is considered.
+func apiContext(network, ch string) *Context {
+	if bot == nil {
+		return nil
+	}
+	ctxs := bot.scheduler.contexts()
+	sort.Slice(ctxs, func(i, j int) bool {
+		return ctxs[i].Network() < ctxs[j].Network()
+	})
+	for _, ctx := range ctxs {
+		if network != "" && !strings.EqualFold(ctx.Network(), network) {
+			continue
+		}
+		if ctx.IsOn(ch, ctx.Me()) {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// apiLines splits text into the lines to say, without blank lines or
+// control characters other than IRC formatting codes.
+func apiLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == '\n' || r == '\r'
+	}) {
+		line = strings.TrimSpace(strings.Map(func(r rune) rune {
+			switch {
+			case r == '\t':
+				return ' '
+			case r < ' ' && !strings.ContainsRune("\x02\x03\x0f\x16\x1d\x1e\x1f", r):
+				return -1
+			}
+			return r
+		}, line))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
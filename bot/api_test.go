@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAPILines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello", []string{"hello"}},
+		{"one\r\ntwo\n\n  three  \r", []string{"one", "two", "three"}},
+		{"\x02bold\x02\tand\x07 \x03" + "4red", []string{"\x02bold\x02 and \x034red"}},
+		// A raw IRC command can't be smuggled in after a carriage return.
+		{"hi\rQUIT :bye", []string{"hi", "QUIT :bye"}},
+		{" \n\x01\n", []string{}},
+	}
+	for _, test := range tests {
+		if got := apiLines(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("apiLines(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+networks: [{server: "a:1"}]
+api:
+  tokens:
+    - {name: ci, token: s3cret, channels: ["#Builds"]}
+    - {name: gh, token: hub, channels: ["*"], network: a}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+	ac := &cfg.API
+	body := []byte(`{"action": "opened"}`)
+	mac := hmac.New(sha256.New, []byte("hub"))
+	mac.Write(body)
+	tests := []struct {
+		header, value string
+		want          string
+	}{
+		{"Authorization", "Bearer s3cret", "ci"},
+		{"Authorization", "Bearer s3cre", ""},
+		{"Authorization", "s3cret", ""},
+		{"X-Hub-Signature-256", "sha256=" + hex.EncodeToString(mac.Sum(nil)), "gh"},
+		{"X-Hub-Signature-256", "sha256=" + strings.Repeat("0", 64), ""},
+		{"X-Hub-Signature-256", "sha256=nothex", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", apiSayPath, nil)
+		req.Header.Set(test.header, test.value)
+		got := ""
+		if tok := ac.token(req, body); tok != nil {
+			got = tok.Name
+		}
+		if got != test.want {
+			t.Errorf("%s: %s authenticated as %q, want %q", test.header, test.value, got, test.want)
+		}
+	}
+
+	ci, gh := ac.Tokens[0], ac.Tokens[1]
+	if !ci.allows("", "#builds") || !ci.allows("other", "#builds") || ci.allows("", "#other") {
+		t.Errorf("ci token should only allow #builds, on any network.")
+	}
+	if !gh.allows("", "#any") || !gh.allows("A", "#any") || gh.allows("b", "#any") {
+		t.Errorf("gh token should allow any channel, only on network a.")
+	}
+}
+
+func TestAPIConfigDiff(t *testing.T) {
+	old, err := parseConfig([]byte(`
+networks: [{server: "a:1"}]
+api:
+  tokens: [{name: ci, token: a, channels: ["#a"]}]
+  templates: {ci: "{{.text}}"}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig(old) returned error: %v", err)
+	}
+	neu, err := parseConfig([]byte(`
+networks: [{server: "a:1"}]
+api:
+  tokens: [{name: ci, token: a, channels: ["#A", "#b"]}]
+  templates: {ci: "{{.text}}"}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig(new) returned error: %v", err)
+	}
+	if got, want := old.diff(neu), []string{"api tokens changed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diff() = %q, want %q", got, want)
+	}
+}
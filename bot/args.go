@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// An Arg describes one of the arguments to a command, see Args.
+type Arg struct {
+	Name     string
+	optional bool
+	// If optional, the argument is parsed from this when it's missing.
+	def  string
+	rest bool
+	// parse converts a string into the argument's value.
+	parse func(ctx *Context, s string) (any, error)
+}
+
+func (a Arg) usage() string {
+	u := a.Name
+	if a.rest {
+		u += "..."
+	}
+	if a.optional {
+		return "[" + u + "]"
+	}
+	return "<" + u + ">"
+}
+
+// StringArg is a single word, or a "double quoted string".
+func StringArg(name string) Arg {
+	return Arg{Name: name, parse: func(_ *Context, s string) (any, error) {
+		return s, nil
+	}}
+}
+
+func IntArg(name string) Arg {
+	return Arg{Name: name, parse: func(_ *Context, s string) (any, error) {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' isn't a number.", s)
+		}
+		return i, nil
+	}}
+}
+
+// NickArg is a string that must be a valid nick.
+func NickArg(name string) Arg {
+	return Arg{Name: name, parse: func(_ *Context, s string) (any, error) {
+		if !isNick(s) {
+			return nil, fmt.Errorf("'%s' doesn't look like a nick.", s)
+		}
+		return s, nil
+	}}
+}
+
+// ChanArg is a channel name, or "here" for the channel the command was
+// sent to. Channels are lowercased.
+func ChanArg(name string) Arg {
+	return Arg{Name: name, parse: func(ctx *Context, s string) (any, error) {
+		if strings.ToLower(s) == "here" {
+			if s = ctx.Target(); !isChannel(s) {
+				return nil, fmt.Errorf("'here' only works in a channel.")
+			}
+		}
+		if !isChannel(s) {
+			return nil, fmt.Errorf("'%s' doesn't look like a channel.", s)
+		}
+		return strings.ToLower(s), nil
+	}}
+}
+
+// DurationArg is a time.Duration like "1h30m". It also allows days and
+// weeks, e.g. "2d" or "1w", but these can't be combined with other units.
+func DurationArg(name string) Arg {
+	return Arg{Name: name, parse: func(_ *Context, s string) (any, error) {
+		d, err := parseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' isn't a duration like 1h30m.", s)
+		}
+		return d, nil
+	}}
+}
+
+// RestArg is the rest of the command's text, verbatim. It must be last.
+func RestArg(name string) Arg {
+	a := StringArg(name)
+	a.rest = true
+	return a
+}
+
+// Optional makes an argument optional. Optional arguments must come after
+// any required ones. If a default is given, it is parsed as if it had been
+// supplied when the argument is missing.
+func Optional(a Arg, def ...string) Arg {
+	a.optional = true
+	if len(def) > 0 {
+		a.def = def[0]
+	}
+	return a
+}
+
+// Args gives a command an argument spec. Before the command is run, its
+// text is parsed according to the spec and the values made available
+// through Context.Arg and friends. If the text doesn't fit, the caller
+// is told how to use the command instead. The command's usage is also
+// added to its help, so help strings shouldn't repeat it.
+func Args(args ...Arg) CommandOpt {
+	return func(c *command) {
+		c.args = args
+	}
+}
+
+func argsUsage(args []Arg) string {
+	u := make([]string, len(args))
+	for i, a := range args {
+		u[i] = a.usage()
+	}
+	return strings.Join(u, " ")
+}
+
+// parseArgs parses text according to args, returning a map of argument
+// name to value, or an error describing why it couldn't.
+func parseArgs(ctx *Context, args []Arg, text string) (map[string]any, error) {
+	vals := map[string]any{}
+	for _, a := range args {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		var s string
+		switch {
+		case text == "" && a.optional && a.def == "":
+			continue
+		case text == "" && a.optional:
+			s = a.def
+		case text == "":
+			return nil, fmt.Errorf("Missing %s.", a.usage())
+		case a.rest:
+			s, text = text, ""
+		default:
+			var err error
+			if s, text, err = nextWord(text); err != nil {
+				return nil, err
+			}
+		}
+		v, err := a.parse(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		vals[a.Name] = v
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		return nil, fmt.Errorf("Didn't expect '%s'.", text)
+	}
+	return vals, nil
+}
+
+// nextWord splits the first word, or "double quoted string", from text.
+// Backslashes escape quotes and backslashes inside quoted strings.
+func nextWord(text string) (string, string, error) {
+	if text[0] != '"' {
+		if idx := strings.IndexFunc(text, unicode.IsSpace); idx != -1 {
+			return text[:idx], text[idx:], nil
+		}
+		return text, "", nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text):
+			i++
+			b.WriteByte(text[i])
+		case c == '"':
+			return b.String(), text[i+1:], nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("Unterminated quoted string.")
+}
+
+// isNick checks s against RFC 2812's definition of a nickname,
+// without the length limit.
+func isNick(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			strings.ContainsRune("[]\\`_^{|}", r):
+		case i > 0 && (r >= '0' && r <= '9' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if u, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * u, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestParseArgs(t *testing.T) {
+	public := &Context{Line: &client.Line{Cmd: client.PRIVMSG,
+		Nick: "someone", Args: []string{"#Chan", "text"}}}
+	private := &Context{Line: &client.Line{Cmd: client.PRIVMSG,
+		Nick: "someone", Args: []string{"sp0rkle", "text"}}}
+
+	tests := []struct {
+		name string
+		ctx  *Context
+		args []Arg
+		text string
+		want map[string]any
+		err  string
+	}{
+		{
+			name: "strings and rest",
+			args: []Arg{StringArg("a"), RestArg("b")},
+			text: "  one   two  three ",
+			want: map[string]any{"a": "one", "b": "two  three "},
+		},
+		{
+			name: "quoted strings",
+			args: []Arg{StringArg("a"), StringArg("b")},
+			text: `"one two" "\"three\" \\ four"`,
+			want: map[string]any{"a": "one two", "b": `"three" \ four`},
+		},
+		{
+			name: "unterminated quote",
+			args: []Arg{StringArg("a")},
+			text: `"one two`,
+			err:  "Unterminated",
+		},
+		{
+			name: "missing required",
+			args: []Arg{StringArg("a"), IntArg("b")},
+			text: "one",
+			err:  "Missing <b>",
+		},
+		{
+			name: "missing required rest",
+			args: []Arg{NickArg("a"), RestArg("b")},
+			text: "one",
+			err:  "Missing <b...>",
+		},
+		{
+			name: "too many",
+			args: []Arg{StringArg("a")},
+			text: "one two",
+			err:  "Didn't expect 'two'",
+		},
+		{
+			name: "empty text with no args",
+			args: []Arg{},
+			text: "",
+			want: map[string]any{},
+		},
+		{
+			name: "types",
+			args: []Arg{IntArg("i"), NickArg("n"), ChanArg("c"), DurationArg("d")},
+			text: "-42 Some_Nick #Foo 2d",
+			want: map[string]any{"i": -42, "n": "Some_Nick", "c": "#foo",
+				"d": 48 * time.Hour},
+		},
+		{
+			name: "bad int",
+			args: []Arg{IntArg("i")},
+			text: "lots",
+			err:  "isn't a number",
+		},
+		{
+			name: "bad nick",
+			args: []Arg{NickArg("n")},
+			text: "9lives",
+			err:  "doesn't look like a nick",
+		},
+		{
+			name: "bad channel",
+			args: []Arg{ChanArg("c")},
+			text: "foo",
+			err:  "doesn't look like a channel",
+		},
+		{
+			name: "bad duration",
+			args: []Arg{DurationArg("d")},
+			text: "ages",
+			err:  "isn't a duration",
+		},
+		{
+			name: "here",
+			ctx:  public,
+			args: []Arg{ChanArg("c")},
+			text: "here",
+			want: map[string]any{"c": "#chan"},
+		},
+		{
+			name: "here in private",
+			ctx:  private,
+			args: []Arg{ChanArg("c")},
+			text: "HERE",
+			err:  "only works in a channel",
+		},
+		{
+			name: "optional missing",
+			args: []Arg{StringArg("a"), Optional(IntArg("b")), Optional(RestArg("c"))},
+			text: "one",
+			want: map[string]any{"a": "one"},
+		},
+		{
+			name: "optional given",
+			args: []Arg{StringArg("a"), Optional(IntArg("b")), Optional(RestArg("c"))},
+			text: "one 2 three four",
+			want: map[string]any{"a": "one", "b": 2, "c": "three four"},
+		},
+		{
+			name: "optional default",
+			ctx:  public,
+			args: []Arg{StringArg("a"), Optional(ChanArg("c"), "here")},
+			text: "one",
+			want: map[string]any{"a": "one", "c": "#chan"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = public
+			}
+			got, err := parseArgs(ctx, test.args, test.text)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("parseArgs(%q) error = %v, want %q", test.text, err, test.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseArgs(%q) = %v, %v; want %v", test.text, got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestArgsUsage(t *testing.T) {
+	args := []Arg{StringArg("nick|mask"), ChanArg("#chan"),
+		Optional(IntArg("n")), Optional(RestArg("text"))}
+	if got, want := argsUsage(args), "<nick|mask> <#chan> [n] [text...]"; got != want {
+		t.Errorf("argsUsage() = %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -11,6 +12,9 @@ import (
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/collections/conf"
+	"github.com/fluffle/sp0rkle/collections/errlog"
+	"github.com/fluffle/sp0rkle/collections/jobs"
+	"github.com/fluffle/sp0rkle/util/metrics"
 )
 
 // This is here because I'm not sure where better to put it...
@@ -25,10 +29,26 @@ type botData struct {
 	ctx       context.Context
 	connected bool
 	servers   ServerSet
+	handlers  *handlerSet
 	rewriters RewriteSet
 	commands  CommandSet
-	pollers   PollerSet
+	scheduler *scheduler
+	events    *eventBus
 	filters   *FilterPipeline
+	ignores   *nickIgnoreFilter
+	acl       *acl
+	aliases   *aliasSet
+	triggers  *triggerSet
+	errors    *errlog.Collection
+	accounts  *accountTracker
+	drivers   map[string]*Driver
+	policy    *driverPolicy
+	pager     *pager
+
+	cfgLock sync.RWMutex
+	config  *Config
+
+	inflight inflight
 }
 
 var bot *botData
@@ -41,33 +61,152 @@ func Init(ctx context.Context) {
 		return
 	}
 
+	cfg := initialConfig()
 	bot = &botData{
 		ctx:       ctx,
-		servers:   newServerSet(),
+		config:    cfg,
+		servers:   newServerSet(cfg),
+		handlers:  newHandlerSet(),
 		commands:  newCommandSet(),
 		rewriters: newRewriteSet(),
-		pollers:   newPollerSet(),
+		scheduler: newScheduler(),
+		events:    newEventBus(),
 		filters:   &FilterPipeline{},
+		accounts:  newAccountTracker(),
+		drivers:   make(map[string]*Driver),
+		pager:     newPager(),
 	}
 
 
 	// This is a special handler that dispatches commands from the command set
 	bot.servers.HandleAll(client.PRIVMSG, bot.commands)
+	bot.handlers.add(client.PRIVMSG, bot.commands, false)
+	// It also serves the list of commands, see help.go.
+	http.Handle(helpPath, bot.commands)
+	// Panics in handlers are recorded, see errors.go.
+	http.HandleFunc(errorsPath, errorsHTTP)
+	// Monitoring can check on the bot, see metrics.go.
+	http.HandleFunc(metricsPath, metrics.ServeHTTP)
+	http.HandleFunc(healthPath, healthHTTP)
+	// Other programs can make the bot say things, see api.go.
+	http.HandleFunc(apiSayPath, apiSayHTTP)
+
+	// The scheduler handles these two to keep track of servers for jobs.
+	for _, ev := range []string{client.CONNECTED, client.DISCONNECTED} {
+		bot.servers.HandleAll(ev, bot.scheduler)
+		bot.handlers.add(ev, bot.scheduler, false)
+	}
 
-	// The poller set handles these two to start and stop registered pollers
-	bot.servers.HandleAll(client.CONNECTED, bot.pollers)
-	bot.servers.HandleAll(client.DISCONNECTED, bot.pollers)
+	// The account tracker resolves nicks to services accounts.
+	for _, ev := range []string{client.JOIN, "ACCOUNT", client.NICK,
+		client.QUIT, client.DISCONNECTED, "330", "318"} {
+		bot.servers.HandleAll(ev, bot.accounts)
+	}
 
 	// These three in handlers.go
-	Handle(connected, client.CONNECTED)
+	bot.servers.HandleAll(client.CONNECTED, client.HandlerFunc(connected))
 	Handle(rebuild, client.NOTICE)
 	Handle(shutdown, client.NOTICE)
 
-	// These two in commands.go
-	Command(ignore, "ignore", "ignore <nick>  -- "+
-		"make the bot ignore <nick> completely.")
-	Command(unignore, "unignore", "unignore <nick>  -- "+
-		"make the bot unignore <nick> again.")
+	// These in commands.go
+	Command(ignore, "ignore", "make the bot ignore <nick> or "+
+		"nick!user@host <mask> completely.",
+		Args(StringArg("nick|mask")), Requires(Admin))
+	Command(unignore, "unignore", "make the bot unignore <nick> or "+
+		"<mask> again.", Args(StringArg("nick|mask")), Requires(Admin))
+	Command(grant, "grant", "give the account <nick> is logged in to, "+
+		"$a:account or nick!user@host <mask> one of the roles user, "+
+		"trusted, admin or owner.",
+		Args(StringArg("nick|mask"), StringArg("role")), Requires(Admin))
+	Command(revoke, "revoke", "take away any role granted to <nick> "+
+		"or <mask>.", Args(StringArg("nick|mask")), Requires(Admin))
+	Command(roles, "roles", "roles  -- "+
+		"list the accounts and masks that have been granted roles.",
+		Requires(Admin))
+	// These are short words that often start other ones, e.g. "actually".
+	Command(joinChan, "join", "make the bot join <#chan>, now and on "+
+		"reconnect.", Args(ChanArg("#chan")), Requires(Admin), WholeWord())
+	Command(partChan, "part", "make the bot leave <#chan>, and stay "+
+		"out of it.", Args(ChanArg("#chan"), Optional(RestArg("message"))),
+		Requires(Admin), WholeWord())
+	Command(changeNick, "nick", "change the bot's nick.",
+		Args(NickArg("newnick")), Requires(Admin), WholeWord())
+	Command(say, "say", "make the bot say <text> in <#chan>.",
+		Args(ChanArg("#chan"), RestArg("text")), Examples("say here hello!"),
+		Requires(Admin), WholeWord())
+	Command(act, "act", "make the bot do <text> in <#chan>.",
+		Args(ChanArg("#chan"), RestArg("text")), Requires(Admin), WholeWord())
+	// Channel ops can use these in their channels, see commands.go.
+	Command(disableDriver, "driver disable", "stop <driver> doing "+
+		"anything in a channel, here by default.",
+		Args(StringArg("driver"), Optional(ChanArg("#chan"), "here")))
+	Command(enableDriver, "driver enable", "let <driver> work in a "+
+		"channel again, here by default.",
+		Args(StringArg("driver"), Optional(ChanArg("#chan"), "here")))
+	Command(reload, "reload", "reload  -- "+
+		"re-read the --config file and apply any changes.", Requires(Admin))
+	Command(listDrivers, "drivers", "list the bot's drivers and which "+
+		"are disabled in a channel, here by default.",
+		Args(Optional(ChanArg("#chan"), "here")))
+
+	// This one in pager.go
+	Command(more, "more", "more  -- show the next page of a long reply.")
+
+	// These in aliases.go
+	Command(defineAlias, "alias", "alias <name> = <command>[; <command>...]"+
+		"  -- make <name> run one or more commands. Whatever follows <name> "+
+		"replaces $* in the commands, or is added to the end of the last one.",
+		Examples("alias q = quote", "alias hi = say here hello $*; act here waves"),
+		Requires(Admin))
+	Command(removeAlias, "unalias", "forget the alias <name>.",
+		Args(RestArg("name")), Requires(Admin))
+	Command(listAliases, "aliases", "aliases  -- list the defined aliases.")
+
+	// These in triggers.go
+	Command(setTriggers, "trigger set", "make lines in a channel, here "+
+		"by default, that start with one of <chars> then a command be for "+
+		"the bot, as if they started with its nick. 'none' turns this off.",
+		Args(StringArg("chars"), Optional(ChanArg("#chan"), "here")),
+		Examples("trigger set !.", "trigger set none #serious"),
+		Requires(Admin))
+	Command(resetTriggers, "trigger reset", "go back to the config "+
+		"file's triggers for a channel, here by default.",
+		Args(Optional(ChanArg("#chan"), "here")), Requires(Admin))
+	Command(listTriggers, "triggers", "show the characters that start "+
+		"lines for the bot in a channel, here by default.",
+		Args(Optional(ChanArg("#chan"), "here")))
+
+	// These in errors.go
+	Command(listErrors, "errors list", "errors list  -- "+
+		"list the errors that have happened, most recent first.",
+		Requires(Admin))
+	Command(showError, "errors show", "show what went wrong for error <id>.",
+		Args(StringArg("id")), Requires(Admin))
+	Command(forgetError, "errors forget", "forget error <id>, e.g. "+
+		"once it has been fixed.", Args(StringArg("id")), Requires(Admin))
+
+	// These in scheduler.go
+	Command(listJobs, "jobs", "jobs  -- list the scheduled jobs, "+
+		"when they next run, and how their last run went.", Requires(Admin))
+	Command(addJob, "jobs add", "add a job that says <text> in <channel> "+
+		"on <schedule>, which is quoted if it has spaces.",
+		Args(StringArg("job"), ChanArg("channel"), StringArg("schedule"), RestArg("text")),
+		Examples(`jobs add standup #dev "0 9 * * mon-fri" Standup time!`),
+		Requires(Admin))
+	Command(delJob, "jobs del", "delete <job>, which an admin added.",
+		Args(StringArg("job")), Requires(Admin))
+	Command(enableJob, "jobs enable", "let <job> run on its schedule again.",
+		Args(StringArg("job")), Requires(Admin))
+	Command(disableJob, "jobs disable", "stop <job> running until it is "+
+		"enabled again.", Args(StringArg("job")), Requires(Admin))
+	Command(scheduleJob, "jobs schedule", "change when <job> runs, to "+
+		"an interval or a cron line, with an optional ~jitter. 'default' "+
+		"goes back to the job's own schedule.",
+		Args(StringArg("job"), RestArg("schedule")),
+		Examples("jobs schedule mc every 10m ~1m", "jobs schedule mc 0 9 * * mon-fri"),
+		Requires(Admin))
+	Command(runJob, "jobs run", "run <job> now.", Args(StringArg("job")),
+		Requires(Admin))
 }
 
 func Connect() chan bool {
@@ -80,7 +219,14 @@ func Connect() chan bool {
 		logging.Warn("Already connected to servers.")
 	}
 	bot.connected = true
-	bot.filters.Add(&nickIgnoreFilter{ns: conf.Ns(ignoreNs)})
+	bot.ignores = newNickIgnoreFilter(conf.Ns(ignoreNs))
+	bot.acl = &acl{ns: conf.Ns(rolesNs)}
+	bot.policy = &driverPolicy{ns: conf.Ns(driversNs)}
+	bot.aliases = &aliasSet{ns: conf.Ns(aliasesNs)}
+	bot.commands.setAliases(bot.aliases)
+	bot.triggers = &triggerSet{ns: conf.Ns(triggersNs)}
+	bot.errors = errlog.Init()
+	bot.scheduler.start(bot.ctx, jobs.Init())
 	return bot.servers.Connect()
 }
 
@@ -94,32 +240,55 @@ func Shutdown() {
 		logging.Warn("Not connected to servers.")
 	}
 	bot.connected = false
-	bot.filters = &FilterPipeline{}
+	bot.filters.Reset()
 	bot.servers.Shutdown(false)
 }
 
 func Handle(fn HandlerFunc, events ...string) {
 	for _, ev := range events {
 		bot.servers.HandleAll(ev, fn)
+		bot.handlers.add(ev, fn, false)
 	}
 }
 
 func HandleBG(fn HandlerFunc, events ...string) {
 	for _, ev := range events {
 		bot.servers.HandleAllBG(ev, fn)
+		bot.handlers.add(ev, fn, true)
+	}
+}
+
+func Command(fn HandlerFunc, prefix, help string, opts ...CommandOpt) {
+	c := &command{fn: fn, prefix: prefix, help: help}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.category == "" {
+		c.category = botCategory
 	}
+	bot.commands.Add(c, prefix)
 }
 
-func Command(fn HandlerFunc, prefix, help string) {
-	bot.commands.Add(&command{fn, help}, prefix)
+// Suggest adds fn to the things asked what an addressed line that
+// didn't match any command might have meant.
+func Suggest(fn SuggestFunc) {
+	bot.commands.Suggest(fn)
 }
 
 func Rewrite(fn RewriteFunc) {
 	bot.rewriters.Add(fn)
 }
 
-func Poll(p Poller) {
-	bot.pollers.Add(p)
+// Schedule runs fn as the job name, replacing any job with that name.
+// spec is an interval or cron line, see util/schedule, which admins can
+// change with the jobs commands in scheduler.go.
+func Schedule(name, spec string, fn JobFunc, opts ...JobOpt) error {
+	return bot.scheduler.Add(name, spec, fn, opts...)
+}
+
+// Unschedule stops the job name from running again.
+func Unschedule(name string) {
+	bot.scheduler.Remove(name)
 }
 
 func GetSecret(s string) string {
@@ -134,6 +303,8 @@ func GetSecret(s string) string {
 	return s
 }
 
+// Ctx returns the context passed to Init, which is cancelled when the
+// bot is shutting down.
 // TODO(fluffle): The long slog refactor of subsuming the bot context
 // into a context.Context Value and using context.Context everywhere.
 func Ctx() context.Context {
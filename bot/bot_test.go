@@ -0,0 +1,14 @@
+package bot
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fluffle/golog/logging"
+)
+
+func TestMain(m *testing.M) {
+	// Lots of things log, as they would in main().
+	logging.InitFromFlags()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,305 @@
+// Package bottest runs the bot against a fake IRC server and a temporary
+// database, so that drivers can be tested end to end. The bot is global,
+// so there can only be one in each test binary, started by TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(bottest.Run(m, Init))
+//	}
+//
+// Tests then get the bot with New, talk to it as other nicks, and check
+// what it says back:
+//
+//	b := bottest.New(t)
+//	b.Privmsg("alice", bottest.Chan, "sp0rkle: foo := bar")
+//	b.Expect(t, bottest.Chan, "alice: Woo, I now know 1 things about 'foo'.")
+//
+// The bot forgets everything between tests, but things it is still doing
+// when a test finishes can leak into the next, so tests should make sure
+// they've seen everything the bot says in response.
+package bottest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/db"
+	"github.com/fluffle/sp0rkle/db/dbtest"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+const (
+	// Nick is the bot's nick.
+	Nick = "sp0rkle"
+	// Chan is the channel the bot joins when it connects.
+	Chan = "#sp0rkle"
+	// Trigger addresses the bot at the start of a line in Chan.
+	Trigger = "!"
+	// APIToken can make the bot say things in Chan with the HTTP API.
+	APIToken = "t0ken"
+)
+
+// How long Expect waits for the bot to say something, and how long
+// ExpectNothing waits to be sure it won't.
+var (
+	Timeout = 5 * time.Second
+	Quiet   = 250 * time.Millisecond
+)
+
+// The bot's --config. Lines aren't rate limited, so tests don't wait.
+const config = `networks:
+  - name: test
+    server: %s
+    nick: ` + Nick + `
+    channels: ["` + Chan + `"]
+    pause: 100ms
+triggers: {"` + Chan + `": "` + Trigger + `"}
+rate_limits:
+  target: {every: 0s}
+  flood: {every: 0s}
+  suggest: {every: 0s}
+api:
+  tokens: [{name: test, token: ` + APIToken + `, channels: ["` + Chan + `"]}]
+  templates:
+    build: "Build {{.number}} {{.status}}."
+    gh: "{{.sender.login}} poked the repo."
+    gh.issues: "{{.sender.login}} {{.action}} issue #{{.issue.number}}."
+`
+
+// Bot is the bot, connected to a fake IRC server.
+type Bot struct {
+	srv    *server
+	dir    string
+	cancel context.CancelFunc
+	done   chan bool
+}
+
+// The bot started by Run, for New to return.
+var running *Bot
+
+// Run starts the bot with Start, runs the tests, then stops it. It returns
+// the exit code for TestMain to pass to os.Exit.
+func Run(m *testing.M, inits ...func()) int {
+	running = Start(inits...)
+	defer running.Stop()
+	return m.Run()
+}
+
+// New returns the bot started by Run. When the test finishes the bot's
+// database is emptied, and it reconnects to a fake server that has
+// forgotten everything too, so the next test, or the next run of this
+// one with -count, starts afresh.
+func New(t testing.TB) *Bot {
+	t.Helper()
+	if running == nil {
+		t.Fatal("The bot hasn't been started by bottest.Run.")
+	}
+	t.Cleanup(func() {
+		if err := running.reset(); err != nil {
+			t.Fatalf("Couldn't reset the bot: %v", err)
+		}
+	})
+	return running
+}
+
+// Start sets up logging, initialises the bot and its database, calls the
+// drivers' Init functions, and connects to the fake server. It returns
+// once the bot has joined Chan.
+func Start(inits ...func()) *Bot {
+	logging.InitFromFlags()
+	b, err := start(inits)
+	if err != nil {
+		logging.Fatal("Couldn't start the bot: %v", err)
+	}
+	return b
+}
+
+func start(inits []func()) (*Bot, error) {
+	dir, err := os.MkdirTemp("", "bottest")
+	if err != nil {
+		return nil, err
+	}
+	srv, err := newServer()
+	if err != nil {
+		return nil, err
+	}
+	b := &Bot{srv: srv, dir: dir}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(config, srv.addr())), 0600); err != nil {
+		return nil, err
+	}
+	if err := flag.Set("config", path); err != nil {
+		return nil, err
+	}
+	if err := datetime.SetTZ("UTC"); err != nil {
+		return nil, err
+	}
+
+	// This follows main().
+	var ctx context.Context
+	ctx, b.cancel = context.WithCancel(context.Background())
+	bot.Init(ctx)
+	if err := dbtest.Open(dir); err != nil {
+		return nil, err
+	}
+	for _, init := range inits {
+		init()
+	}
+	b.done = bot.Connect()
+	if err := b.joined(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// joined waits for the bot to join Chan, which it does once it connects.
+func (b *Bot) joined() error {
+	if line := b.Next(); line == nil || line.Cmd != client.JOIN || line.Args[0] != Chan {
+		return fmt.Errorf("expected the bot to join %s, got %v", Chan, line)
+	}
+	return nil
+}
+
+// reset empties the database while the bot is disconnected, so nothing
+// is loaded from it when the bot reconnects.
+func (b *Bot) reset() error {
+	if err := b.srv.disconnect(dbtest.Wipe); err != nil {
+		return err
+	}
+	return b.joined()
+}
+
+// Stop disconnects the bot and waits for it to finish what it's doing,
+// then throws away its database.
+func (b *Bot) Stop() {
+	// Shutdown waits for us to receive from the channel Connect returned.
+	go bot.Shutdown()
+	<-b.done
+	b.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	if !bot.Wait(ctx) {
+		logging.Warn("Timed out waiting for handlers to finish.")
+	}
+	db.Bolt.Close()
+	b.srv.close()
+	os.RemoveAll(b.dir)
+}
+
+func src(nick string) string {
+	return nick + "!" + nick + "@" + userHost
+}
+
+// Privmsg makes nick say text to target, which is a channel or Nick.
+func (b *Bot) Privmsg(nick, target, text string) {
+	b.srv.send(":%s PRIVMSG %s :%s", src(nick), target, text)
+}
+
+// Action makes nick do text in target, which is a channel or Nick.
+func (b *Bot) Action(nick, target, text string) {
+	b.srv.send(":%s PRIVMSG %s :\001ACTION %s\001", src(nick), target, text)
+}
+
+// Join makes nick join ch.
+func (b *Bot) Join(nick, ch string) {
+	b.srv.send(":%s JOIN %s", src(nick), ch)
+}
+
+// Part makes nick leave ch.
+func (b *Bot) Part(nick, ch string) {
+	b.srv.send(":%s PART %s", src(nick), ch)
+}
+
+// Nick changes old's nick to nick.
+func (b *Bot) Nick(old, nick string) {
+	b.srv.send(":%s NICK :%s", src(old), nick)
+}
+
+// Raw sends a raw line to the bot, for anything the methods above don't do.
+func (b *Bot) Raw(line string) {
+	b.srv.send("%s", line)
+}
+
+// IgnoreWhois stops the fake server answering when the bot asks who nick
+// is, so the bot never finds out.
+func (b *Bot) IgnoreWhois(nick string) {
+	b.srv.ignoreWhois(nick)
+}
+
+// ExpectWhois fails the test unless the bot asks who nick is within
+// Timeout. The fake server says they aren't logged in to an account, which
+// the bot knows before it handles anything sent after ExpectWhois returns.
+func (b *Bot) ExpectWhois(t testing.TB, nick string) {
+	t.Helper()
+	select {
+	case n := <-b.srv.whoised:
+		if !strings.EqualFold(n, nick) {
+			t.Errorf("Bot asked who %s is, want %s.", n, nick)
+		}
+	case <-time.After(Timeout):
+		t.Errorf("Bot didn't ask who %s is.", nick)
+	}
+}
+
+// Next returns the next line the bot sends, or nil if it doesn't send
+// anything within Timeout. The fake server takes care of registration,
+// NAMES, WHO and WHOIS, so lines to do with those aren't returned.
+// Nor are PINGs or QUITs, or the bot setting its own modes.
+func (b *Bot) Next() *client.Line {
+	return b.srv.next(Timeout)
+}
+
+// Expect fails the test unless the next line the bot sends is a PRIVMSG,
+// NOTICE or ACTION of text to target.
+func (b *Bot) Expect(t testing.TB, target, text string) {
+	t.Helper()
+	if line := b.expectMessage(t, target); line != nil && line.Text() != text {
+		t.Errorf("Bot said %q to %s, want %q.", line.Text(), target, text)
+	}
+}
+
+// ExpectMatch is like Expect, but the text must match the regexp re.
+func (b *Bot) ExpectMatch(t testing.TB, target, re string) {
+	t.Helper()
+	line := b.expectMessage(t, target)
+	if line != nil && !regexp.MustCompile(re).MatchString(line.Text()) {
+		t.Errorf("Bot said %q to %s, want a match for %q.",
+			line.Text(), target, re)
+	}
+}
+
+func (b *Bot) expectMessage(t testing.TB, target string) *client.Line {
+	t.Helper()
+	line := b.Next()
+	switch {
+	case line == nil:
+		t.Errorf("Bot didn't say anything to %s.", target)
+		return nil
+	case line.Cmd != client.PRIVMSG && line.Cmd != client.NOTICE &&
+		line.Cmd != client.ACTION:
+		t.Errorf("Bot sent %q, want a message to %s.", line.Raw, target)
+		return nil
+	case line.Args[0] != target:
+		t.Errorf("Bot said %q to %s, want it said to %s.",
+			line.Text(), line.Args[0], target)
+		return nil
+	}
+	return line
+}
+
+// ExpectNothing fails the test if the bot sends anything within Quiet.
+func (b *Bot) ExpectNothing(t testing.TB) {
+	t.Helper()
+	if line := b.srv.next(Quiet); line != nil {
+		t.Errorf("Bot sent %q, want nothing.", line.Raw)
+	}
+}
@@ -0,0 +1,388 @@
+package bottest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/sp0rkle/bot"
+)
+
+func TestMain(m *testing.M) {
+	// root is the owner.
+	flag.Set("rebuilder", "root!*@*")
+	os.Exit(Run(m, func() {
+		d := bot.NewDriver("echo")
+		d.Command(func(ctx *bot.Context) {
+			ctx.ReplyN("%s", ctx.Arg("text"))
+		}, "echo", "echo <text>  -- says it back.", bot.Args(bot.RestArg("text")))
+		d.Command(func(ctx *bot.Context) {
+			var m map[string]bool
+			m[ctx.Text()] = true
+		}, "boom", "boom  -- panics.")
+		d.Handle(func(ctx *bot.Context) {
+			if ctx.Nick != ctx.Me() {
+				ctx.Do("waves at %s", ctx.Nick)
+			}
+		}, client.JOIN)
+		d.Rewrite(func(s string, ctx *bot.Context) string {
+			return strings.ReplaceAll(s, "$echo", "ECHO")
+		})
+		bot.Schedule("greet", "@yearly", func(ctxs []*bot.Context) error {
+			for _, ctx := range ctxs {
+				ctx.Privmsg(Chan, "scheduled hello")
+			}
+			return nil
+		})
+		bot.Schedule("tick", "every 1s", func(ctxs []*bot.Context) error {
+			for _, ctx := range ctxs {
+				ctx.Privmsg(Chan, "tick")
+			}
+			return nil
+		}, bot.Disabled())
+		bot.Schedule("broken", "every 1h", func([]*bot.Context) error {
+			return errors.New("oops")
+		}, bot.Disabled())
+	}))
+}
+
+func TestBot(t *testing.T) {
+	b := New(t)
+	b.Privmsg("alice", Chan, "sp0rkle: drivers")
+	b.Expect(t, Chan, "alice: Drivers: echo. All enabled in #sp0rkle.")
+
+	// Commands must be addressed to the bot in channels, but not privately.
+	b.Privmsg("alice", Chan, "echo hi")
+	b.ExpectNothing(t)
+	b.Privmsg("alice", Chan, "sp0rkle: echo hi there")
+	b.Expect(t, Chan, "alice: hi there")
+	b.Privmsg("alice", Chan, Trigger+"echo triggered")
+	b.Expect(t, Chan, "alice: triggered")
+	b.Privmsg("alice", "#other", Trigger+"echo triggered")
+	b.ExpectNothing(t)
+	b.Privmsg("alice", Nick, "echo hi")
+	b.ExpectMatch(t, "alice", "^alice: h")
+
+	// Typos get suggestions, other addressed lines are ignored.
+	b.Privmsg("alice", Chan, "sp0rkle: ecko hi")
+	b.Expect(t, Chan, "alice: Did you mean 'echo'?")
+	b.Privmsg("alice", Chan, "sp0rkle: how are you?")
+	b.ExpectNothing(t)
+
+	b.Join("bob", Chan)
+	if line := b.Next(); line == nil || line.Cmd != client.ACTION ||
+		line.Target() != Chan || line.Text() != "waves at bob" {
+		t.Errorf("Got %v, want the bot to wave at bob.", line)
+	}
+}
+
+func TestDrivers(t *testing.T) {
+	b := New(t)
+	b.Join("carol", Chan)
+	b.Expect(t, Chan, "waves at carol")
+	// The bot has to find out who carol is first.
+	b.Privmsg("carol", Chan, "sp0rkle: driver disable echo")
+	b.Expect(t, Chan, "carol: I'm not sure who you are yet, try again in a moment.")
+	b.ExpectWhois(t, "carol")
+	b.Privmsg("carol", Chan, "sp0rkle: driver disable echo")
+	b.Expect(t, Chan, "carol: Sorry, you need to be admin or an op in #sp0rkle to do that.")
+
+	// Channel ops can turn drivers on and off in their channel.
+	b.Raw(":irc.test MODE " + Chan + " +o carol")
+	b.Privmsg("carol", Chan, "sp0rkle: driver enable $echo")
+	b.Expect(t, Chan, "carol: 'ECHO' isn't a driver. Try one of: echo.")
+	b.Privmsg("carol", Chan, "sp0rkle: driver disable echo")
+	b.Expect(t, Chan, "carol: Driver echo is now disabled in #sp0rkle.")
+	// The driver's rewriters don't touch replies there now.
+	b.Privmsg("carol", Chan, "sp0rkle: driver enable $echo")
+	b.Expect(t, Chan, "carol: '$echo' isn't a driver. Try one of: echo.")
+	b.Privmsg("carol", Chan, "sp0rkle: driver disable echo #other")
+	b.Expect(t, Chan, "carol: Sorry, you need to be admin or an op in #other to do that.")
+	b.Privmsg("carol", Chan, "sp0rkle: driver enable echo")
+	b.Expect(t, Chan, "carol: Driver echo is now enabled in #sp0rkle.")
+	b.Part("carol", Chan)
+}
+
+func TestUnknown(t *testing.T) {
+	b := New(t)
+	// Until the bot finds out who dan is, it can't tell what they can do.
+	b.IgnoreWhois("dan")
+	b.Privmsg("dan", Chan, "sp0rkle: driver disable echo")
+	b.Expect(t, Chan, "dan: I'm not sure who you are yet, try again in a moment.")
+	b.Privmsg("dan", Chan, "sp0rkle: driver disable echo")
+	b.Expect(t, Chan, "dan: I'm not sure who you are yet, try again in a moment.")
+	// Commands anyone can use don't need to know.
+	b.Privmsg("dan", Chan, "sp0rkle: echo hi")
+	b.Expect(t, Chan, "dan: hi")
+}
+
+func TestErrors(t *testing.T) {
+	b := New(t)
+	b.Privmsg("alice", Chan, "sp0rkle: boom")
+	line := b.Next()
+	if line == nil {
+		t.Fatalf("Bot didn't apologise.")
+	}
+	m := regexp.MustCompile("^Sorry, something went wrong. " +
+		"It has been recorded as error ([0-9a-f]{8}).$").FindStringSubmatch(line.Text())
+	if m == nil {
+		t.Fatalf("Got %v, want an apology with an error ID.", line)
+	}
+	id := m[1]
+	b.Privmsg("bob", Nick, "boom again")
+	b.Expect(t, "bob", "Sorry, something went wrong. It has been recorded as error "+id+".")
+
+	b.Privmsg("root", Nick, "errors list")
+	b.Expect(t, "root", "root: 1 errors have been recorded:")
+	b.ExpectMatch(t, "root", "^"+id+": panic: assignment to entry in nil map \\(2 times, last .* ago\\)$")
+	b.Privmsg("root", Nick, "errors show "+strings.ToUpper(id))
+	b.Expect(t, "root", "root: "+id+": panic: assignment to entry in nil map")
+	b.ExpectMatch(t, "root", "^Seen 2 times, first at .*, last at .*\\.$")
+	b.Expect(t, "root", "Last caused by a private message on test.")
+	b.ExpectMatch(t, "root", "^\\(0\\) bot/bottest.TestMain.* at .*bot/bottest/bottest_test.go:\\d+$")
+	// The rest of the stack is paged.
+	for line := b.Next(); line != nil && !strings.HasSuffix(line.Text(), `ask for "more".`); line = b.Next() {
+	}
+	b.Privmsg("root", Nick, "errors forget "+id)
+	b.Expect(t, "root", "root: Forgot error "+id+".")
+	b.Privmsg("root", Nick, "errors show "+id)
+	b.Expect(t, "root", "root: There's no error with ID '"+id+"'.")
+}
+
+func get(path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestMonitoring(t *testing.T) {
+	b := New(t)
+	if rec := get("/healthz"); rec.Code != http.StatusOK ||
+		rec.Body.String() != "ok\ntest: connected\n" {
+		t.Errorf("/healthz returned %d %q.", rec.Code, rec.Body.String())
+	}
+
+	b.Privmsg("alice", Nick, "echo counted")
+	b.Expect(t, "alice", "alice: counted")
+	body := get("/metrics").Body.String()
+	for _, want := range []string{
+		`sp0rkle_connected{network="test"} 1`,
+		`sp0rkle_command_lines_total{command="echo"} `,
+		`sp0rkle_command_duration_seconds_count{command="echo"} `,
+		`sp0rkle_handler_lines_total{handler="bot/bottest.TestMain.func1.3"} `,
+	} {
+		if !strings.Contains(body, "\n"+want) {
+			t.Errorf("/metrics doesn't contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	b := New(t)
+	b.Privmsg("root", Nick, "jobs")
+	b.ExpectMatch(t, "root", "^root: broken: every 1h0m0s, disabled, ")
+	b.ExpectMatch(t, "root", "^greet: @yearly, next run at ")
+	b.ExpectMatch(t, "root", "^tick: every 1s, disabled, ")
+
+	b.Privmsg("root", Nick, "jobs run greet")
+	b.Expect(t, Chan, "scheduled hello")
+	b.ExpectMatch(t, "root", "^root: Ran greet in .*s\\.$")
+	b.Privmsg("root", Nick, "jobs run broken")
+	b.Expect(t, "root", "root: broken failed: oops")
+	b.Privmsg("root", Nick, "jobs run nope")
+	b.Expect(t, "root", "root: There's no job called 'nope'.")
+
+	// Enabled jobs run on their schedule.
+	b.Privmsg("root", Nick, "jobs enable tick")
+	b.Expect(t, "root", "root: tick will run every 1s.")
+	b.Expect(t, Chan, "tick")
+	b.Privmsg("root", Nick, "jobs disable tick")
+	b.Expect(t, "root", "root: tick won't run until it is enabled again.")
+
+	b.Privmsg("root", Nick, "jobs schedule greet sometimes")
+	b.ExpectMatch(t, "root", "^root: Bad schedule 'sometimes': need an interval")
+	b.Privmsg("root", Nick, "jobs schedule greet every 2h ~5m")
+	b.Expect(t, "root", "root: greet will run every 2h0m0s ~5m0s.")
+
+	b.Privmsg("root", Nick, "jobs")
+	b.ExpectMatch(t, "root", "^root: broken: every 1h0m0s, disabled, "+
+		"last ran at .* for .*s, \\d+ runs, \\d+ failed, last error: oops$")
+	b.ExpectMatch(t, "root", "^greet: every 2h0m0s ~5m0s, next run at .*, "+
+		"last ran at .* for .*s, \\d+ runs, 0 failed\\.$")
+	b.ExpectMatch(t, "root", "^tick: every 1s, disabled, last ran at .*, [1-9]\\d* runs")
+
+	b.Privmsg("root", Nick, "jobs schedule greet default")
+	b.Expect(t, "root", "root: greet will run @yearly.")
+
+	// Admins can add jobs that say things, and delete only those.
+	b.Privmsg("root", Nick, `jobs add standup #sp0rkle "0 9 * * mon-fri" Standup time!`)
+	b.Expect(t, "root", "root: standup will run 0 9 * * mon-fri, saying that in #sp0rkle.")
+	b.Privmsg("root", Nick, "jobs add greet #sp0rkle @daily hi")
+	b.Expect(t, "root", "root: There's already a job called 'greet'.")
+	b.Privmsg("root", Nick, "jobs add later #sp0rkle sometimes hi")
+	b.ExpectMatch(t, "root", "^root: Bad schedule 'sometimes': need an interval")
+	b.Privmsg("root", Nick, "jobs run standup")
+	b.Expect(t, Chan, "Standup time!")
+	b.ExpectMatch(t, "root", "^root: Ran standup in .*s\\.$")
+	b.Privmsg("root", Nick, "jobs del greet")
+	b.Expect(t, "root", "root: greet is scheduled by the bot, it can only be disabled.")
+	b.Privmsg("root", Nick, "jobs del standup")
+	b.Expect(t, "root", "root: Deleted standup.")
+	b.Privmsg("root", Nick, "jobs run standup")
+	b.Expect(t, "root", "root: There's no job called 'standup'.")
+}
+
+// Subscribers can't be removed, so this one is only added once even if
+// the tests are run more than once.
+var subscribePanic sync.Once
+
+func TestEvents(t *testing.T) {
+	b := New(t)
+	added, all := make(chan bot.FactoidAdded, 1), make(chan bot.Event, 2)
+	bot.Subscribe(func(ev bot.FactoidAdded) { added <- ev })
+	bot.SubscribeAll(func(ev bot.Event) { all <- ev })
+	subscribePanic.Do(func() {
+		bot.Subscribe(func(bot.UrlSeen) { panic("subscriber panic") })
+	})
+
+	bot.Publish(bot.UrlSeen{Url: "http://example.com/", Nick: "alice", Chan: Chan})
+	want := bot.FactoidAdded{Key: "foo", Value: "bar", Nick: "alice", Chan: Chan}
+	bot.Publish(want)
+
+	timeout := time.After(5 * time.Second)
+	select {
+	case ev := <-added:
+		if ev != want {
+			t.Errorf("Subscriber got %#v, want %#v", ev, want)
+		}
+	case <-timeout:
+		t.Fatal("FactoidAdded not delivered.")
+	}
+	// The panicking subscriber doesn't stop everything else getting both.
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case ev := <-all:
+			seen[ev.String()] = true
+		case <-timeout:
+			t.Fatalf("SubscribeAll got %d events, want 2.", len(seen))
+		}
+	}
+	if !seen["alice taught me that 'foo' is 'bar' in #sp0rkle"] {
+		t.Errorf("SubscribeAll didn't get FactoidAdded: %v", seen)
+	}
+
+	// The panic is recorded as an error. Forget it, so TestErrors
+	// doesn't find it if the tests are run again.
+	re := regexp.MustCompile(`<h2 id="([0-9a-f]{8})">\w+: panic: subscriber panic</h2>`)
+	var m []string
+	for ; m == nil; time.Sleep(10 * time.Millisecond) {
+		select {
+		case <-timeout:
+			t.Fatal("Subscriber panic not recorded.")
+		default:
+		}
+		m = re.FindStringSubmatch(get("/errors").Body.String())
+	}
+	b.Privmsg("root", Nick, "errors forget "+m[1])
+	b.Expect(t, "root", "root: Forgot error "+m[1]+".")
+}
+
+func post(path, ctype, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", ctype)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPI(t *testing.T) {
+	b := New(t)
+	auth := []string{"Authorization", "Bearer " + APIToken}
+	say := "/api/say?channel=" + url.QueryEscape(Chan)
+	tests := []struct {
+		path, ctype, body string
+		headers           []string
+		code              int
+		says              []string
+	}{
+		{say, "text/plain", "hello", nil, http.StatusUnauthorized, nil},
+		{say, "text/plain", "hello", []string{"Authorization", "Bearer nope"},
+			http.StatusUnauthorized, nil},
+		{say, "text/plain", "hello from CI\n", auth, http.StatusOK, []string{"hello from CI"}},
+		{"/api/say", "application/json", `{"channel": "#SP0RKLE", "text": "one\ntwo"}`,
+			auth, http.StatusOK, []string{"one", "two"}},
+		{"/api/say", "application/x-www-form-urlencoded", "channel=%23other&text=hi",
+			auth, http.StatusForbidden, nil},
+		{say, "text/plain", "1\n2\n3\n4\n5\n6", auth, http.StatusBadRequest, nil},
+		{say, "text/plain", "", auth, http.StatusBadRequest, nil},
+		{say + "&template=build", "application/json", `{"number": 42, "status": "passed"}`,
+			auth, http.StatusOK, []string{"Build 42 passed."}},
+		{say + "&template=nope", "application/json", `{}`, auth, http.StatusBadRequest, nil},
+		{say + "&template=build", "application/json", `{"number": `, auth,
+			http.StatusBadRequest, nil},
+	}
+	for i, test := range tests {
+		rec := post(test.path, test.ctype, test.body, test.headers...)
+		if rec.Code != test.code {
+			t.Errorf("%d: POST %s %q returned %d %q, want %d.", i, test.path,
+				test.body, rec.Code, rec.Body.String(), test.code)
+		}
+		for _, s := range test.says {
+			b.Expect(t, Chan, s)
+		}
+		b.ExpectNothing(t)
+	}
+	if rec := get(say); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET %s returned %d, want %d.", say, rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	// GitHub webhooks sign the payload with the token, and can have a
+	// template for each type of event.
+	body := `{"action": "opened", "issue": {"number": 7}, "sender": {"login": "alice"}}`
+	mac := hmac.New(sha256.New, []byte(APIToken))
+	mac.Write([]byte(body))
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	for event, want := range map[string]string{
+		"issues": "alice opened issue #7.",
+		"push":   "alice poked the repo.",
+	} {
+		rec := post(say+"&template=gh", "application/json", body,
+			"X-GitHub-Event", event, "X-Hub-Signature-256", sig)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GitHub %s event returned %d %q.", event, rec.Code, rec.Body.String())
+		}
+		b.Expect(t, Chan, want)
+	}
+
+	// By default, GitHub sends the payload as a form value.
+	form := "payload=" + url.QueryEscape(body)
+	mac = hmac.New(sha256.New, []byte(APIToken))
+	mac.Write([]byte(form))
+	rec := post(say+"&template=gh", "application/x-www-form-urlencoded", form,
+		"X-GitHub-Event", "issues", "X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GitHub form returned %d %q.", rec.Code, rec.Body.String())
+	}
+	b.Expect(t, Chan, "alice opened issue #7.")
+
+	if body := get("/metrics").Body.String(); !strings.Contains(body,
+		`sp0rkle_api_requests_total{token="test",code="200"} `) {
+		t.Errorf("/metrics doesn't count API requests:\n%s", body)
+	}
+}
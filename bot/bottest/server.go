@@ -0,0 +1,221 @@
+package bottest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+const (
+	serverName = "irc.sp0rkle.test"
+	// The host of the bot, and of every nick the tests speak as.
+	userHost = "users.sp0rkle.test"
+)
+
+// server is a fake IRC server for the bot to connect to. It does just
+// enough for the bot to register, join channels and look people up, and
+// keeps everything else the bot sends for the tests to look at.
+type server struct {
+	l net.Listener
+
+	mu   sync.Mutex
+	conn net.Conn
+	// The bot's nick and ident, once it has registered.
+	nick, ident string
+	registered  bool
+	// Lines sent by the bot that tests are interested in.
+	sent  []*client.Line
+	ready chan struct{}
+	// Nicks whose WHOIS isn't answered, and nicks whose WHOIS has been.
+	unanswered map[string]bool
+	whoised    chan string
+}
+
+func newServer() (*server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &server{l: l, ready: make(chan struct{}, 1), whoised: make(chan string, 10)}
+	go s.serve()
+	return s, nil
+}
+
+func (s *server) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *server) close() {
+	s.l.Close()
+}
+
+// serve accepts connections from the bot one at a time, so that it can
+// reconnect if it is disconnected.
+func (s *server) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.session(conn)
+	}
+}
+
+func (s *server) session(conn net.Conn) {
+	s.mu.Lock()
+	s.conn, s.registered = conn, false
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := client.ParseLine(scanner.Text())
+		if line == nil {
+			continue
+		}
+		if line.Cmd == client.QUIT {
+			return
+		}
+		s.handle(line)
+	}
+}
+
+// handle responds to a line from the bot like a real server would.
+func (s *server) handle(line *client.Line) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch line.Cmd {
+	case client.NICK:
+		if !s.registered {
+			s.nick = line.Args[0]
+			return
+		}
+		s.write(":%s NICK :%s", s.src(), line.Args[0])
+		s.nick = line.Args[0]
+	case client.USER:
+		s.ident, s.registered = line.Args[0], true
+		s.write(":%s 001 %s :Welcome to the fake IRC network %s",
+			serverName, s.nick, s.src())
+		return
+	case client.JOIN:
+		for _, ch := range strings.Split(line.Args[0], ",") {
+			s.write(":%s JOIN %s", s.src(), ch)
+			s.write(":%s 353 %s = %s :%s", serverName, s.nick, ch, s.nick)
+			s.write(":%s 366 %s %s :End of /NAMES list.", serverName, s.nick, ch)
+		}
+	case client.PART:
+		s.write(":%s %s", s.src(), line.Raw)
+	case client.TOPIC:
+		if len(line.Args) > 1 {
+			s.write(":%s %s", s.src(), line.Raw)
+		}
+	case client.WHOIS:
+		if s.unanswered[strings.ToLower(line.Args[0])] {
+			return
+		}
+		// Nobody is logged in to an account.
+		s.write(":%s 318 %s %s :End of /WHOIS list.",
+			serverName, s.nick, line.Args[0])
+		select {
+		case s.whoised <- line.Args[0]:
+		default:
+		}
+		return
+	case client.PING:
+		s.write(":%s PONG %s :%s", serverName, serverName, line.Args[0])
+		return
+	case client.MODE:
+		// Ignore the bot setting its own modes when it connects, and
+		// goirc asking for a channel's modes when it joins.
+		if !strings.HasPrefix(line.Args[0], "#") || len(line.Args) == 1 {
+			return
+		}
+	case client.PRIVMSG, client.NOTICE, client.ACTION, client.KICK:
+	default:
+		return
+	}
+	line.Time = time.Now()
+	s.sent = append(s.sent, line)
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// disconnect drops the bot's connection and forgets what it sent. The bot
+// can't register again until fn has returned.
+func (s *server) disconnect(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.sent, s.unanswered = nil, nil
+	for len(s.whoised) > 0 {
+		<-s.whoised
+	}
+	return fn()
+}
+
+// ignoreWhois stops the server answering WHOIS for nick.
+func (s *server) ignoreWhois(nick string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unanswered == nil {
+		s.unanswered = make(map[string]bool)
+	}
+	s.unanswered[strings.ToLower(nick)] = true
+}
+
+// src returns the bot's nick!ident@host, with s.mu held.
+func (s *server) src() string {
+	return s.nick + "!" + s.ident + "@" + userHost
+}
+
+// write sends a line to the bot, with s.mu held.
+func (s *server) write(f string, args ...any) {
+	if s.conn == nil {
+		logging.Warn("Not connected, dropping %q.", fmt.Sprintf(f, args...))
+		return
+	}
+	if _, err := fmt.Fprintf(s.conn, f+"\r\n", args...); err != nil {
+		logging.Error("Write error: %v", err)
+	}
+}
+
+// send sends a line to the bot.
+func (s *server) send(f string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(f, args...)
+}
+
+// next returns the next line the bot sent, waiting up to timeout for one.
+func (s *server) next(timeout time.Duration) *client.Line {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if len(s.sent) > 0 {
+			line := s.sent[0]
+			s.sent = s.sent[1:]
+			s.mu.Unlock()
+			return line
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.ready:
+		case <-deadline:
+			return nil
+		}
+	}
+}
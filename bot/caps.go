@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+// capabilities are the IRCv3 capabilities we ask servers for. goirc only
+// requests the ones a server advertises in its CAP LS reply.
+var capabilities = []string{
+	// These let us work out who people are without needing to WHOIS.
+	"account-notify", "account-tag", "extended-join",
+	// Tags are exposed to drivers through Context.Tag.
+	"message-tags", "server-time",
+	// Echoes of our own messages are dropped by isEcho.
+	"echo-message",
+	// goirc's state tracker only understands one prefix per nick in
+	// NAMES replies, so fixNames cleans up after it.
+	"multi-prefix",
+}
+
+// serverTime returns the time the server says it received the line,
+// or the time we received it if the server-time tag is missing. The tag
+// is ISO 8601, usually but not always with millisecond precision.
+func serverTime(line *client.Line) time.Time {
+	if s, ok := line.Tags["time"]; ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err == nil {
+			return t
+		}
+		logging.Debug("Ignoring bad server-time %q: %v", s, err)
+	}
+	return line.Time
+}
+
+// isEcho returns true if line is the server echoing back one of our
+// own messages because echo-message is enabled. Handlers shouldn't
+// respond to these as they would to other people's messages.
+func isEcho(conn *client.Conn, line *client.Line) bool {
+	switch line.Cmd {
+	case client.PRIVMSG, client.NOTICE, client.ACTION, client.CTCP, client.CTCPREPLY:
+	default:
+		return false
+	}
+	return conn.HasCapability("echo-message") &&
+		strings.EqualFold(line.Nick, bot.servers.Transport(conn).Me())
+}
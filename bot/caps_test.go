@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestServerTime(t *testing.T) {
+	received := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		tags map[string]string
+		want time.Time
+	}{
+		{nil, received},
+		{map[string]string{"time": "2024-01-02T03:04:00.123Z"},
+			time.Date(2024, 1, 2, 3, 4, 0, 123e6, time.UTC)},
+		{map[string]string{"time": "2024-01-02T03:04:00Z"},
+			time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)},
+		{map[string]string{"time": "2024-01-02T03:04:00.123456Z"},
+			time.Date(2024, 1, 2, 3, 4, 0, 123456e3, time.UTC)},
+		{map[string]string{"time": "2024-01-02T04:04:00.5+01:00"},
+			time.Date(2024, 1, 2, 3, 4, 0, 5e8, time.UTC)},
+		{map[string]string{"time": "yesterday"}, received},
+		{map[string]string{"account": "someone"}, received},
+	}
+	for _, test := range tests {
+		line := &client.Line{Tags: test.tags, Time: received}
+		if got := serverTime(line); !got.Equal(test.want) {
+			t.Errorf("serverTime(%q) = %s, want %s", test.tags, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+const (
+	channelsNs = "channels"
+	// Values stored in channelsNs. Parts are remembered so that channels
+	// from --channels can be left permanently too.
+	chanJoined = "join"
+	chanParted = "part"
+)
+
+func isChannel(s string) bool {
+	return s != "" && strings.ContainsRune("#&+!", rune(s[0]))
+}
+
+// channelList merges a network's configured channels with channels that
+// admins have asked the bot to join or part at runtime.
+type channelList struct {
+	ns conf.Namespace
+}
+
+// channelsFor returns the channelList for the named network.
+func channelsFor(network string) channelList {
+	return channelList{ns: conf.Ns(channelsNs + ":" + network)}
+}
+
+func (cl channelList) Join(ch string) {
+	cl.ns.String(strings.ToLower(ch), chanJoined)
+}
+
+func (cl channelList) Part(ch string) {
+	cl.ns.String(strings.ToLower(ch), chanParted)
+}
+
+// Channels returns the sorted list of channels the bot should be in,
+// given a list of channels to join by default.
+func (cl channelList) Channels(defaults []string) []string {
+	set := map[string]bool{}
+	for _, ch := range defaults {
+		if ch = strings.TrimSpace(ch); isChannel(ch) {
+			set[strings.ToLower(ch)] = true
+		}
+	}
+	for _, e := range cl.ns.All() {
+		switch e.Value {
+		case chanJoined:
+			set[e.Key] = true
+		case chanParted:
+			delete(set, e.Key)
+		}
+	}
+	chans := make([]string, 0, len(set))
+	for ch := range set {
+		chans = append(chans, ch)
+	}
+	sort.Strings(chans)
+	return chans
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestChannelList(t *testing.T) {
+	cl := channelList{ns: conf.InMem(channelsNs)}
+
+	tests := []struct {
+		name     string
+		join     string
+		part     string
+		defaults []string
+		want     []string
+	}{
+		{
+			name:     "defaults only",
+			defaults: []string{"#sp0rklf", "#Other"},
+			want:     []string{"#other", "#sp0rklf"},
+		},
+		{
+			name:     "ignores empty and invalid defaults",
+			defaults: []string{"", "notachannel", " #spaced"},
+			want:     []string{"#spaced"},
+		},
+		{
+			name:     "joined channel added to defaults",
+			join:     "#Joined",
+			defaults: []string{"#sp0rklf"},
+			want:     []string{"#joined", "#sp0rklf"},
+		},
+		{
+			name:     "parted default channel is removed",
+			part:     "#SP0RKLF",
+			defaults: []string{"#sp0rklf"},
+			want:     []string{"#joined"},
+		},
+		{
+			name:     "parting a joined channel",
+			part:     "#joined",
+			defaults: []string{"#sp0rklf"},
+			want:     []string{},
+		},
+		{
+			name:     "rejoining a parted channel",
+			join:     "#sp0rklf",
+			defaults: []string{"#sp0rklf"},
+			want:     []string{"#sp0rklf"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.join != "" {
+				cl.Join(test.join)
+			}
+			if test.part != "" {
+				cl.Part(test.part)
+			}
+			got := cl.Channels(test.defaults)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Channels(%q) = %q, want %q", test.defaults, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package bot
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+// The chat protocol is a simple alternative to IRC, so that the bot can
+// be bridged to other chat systems. Each line sent over a TCP connection
+// is a JSON chatMsg. When the bot connects it sends a "hello" with its
+// nick, and a "join" for each channel. After that, both ends send
+// "message", "action", "topic", "join" and "part" messages to each other,
+// with the server filling in the nick of the sender of each one, and the
+// account they're logged in to if there is one. Messages without a
+// channel are private messages to or from Nick. The bot also sends
+// "notice" messages, which it would like to be less obtrusive, and a
+// "nick" message to change its nick, which the server confirms with
+// a "hello" that has its new nick.
+const (
+	chatHello   = "hello"
+	chatMessage = "message"
+	chatNotice  = "notice"
+	chatAction  = "action"
+	chatTopic   = "topic"
+	chatJoin    = "join"
+	chatPart    = "part"
+	chatNick    = "nick"
+)
+
+type chatMsg struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Nick    string `json:"nick,omitempty"`
+	Account string `json:"account,omitempty"`
+	Text    string `json:"text,omitempty"`
+}
+
+// Map between chat message types and the IRC commands in client.Lines.
+var (
+	chatToIRC = map[string]string{
+		chatMessage: client.PRIVMSG,
+		chatAction:  client.ACTION,
+		chatTopic:   client.TOPIC,
+		chatJoin:    client.JOIN,
+		chatPart:    client.PART,
+	}
+	ircToChat = map[string]string{
+		client.PRIVMSG: chatMessage,
+		client.NOTICE:  chatNotice,
+		client.ACTION:  chatAction,
+	}
+)
+
+// chatServer is the Transport for a chat protocol server.
+type chatServer struct {
+	// Lines from the server are passed to handle.
+	handle func(Transport, *client.Line)
+
+	mu      sync.RWMutex
+	network *Network
+	me      string
+	conn    net.Conn
+
+	out  *outQueue
+	wg   *sync.WaitGroup
+	quit chan struct{}
+}
+
+func newChatServer(n *Network, wg *sync.WaitGroup) *chatServer {
+	c := &chatServer{
+		// This is called by Init, before bot is set.
+		handle: func(t Transport, line *client.Line) {
+			bot.handlers.dispatch(t, line)
+		},
+		network: n,
+		me:      n.Nick,
+		wg:      wg,
+		quit:    make(chan struct{}),
+	}
+	c.out = newOutQueue(c.writeLine)
+	return c
+}
+
+func (c *chatServer) connectLoop() {
+	defer c.wg.Done()
+	defer c.out.stop()
+	for attempt := 0; ; attempt++ {
+		n := c.settings()
+		if attempt > 0 {
+			reconnects.Inc(n.Name)
+		}
+		logging.Info("Connecting to %s (%s).", n.Server, n.Name)
+		conn, err := c.dial(n)
+		if err == nil {
+			setConnected(n.Name, true)
+			c.session(conn, channelsFor(n.Name).Channels(n.Channels))
+			setConnected(n.Name, false)
+			logging.Info("Disconnected from %s...", n.Server)
+		} else {
+			logging.Error("Connection error: %s", err)
+			setConnected(n.Name, false)
+		}
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(n.Pause):
+		}
+	}
+}
+
+func (c *chatServer) dial(n *Network) (net.Conn, error) {
+	if !n.TLS.Enabled {
+		return net.Dial("tcp", n.Server)
+	}
+	cfg, err := n.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", n.Server, cfg)
+}
+
+// session says hello, joins chans and handles lines from the server
+// until it disconnects.
+func (c *chatServer) session(conn net.Conn, chans []string) {
+	c.mu.Lock()
+	c.conn = conn
+	me, n := c.me, c.network
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		c.out.clear()
+		conn.Close()
+	}()
+	// Closing the connection on shutdown ends the read loop below.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-c.quit:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	c.write(chatMsg{Type: chatHello, Nick: me})
+	for _, ch := range chans {
+		logging.Info("Joining %s on %s on startup.\n", ch, n.Name)
+		c.Join(ch)
+	}
+	// Handlers for these do things like loading reminders, as for IRC.
+	c.handle(c, &client.Line{Cmd: client.CONNECTED, Time: time.Now()})
+	defer c.handle(c, &client.Line{Cmd: client.DISCONNECTED, Time: time.Now()})
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var m chatMsg
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			logging.Warn("Bad line from %s: %v", n.Name, err)
+			continue
+		}
+		if m.Type == chatHello {
+			c.mu.Lock()
+			c.me = m.Nick
+			c.mu.Unlock()
+			continue
+		}
+		if line := c.line(m); line != nil {
+			c.handle(c, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logging.Error("Read error from %s: %v", n.Name, err)
+	}
+}
+
+// line converts a message from the server into a client.Line.
+func (c *chatServer) line(m chatMsg) *client.Line {
+	cmd, ok := chatToIRC[m.Type]
+	if !ok || m.Nick == "" {
+		return nil
+	}
+	host := c.Network()
+	line := &client.Line{
+		Nick: m.Nick, Ident: m.Nick, Host: host,
+		Src: m.Nick + "!" + m.Nick + "@" + host,
+		Cmd: cmd, Time: time.Now(),
+	}
+	if m.Account != "" {
+		line.Tags = map[string]string{"account": m.Account}
+	}
+	target := m.Channel
+	if target == "" {
+		target = c.Me()
+	}
+	switch cmd {
+	case client.JOIN:
+		line.Args = []string{target}
+	default:
+		line.Args = []string{target, m.Text}
+	}
+	return line
+}
+
+// write sends a message to the server straight away.
+func (c *chatServer) write(m chatMsg) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		logging.Error("Couldn't encode %#v: %v", m, err)
+		return
+	}
+	c.writeLine(string(data))
+}
+
+// writeLine sends a JSON-encoded message to the server, if we're connected.
+func (c *chatServer) writeLine(line string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil {
+		return
+	}
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		logging.Error("Write error to %s: %v", c.network.Name, err)
+	}
+}
+
+func (c *chatServer) shutdown() {
+	close(c.quit)
+}
+
+// settings returns the network's configuration, which can change if the
+// config is reloaded.
+func (c *chatServer) settings() *Network {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.network
+}
+
+// connected returns true during a session with the server.
+func (c *chatServer) connected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn != nil
+}
+
+func (c *chatServer) Network() string {
+	return c.settings().Name
+}
+
+func (c *chatServer) Me() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.me
+}
+
+// Send rate limits text with the outQueue, the same as for IRC.
+func (c *chatServer) Send(cmd, target, text string) {
+	m := chatMsg{Type: ircToChat[cmd], Text: text}
+	if m.Type == "" {
+		logging.Error("Can't send %s to %s on %s.", cmd, target, c.Network())
+		return
+	}
+	if isChannel(target) {
+		m.Channel = target
+	} else {
+		m.Nick = target
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		logging.Error("Couldn't encode %#v: %v", m, err)
+		return
+	}
+	c.out.queue(target, string(data))
+}
+
+func (c *chatServer) Topic(ch string, topic ...string) {
+	c.write(chatMsg{Type: chatTopic, Channel: ch, Text: strings.Join(topic, " ")})
+}
+
+func (c *chatServer) Join(ch string) {
+	c.write(chatMsg{Type: chatJoin, Channel: ch})
+}
+
+func (c *chatServer) Part(ch string, msg ...string) {
+	c.write(chatMsg{Type: chatPart, Channel: ch, Text: strings.Join(msg, " ")})
+}
+
+func (c *chatServer) Nick(nick string) {
+	c.write(chatMsg{Type: chatNick, Nick: nick})
+}
+
+// Identity trusts the server to tell us the account of each sender.
+func (c *chatServer) Identity(line *client.Line) *Identity {
+	id := lineIdentity(line)
+	id.Known = true
+	return id
+}
+
+// Account doesn't know, as the server only tells us the accounts of
+// the senders of lines, and there's no way to ask about anyone else.
+func (c *chatServer) Account(nick string) (string, bool) {
+	return "", false
+}
+
+func (c *chatServer) Whois(nick string) {}
+
+func (c *chatServer) reconfigure(n *Network) []string {
+	old := c.settings()
+	c.mu.Lock()
+	c.network = n
+	c.mu.Unlock()
+	changes := []string{}
+	if old.Nick != n.Nick {
+		c.Nick(n.Nick)
+		changes = append(changes, "nick "+old.Nick+" -> "+n.Nick)
+	}
+	cl := channelsFor(n.Name)
+	join, part := diffStrings(cl.Channels(old.Channels), cl.Channels(n.Channels))
+	for _, ch := range join {
+		c.Join(ch)
+	}
+	for _, ch := range part {
+		c.Part(ch)
+	}
+	if len(join)+len(part) > 0 {
+		changes = append(changes, "channels "+describe(join, part))
+	}
+	if old.Server != n.Server || old.TLS != n.TLS {
+		changes = append(changes, "connection settings changed, restart to apply")
+	}
+	for i, ch := range changes {
+		changes[i] = n.Name + ": " + ch
+	}
+	return changes
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestChatSession(t *testing.T) {
+	lines := make(chan *client.Line, 1)
+	c := &chatServer{
+		handle:  func(_ Transport, line *client.Line) { lines <- line },
+		network: &Network{Name: "chatnet", Nick: "sp0rkle"},
+		me:      "sp0rkle",
+		quit:    make(chan struct{}),
+	}
+	c.out = newOutQueue(c.writeLine)
+	go c.out.run()
+	defer c.out.stop()
+
+	// The fake server is the other end of a pipe.
+	conn, srv := net.Pipe()
+	defer srv.Close()
+	done := make(chan struct{})
+	go func() {
+		c.session(conn, []string{"#chan"})
+		close(done)
+	}()
+	dec, enc := json.NewDecoder(srv), json.NewEncoder(srv)
+	expect := func(want chatMsg) {
+		t.Helper()
+		srv.SetReadDeadline(time.Now().Add(time.Second))
+		var got chatMsg
+		if err := dec.Decode(&got); err != nil || got != want {
+			t.Errorf("Server got %+v, %v; want %+v", got, err, want)
+		}
+	}
+	receive := func() *client.Line {
+		t.Helper()
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a line.")
+		}
+		return nil
+	}
+
+	expect(chatMsg{Type: chatHello, Nick: "sp0rkle"})
+	expect(chatMsg{Type: chatJoin, Channel: "#chan"})
+	if line := receive(); line.Cmd != client.CONNECTED {
+		t.Errorf("Got line %+v, want CONNECTED", line)
+	}
+
+	// The server can change our nick.
+	enc.Encode(chatMsg{Type: chatHello, Nick: "sp0rkle_"})
+	enc.Encode(chatMsg{Type: chatMessage, Channel: "#chan", Nick: "alice",
+		Account: "alice", Text: "sp0rkle_: hi"})
+	line := receive()
+	if c.Me() != "sp0rkle_" {
+		t.Errorf("Me() = %q after hello", c.Me())
+	}
+	want := &client.Line{Nick: "alice", Ident: "alice", Host: "chatnet",
+		Src: "alice!alice@chatnet", Cmd: client.PRIVMSG,
+		Args: []string{"#chan", "sp0rkle_: hi"},
+		Tags: map[string]string{"account": "alice"}, Time: line.Time}
+	if !reflect.DeepEqual(line, want) {
+		t.Errorf("Got line %+v, want %+v", line, want)
+	}
+	if id := c.Identity(line); !id.Known || id.Account != "alice" {
+		t.Errorf("Identity() = %+v, want account alice", id)
+	}
+
+	// Private messages are to us, and from whoever sent them.
+	enc.Encode(chatMsg{Type: chatAction, Nick: "bob", Text: "waves"})
+	line = receive()
+	if line.Cmd != client.ACTION || line.Args[0] != "sp0rkle_" ||
+		line.Target() != "bob" || line.Text() != "waves" {
+		t.Errorf("Got private line %+v", line)
+	}
+	if id := c.Identity(line); !id.Known || id.Account != "" {
+		t.Errorf("Identity() = %+v, want no account", id)
+	}
+	enc.Encode(chatMsg{Type: "bogus", Nick: "bob"})
+	enc.Encode(chatMsg{Type: chatJoin, Channel: "#chan", Nick: "carol"})
+	if line = receive(); line.Cmd != client.JOIN || !reflect.DeepEqual(line.Args, []string{"#chan"}) {
+		t.Errorf("Got join line %+v", line)
+	}
+
+	c.Send(client.ACTION, "#chan", "waves back")
+	expect(chatMsg{Type: chatAction, Channel: "#chan", Text: "waves back"})
+	c.Send(client.NOTICE, "bob", "hi")
+	expect(chatMsg{Type: chatNotice, Nick: "bob", Text: "hi"})
+	// Pipes are unbuffered, and Part writes straight away.
+	go c.Part("#chan", "bye")
+	expect(chatMsg{Type: chatPart, Channel: "#chan", Text: "bye"})
+
+	// Shutting down ends the session.
+	c.shutdown()
+	if line := receive(); line.Cmd != client.DISCONNECTED {
+		t.Errorf("Got line %+v, want DISCONNECTED", line)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Session didn't end on shutdown.")
+	}
+}
+
+type fakeTransport struct {
+	Transport
+	sent chan string
+}
+
+func (ft fakeTransport) Send(cmd, target, text string) {
+	ft.sent <- target
+}
+
+func (ft fakeTransport) Network() string {
+	return "test"
+}
+
+type lineHandlerFunc func(Transport, *client.Line)
+
+func (f lineHandlerFunc) handleLine(t Transport, line *client.Line) {
+	f(t, line)
+}
+
+func TestHandlerSet(t *testing.T) {
+	hs := newHandlerSet()
+	called := make(chan string, 3)
+	hs.add(client.PRIVMSG, lineHandlerFunc(func(_ Transport, line *client.Line) {
+		called <- "fg"
+	}), false)
+	hs.add(client.PRIVMSG, lineHandlerFunc(func(_ Transport, line *client.Line) {
+		called <- "bg"
+	}), true)
+	hs.add(client.JOIN, lineHandlerFunc(func(_ Transport, line *client.Line) {
+		called <- "join"
+	}), false)
+	hs.add("privmsg", lineHandlerFunc(func(_ Transport, line *client.Line) {
+		panic("oops")
+	}), false)
+
+	ft := fakeTransport{sent: make(chan string, 1)}
+	hs.dispatch(ft, &client.Line{Nick: "alice", Cmd: client.PRIVMSG,
+		Args: []string{"#chan", "hi"}})
+	got := map[string]bool{<-called: true, <-called: true}
+	if !got["fg"] || !got["bg"] {
+		t.Errorf("Handlers called: %v", got)
+	}
+	select {
+	case target := <-ft.sent:
+		if target != "#chan" {
+			t.Errorf("Panic reported to %q", target)
+		}
+	default:
+		t.Errorf("Panic not reported.")
+	}
+	select {
+	case c := <-called:
+		t.Errorf("Unexpected handler %q called.", c)
+	default:
+	}
+}
@@ -9,19 +9,169 @@ import (
 const ignoreNs = "ignore"
 
 func ignore(ctx *Context) {
-	nick := strings.ToLower(strings.Fields(ctx.Text())[0])
-	if nick == "" {
-		return
-	}
+	nick := strings.ToLower(ctx.Arg("nick|mask"))
 	conf.Ns(ignoreNs).String(nick, "ignore")
+	bot.ignores.refresh()
 	ctx.ReplyN("I'll ignore '%s'.", nick)
 }
 
 func unignore(ctx *Context) {
-	nick := strings.ToLower(strings.Fields(ctx.Text())[0])
-	if nick == "" {
-		return
-	}
+	nick := strings.ToLower(ctx.Arg("nick|mask"))
 	conf.Ns(ignoreNs).Delete(nick)
+	bot.ignores.refresh()
 	ctx.ReplyN("No longer ignoring '%s'.", nick)
 }
+
+// maskFor turns a nick into an account mask, using the account the nick
+// is logged in to if we know it, or assuming the nick is the account name.
+func maskFor(ctx *Context, s string) string {
+	if IsMask(s) {
+		return s
+	}
+	if acct, _ := ctx.t.Account(s); acct != "" {
+		return accountPrefix + acct
+	}
+	return accountPrefix + s
+}
+
+func grant(ctx *Context) {
+	mask, role := maskFor(ctx, ctx.Arg("nick|mask")), ctx.Arg("role")
+	r, ok := ParseRole(role)
+	if !ok {
+		ctx.ReplyN("'%s' isn't a role. Try one of: %s.", role,
+			strings.Join(roleNames, ", "))
+		return
+	}
+	// Only owners can create more owners; otherwise, you can't
+	// grant a role higher than or equal to your own.
+	if mine := ctx.Role(); mine != Owner && r >= mine {
+		ctx.ReplyN("You can't grant a role of %s as you're only %s.", r, mine)
+		return
+	}
+	if cur := bot.acl.Granted(mask); cur >= ctx.Role() && ctx.Role() != Owner {
+		ctx.ReplyN("%s is already %s, which you can't change.", mask, cur)
+		return
+	}
+	bot.acl.Grant(mask, r)
+	ctx.ReplyN("%s is now %s.", mask, r)
+}
+
+func revoke(ctx *Context) {
+	mask := maskFor(ctx, ctx.Arg("nick|mask"))
+	cur := bot.acl.Granted(mask)
+	if cur == User {
+		ctx.ReplyN("%s doesn't have a role to revoke.", mask)
+		return
+	}
+	if mine := ctx.Role(); mine != Owner && cur >= mine {
+		ctx.ReplyN("You can't revoke %s from %s as you're only %s.",
+			cur, mask, mine)
+		return
+	}
+	bot.acl.Revoke(mask)
+	ctx.ReplyN("%s is no longer %s.", mask, cur)
+}
+
+func roles(ctx *Context) {
+	grants := bot.acl.Grants()
+	if len(grants) == 0 {
+		ctx.ReplyN("Nobody has been granted any roles.")
+		return
+	}
+	ctx.ReplyN("Roles: %s.", strings.Join(grants, ", "))
+}
+
+func joinChan(ctx *Context) {
+	ch := ctx.Arg("#chan")
+	channelsFor(ctx.Network()).Join(ch)
+	ctx.t.Join(ch)
+	ctx.ReplyN("Joining %s.", ch)
+}
+
+func partChan(ctx *Context) {
+	ch := ctx.Arg("#chan")
+	channelsFor(ctx.Network()).Part(ch)
+	if msg := ctx.Arg("message"); msg != "" {
+		ctx.t.Part(ch, msg)
+	} else {
+		ctx.t.Part(ch)
+	}
+	ctx.ReplyN("Leaving %s.", ch)
+}
+
+func changeNick(ctx *Context) {
+	ctx.t.Nick(ctx.Arg("newnick"))
+}
+
+func say(ctx *Context) {
+	ctx.Privmsg(ctx.Arg("#chan"), ctx.Arg("text"))
+}
+
+func act(ctx *Context) {
+	ctx.Action(ctx.Arg("#chan"), ctx.Arg("text"))
+}
+
+// Checks the driver argument for driver enable and disable.
+func driverArg(ctx *Context) (string, bool) {
+	d := strings.ToLower(ctx.Arg("driver"))
+	if !isDriver(d) {
+		ctx.ReplyN("'%s' isn't a driver. Try one of: %s.", d,
+			strings.Join(driverNames(), ", "))
+		return "", false
+	}
+	return d, true
+}
+
+// opOrAdmin returns true if the sender of ctx is an admin or an op in ch,
+// and replies if not.
+func opOrAdmin(ctx *Context, ch string) bool {
+	if p, _ := ctx.Privs(ch, ctx.Nick); p.Op || p.Admin || p.Owner || ctx.Can(Admin) {
+		return true
+	}
+	if !ctx.unknown() {
+		ctx.ReplyN("Sorry, you need to be %s or an op in %s to do that.", Admin, ch)
+	}
+	return false
+}
+
+func disableDriver(ctx *Context) {
+	d, ok := driverArg(ctx)
+	if !ok {
+		return
+	}
+	ch := ctx.Arg("#chan")
+	if !opOrAdmin(ctx, ch) {
+		return
+	}
+	bot.policy.Disable(d, ch)
+	ctx.ReplyN("Driver %s is now disabled in %s.", d, ch)
+}
+
+func enableDriver(ctx *Context) {
+	d, ok := driverArg(ctx)
+	if !ok {
+		return
+	}
+	ch := ctx.Arg("#chan")
+	if !opOrAdmin(ctx, ch) {
+		return
+	}
+	bot.policy.Enable(d, ch)
+	ctx.ReplyN("Driver %s is now enabled in %s.", d, ch)
+}
+
+func listDrivers(ctx *Context) {
+	ch := ctx.Arg("#chan")
+	drivers := driverNames()
+	if len(drivers) == 0 {
+		ctx.ReplyN("No drivers are loaded.")
+		return
+	}
+	if off := bot.policy.Disabled(ch); len(off) > 0 {
+		ctx.ReplyN("Drivers: %s. Disabled in %s: %s.",
+			strings.Join(drivers, ", "), ch, strings.Join(off, ", "))
+		return
+	}
+	ctx.ReplyN("Drivers: %s. All enabled in %s.",
+		strings.Join(drivers, ", "), ch)
+}
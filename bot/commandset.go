@@ -1,8 +1,13 @@
 package bot
 
 import (
+	"fmt"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
@@ -12,38 +17,155 @@ import (
 type HandlerFunc func(*Context)
 
 func (hf HandlerFunc) Handle(conn *client.Conn, line *client.Line) {
-	if !bot.filters.ShouldProcess(line) {
+	if isEcho(conn, line) || !startLine(bot.servers.Transport(conn), line) {
 		return
 	}
+	defer bot.inflight.done()
+	defer observeHandler(hf, time.Now())
 	hf(reqContext(conn, line))
 }
 
+func (hf HandlerFunc) handleLine(t Transport, line *client.Line) {
+	if !startLine(t, line) {
+		return
+	}
+	defer bot.inflight.done()
+	defer observeHandler(hf, time.Now())
+	hf(newContext(t, line))
+}
+
+// startLine returns true if a line should be handled, in which case
+// bot.inflight.done must be called once it has been.
+func startLine(t Transport, line *client.Line) bool {
+	return bot.filters.ShouldProcess(line) && !bot.ignores.ignored(t, line) &&
+		bot.inflight.start()
+}
+
 type Runner interface {
 	Run(context *Context)
 	Help() string
+	Requires() Role
 }
 
 type command struct {
-	fn   HandlerFunc
-	help string
+	fn     HandlerFunc
+	prefix string
+	help   string
+	role   Role
+	driver *Driver
+	args   []Arg
+	// If set, how often each nick may run the command.
+	cooldown     *RateLimit
+	cooldownName string
+	// Used by help to group and explain commands.
+	category string
+	examples []string
+	// If set, the prefix must be followed by the end of a word.
+	wholeWord bool
+}
+
+// CommandOpt sets optional properties of a command when it is registered.
+type CommandOpt func(*command)
+
+// Requires restricts a command to callers with at least the given role.
+func Requires(r Role) CommandOpt {
+	return func(c *command) {
+		c.role = r
+	}
+}
+
+// WholeWord stops a command matching lines that start with its prefix
+// as part of a longer word, so "act" doesn't run for "actually ...".
+func WholeWord() CommandOpt {
+	return func(c *command) {
+		c.wholeWord = true
+	}
+}
+
+// Cooldown limits how often each nick may run a command. Commands with the
+// same name share a limit, which the config file's rate_limits can change.
+// Commands run too soon are silently ignored.
+func Cooldown(name string, def RateLimit) CommandOpt {
+	return func(c *command) {
+		c.cooldown, c.cooldownName = &def, name
+	}
+}
+
+// Category puts a command in a help category. Commands are in their
+// driver's category by default, or "bot" if they don't have a driver.
+func Category(name string) CommandOpt {
+	return func(c *command) {
+		c.category = strings.ToLower(name)
+	}
+}
+
+// Examples are shown by help, to make it clearer how to use a command.
+func Examples(ex ...string) CommandOpt {
+	return func(c *command) {
+		c.examples = append(c.examples, ex...)
+	}
+}
+
+// inDriver marks a command as belonging to a driver, so it is only run
+// in channels where the driver is enabled.
+func inDriver(d *Driver) CommandOpt {
+	return func(c *command) {
+		c.driver = d
+		if c.category == "" {
+			c.category = d.name
+		}
+	}
 }
 
 func (c *command) Run(ctx *Context) {
+	if c.args != nil {
+		args, err := parseArgs(ctx, c.args, ctx.Text())
+		if err != nil {
+			ctx.ReplyN("%s Usage: %s %s", err, c.prefix, argsUsage(c.args))
+			return
+		}
+		ctx.args = args
+	}
+	if c.cooldown != nil && RateLimited(c.cooldownName, ctx.Nick, *c.cooldown) {
+		return
+	}
+	defer observeCommand(c.prefix, time.Now())
 	c.fn(ctx)
 }
 
 func (c *command) Help() string {
-	return c.help
+	h := c.help
+	if c.args != nil {
+		h = fmt.Sprintf("%s %s  -- %s", c.prefix, argsUsage(c.args), c.help)
+	}
+	if c.role > User {
+		h += fmt.Sprintf(" (needs %s)", c.role)
+	}
+	if len(c.examples) > 0 {
+		h += fmt.Sprintf(" (e.g. '%s')", strings.Join(c.examples, "', '"))
+	}
+	return h
+}
+
+func (c *command) Requires() Role {
+	return c.role
 }
 
 type CommandSet interface {
 	client.Handler
+	http.Handler
+	lineHandler
 	Add(command Runner, prefix string)
+	Suggest(fn SuggestFunc)
+	match(txt string) (Runner, int)
+	setAliases(as *aliasSet)
 }
 
 type commandSet struct {
 	sync.RWMutex
-	set map[string]Runner
+	set        map[string]Runner
+	aliases    *aliasSet
+	suggesters []SuggestFunc
 }
 
 func newCommandSet() *commandSet {
@@ -67,8 +189,28 @@ func (cs *commandSet) Add(r Runner, prefix string) {
 	cs.set[prefix] = r
 }
 
-// commandSet.match() mostly gratuitously stolen from net/http ;-)
-func (cs *commandSet) match(txt string) (final Runner, prefixlen int) {
+func (cs *commandSet) setAliases(as *aliasSet) {
+	cs.Lock()
+	defer cs.Unlock()
+	cs.aliases = as
+}
+
+// match returns the command or alias with the longest prefix of txt as
+// its name. Commands win if an alias has the same name as one.
+func (cs *commandSet) match(txt string) (Runner, int) {
+	r, ln := cs.matchCommand(txt)
+	cs.RLock()
+	as := cs.aliases
+	cs.RUnlock()
+	if a, aln := as.match(txt); a != nil && aln > ln {
+		a.cs = cs
+		return a, aln
+	}
+	return r, ln
+}
+
+// commandSet.matchCommand() mostly gratuitously stolen from net/http ;-)
+func (cs *commandSet) matchCommand(txt string) (final Runner, prefixlen int) {
 	cs.RLock()
 	defer cs.RUnlock()
 
@@ -77,6 +219,9 @@ func (cs *commandSet) match(txt string) (final Runner, prefixlen int) {
 		if !strings.HasPrefix(lowertxt, strings.ToLower(prefix)) {
 			continue
 		}
+		if c, ok := r.(*command); ok && c.wholeWord && !endsWord(txt, len(prefix)) {
+			continue
+		}
 		if final == nil || len(prefix) > prefixlen {
 			prefixlen = len(prefix)
 			final = r
@@ -85,13 +230,29 @@ func (cs *commandSet) match(txt string) (final Runner, prefixlen int) {
 	return
 }
 
+// endsWord returns true if a prefix of length ln of txt is not followed
+// immediately by more of the same word, so "act" doesn't match "actually".
+// Prefixes that end in punctuation, like "quote #", can be followed by
+// anything.
+func endsWord(txt string, ln int) bool {
+	if ln >= len(txt) || unicode.IsSpace(rune(txt[ln])) {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(txt[:ln])
+	return !unicode.IsLetter(last) && !unicode.IsDigit(last)
+}
+
 func (cs *commandSet) possible(txt string) []string {
 	cs.RLock()
 	defer cs.RUnlock()
 
 	poss := []string{}
 	words := strings.Fields(strings.ToLower(txt))
+	prefixes := cs.aliases.Names()
 	for prefix := range cs.set {
+		prefixes = append(prefixes, prefix)
+	}
+	for _, prefix := range prefixes {
 		lowerPrefix := strings.ToLower(prefix)
 		for _, w := range words {
 			if strings.Contains(lowerPrefix, w) {
@@ -108,36 +269,75 @@ func (cs *commandSet) possible(txt string) []string {
 
 // Implement client.Handler so commandSet can Handle things directly.
 func (cs *commandSet) Handle(conn *client.Conn, line *client.Line) {
-	if !bot.filters.ShouldProcess(line) {
+	if isEcho(conn, line) || !startLine(bot.servers.Transport(conn), line) {
 		return
 	}
+	defer bot.inflight.done()
+	cs.dispatch(reqContext(conn, line), line)
+}
+
+func (cs *commandSet) handleLine(t Transport, line *client.Line) {
+	if !startLine(t, line) {
+		return
+	}
+	defer bot.inflight.done()
+	cs.dispatch(newContext(t, line), line)
+}
+
+func (cs *commandSet) dispatch(ctx *Context, line *client.Line) {
 	// This is a dirty hack to treat factoid additions as a special
 	// case, since they may begin with command string prefixes.
-	ctx := reqContext(conn, line)
 	if util.IsFactoidAddition(line.Text()) {
 		return
 	}
-	if r, ln := cs.match(ctx.Text()); ctx.Addressed && r != nil {
-		// Cut command off, trim and compress spaces.
-		ctx.Args[1] = strings.Join(strings.Fields(ctx.Args[1][ln:]), " ")
-		r.Run(ctx)
+	if !ctx.Addressed {
+		return
 	}
+	if r, ln := cs.match(ctx.Text()); r != nil {
+		cs.run(ctx, r, ln)
+		return
+	}
+	cs.suggest(ctx)
 }
 
-func (cs *commandSet) Run(ctx *Context) {
-	if r, _ := cs.match(ctx.Text()); r != nil {
-		ctx.ReplyN("%s", r.Help())
-	} else if len(ctx.Text()) == 0 {
-		ctx.ReplyN("https://github.com/fluffle/sp0rkle/wiki " +
-			"-- pull requests welcome ;-)")
-	} else if poss := cs.possible(ctx.Text()); len(poss) > 0 {
-		ctx.ReplyN("Commands matching %q: \"%s\".", ctx.Text(),
-			strings.Join(poss, "\", \""))
-	} else {
-		ctx.ReplyN("Unrecognised command '%s'.", ctx.Text())
+// run cuts a prefix of length ln off the text of ctx, and runs r with
+// what's left if the caller is allowed to.
+func (cs *commandSet) run(ctx *Context, r Runner, ln int) {
+	// Cut command off, trim and compress spaces.
+	ctx.Args[1] = strings.Join(strings.Fields(ctx.Args[1][ln:]), " ")
+	if c, ok := r.(*command); ok && c.driver != nil &&
+		!c.driver.ShouldProcess(ctx.Line) {
+		return
+	}
+	if role := r.Requires(); !ctx.Can(role) {
+		if !ctx.unknown() {
+			ctx.ReplyN("Sorry, you need to be %s to do that.", role)
+		}
+		return
 	}
+	r.Run(ctx)
+}
+
+// unknown returns true if we don't know who sent ctx's line yet, in which
+// case it asks, and tells them to try again once we've found out.
+func (ctx *Context) unknown() bool {
+	if ctx.Identity().Known {
+		return false
+	}
+	ctx.t.Whois(ctx.Nick)
+	ctx.ReplyN("I'm not sure who you are yet, try again in a moment.")
+	return true
+}
+
+// Run implements the help command, see help.go.
+func (cs *commandSet) Run(ctx *Context) {
+	ctx.PageN(cs.help(ctx.Text())...)
 }
 
 func (cs *commandSet) Help() string {
 	return "If you have to ask, you're beyond help."
 }
+
+func (cs *commandSet) Requires() Role {
+	return User
+}
@@ -10,6 +10,7 @@ func TestMatchCaseInsensitive(t *testing.T) {
 	// newCommandSet already adds "help"
 	cs.Add(&command{help: "remind help"}, "remind")
 	cs.Add(&command{help: "remind list help"}, "remind list")
+	cs.Add(&command{help: "act help", wholeWord: true}, "act")
 
 	tests := []struct {
 		input          string
@@ -23,10 +24,21 @@ func TestMatchCaseInsensitive(t *testing.T) {
 		{"Remind List 1", "remind list"},
 		{"help remind", "help"},
 		{"HELP remind", "help"},
+		{"reminder", "remind"},
+		{"helpless", "help"},
+		{"act #chan waves", "act"},
+		{"actually", ""},
+		{"act", "act"},
 	}
 
 	for _, test := range tests {
 		r, ln := cs.match(test.input)
+		if test.expectedPrefix == "" {
+			if r != nil {
+				t.Errorf("Input %q matched prefix of length %d", test.input, ln)
+			}
+			continue
+		}
 		if r == nil {
 			t.Errorf("Input %q failed to match", test.input)
 			continue
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+var (
+	console *bool = flag.Bool("console", false,
+		"Don't connect to any servers. Instead, read lines from stdin as if "+
+			"they were said to the bot, and print what it says to stdout.")
+	consoleNick *string = flag.String("console_nick", "dev",
+		"Nick that lines read from stdin in --console mode are from.")
+	consoleChan *string = flag.String("console_chan", "#console",
+		"Channel that lines read from stdin in --console mode are said in.")
+)
+
+// The name of the network that lines from the console come from.
+const consoleNetwork = "console"
+
+// consoleHelp is printed when the console starts.
+const consoleHelp = `Talking to %s as %s in %s. Lines are said in the channel, unless:
+  /nick <nick>   changes who you are
+  /join <#chan>  changes channel
+  /msg <text>    says text to the bot privately
+  /me <text>     does text in the channel
+Press Ctrl-D to quit.
+`
+
+// consoleTransport is the Transport for --console. It lets a developer
+// talk to the bot in a terminal, so that drivers can be tried out without
+// an IRC server. Apart from lines from the console, only CONNECTED and
+// DISCONNECTED are dispatched, when it starts and stops.
+type consoleTransport struct {
+	// Lines from the console are passed to handle.
+	handle func(Transport, *client.Line)
+	out    io.Writer
+
+	mu       sync.Mutex
+	me       string
+	nick, ch string
+
+	quit chan struct{}
+}
+
+func newConsole(out io.Writer) *consoleTransport {
+	return &consoleTransport{
+		// This is called by Init, before bot is set.
+		handle: func(t Transport, line *client.Line) {
+			bot.handlers.dispatch(t, line)
+		},
+		out:  out,
+		me:   *nick,
+		nick: *consoleNick,
+		ch:   *consoleChan,
+		quit: make(chan struct{}),
+	}
+}
+
+// run dispatches lines read from in until it runs out or shutdown is
+// called. It calls done when it stops reading from in.
+func (c *consoleTransport) run(in io.Reader, done func()) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		if err := scanner.Err(); err != nil {
+			logging.Error("Console read error: %v", err)
+		}
+	}()
+	c.mu.Lock()
+	c.printf(consoleHelp, c.me, c.nick, c.ch)
+	c.mu.Unlock()
+	c.handle(c, &client.Line{Cmd: client.CONNECTED, Time: time.Now()})
+	defer c.handle(c, &client.Line{Cmd: client.DISCONNECTED, Time: time.Now()})
+	for {
+		select {
+		case s, ok := <-lines:
+			if !ok {
+				done()
+				return
+			}
+			if line := c.line(s); line != nil {
+				c.handle(c, line)
+			}
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// line converts text typed at the console into a client.Line, or nil
+// if there's nothing to dispatch.
+func (c *consoleTransport) line(s string) *client.Line {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cmd, text := "", strings.TrimSpace(s)
+	if strings.HasPrefix(text, "/") {
+		cmd, text, _ = strings.Cut(text[1:], " ")
+		text = strings.TrimSpace(text)
+	}
+	line := &client.Line{
+		Nick: c.nick, Ident: c.nick, Host: consoleNetwork,
+		Src:  c.nick + "!" + c.nick + "@" + consoleNetwork,
+		Cmd:  client.PRIVMSG,
+		Args: []string{c.ch, text},
+		Time: time.Now(),
+	}
+	switch strings.ToLower(cmd) {
+	case "":
+	case "me":
+		line.Cmd = client.ACTION
+	case "msg":
+		line.Args[0] = c.me
+	case "nick":
+		if !isNick(text) {
+			c.printf("'%s' doesn't look like a nick.\n", text)
+			return nil
+		}
+		line.Cmd, line.Args = client.NICK, []string{text}
+		c.nick = text
+	case "join":
+		if !isChannel(text) {
+			c.printf("'%s' doesn't look like a channel.\n", text)
+			return nil
+		}
+		line.Cmd, line.Args = client.JOIN, []string{text}
+		c.ch = text
+	default:
+		c.printf("Unknown command /%s.\n", cmd)
+		return nil
+	}
+	if line.Cmd != client.JOIN && line.Cmd != client.NICK && text == "" {
+		return nil
+	}
+	return line
+}
+
+// printf writes to the console, with c.mu held.
+func (c *consoleTransport) printf(f string, args ...any) {
+	fmt.Fprintf(c.out, f, args...)
+}
+
+func (c *consoleTransport) shutdown() {
+	close(c.quit)
+}
+
+func (c *consoleTransport) Network() string {
+	return consoleNetwork
+}
+
+func (c *consoleTransport) Me() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.me
+}
+
+// Send prints text to the console, formatted like an IRC client would.
+func (c *consoleTransport) Send(cmd, target, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range strings.Split(text, "\n") {
+		switch cmd {
+		case client.ACTION:
+			c.printf("%s * %s %s\n", target, c.me, s)
+		case client.NOTICE:
+			c.printf("%s -%s- %s\n", target, c.me, s)
+		default:
+			c.printf("%s <%s> %s\n", target, c.me, s)
+		}
+	}
+}
+
+func (c *consoleTransport) Topic(ch string, topic ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.printf("%s * %s sets the topic to: %s\n", ch, c.me, strings.Join(topic, " "))
+}
+
+func (c *consoleTransport) Join(ch string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.printf("%s * %s joins\n", ch, c.me)
+}
+
+func (c *consoleTransport) Part(ch string, msg ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.printf("%s * %s leaves: %s\n", ch, c.me, strings.Join(msg, " "))
+}
+
+func (c *consoleTransport) Nick(nick string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.printf("* %s is now known as %s\n", c.me, nick)
+	c.me = nick
+}
+
+// Identity knows that nobody at the console is logged in to an account.
+func (c *consoleTransport) Identity(line *client.Line) *Identity {
+	id := lineIdentity(line)
+	id.Known = true
+	return id
+}
+
+func (c *consoleTransport) Account(nick string) (string, bool) {
+	return "", true
+}
+
+func (c *consoleTransport) Whois(nick string) {}
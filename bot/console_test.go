@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestConsole(t *testing.T) {
+	out := &strings.Builder{}
+	var got []*client.Line
+	c := &consoleTransport{
+		handle: func(_ Transport, line *client.Line) { got = append(got, line) },
+		out:    out,
+		me:     "sp0rkle",
+		nick:   "dev",
+		ch:     "#console",
+		quit:   make(chan struct{}),
+	}
+	in := strings.Join([]string{
+		"hello there",
+		"  ",
+		"/me waves",
+		"/msg psst",
+		"/nick dev2",
+		"/nick #bad",
+		"/join #other",
+		"/join other",
+		"/frob",
+		"bye",
+	}, "\n")
+	done := false
+	c.run(strings.NewReader(in), func() { done = true })
+	if !done {
+		t.Errorf("done wasn't called at the end of the input.")
+	}
+
+	// The console connects when it starts, and disconnects at the end.
+	if len(got) < 2 || got[0].Cmd != client.CONNECTED || got[len(got)-1].Cmd != client.DISCONNECTED {
+		t.Fatalf("Got %v, want CONNECTED first and DISCONNECTED last.", got)
+	}
+	got = got[1 : len(got)-1]
+	want := []struct {
+		nick, cmd string
+		args      []string
+	}{
+		{"dev", client.PRIVMSG, []string{"#console", "hello there"}},
+		{"dev", client.ACTION, []string{"#console", "waves"}},
+		{"dev", client.PRIVMSG, []string{"sp0rkle", "psst"}},
+		{"dev", client.NICK, []string{"dev2"}},
+		{"dev2", client.JOIN, []string{"#other"}},
+		{"dev2", client.PRIVMSG, []string{"#other", "bye"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got %d lines, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		l := got[i]
+		if l.Nick != w.nick || l.Src != w.nick+"!"+w.nick+"@console" ||
+			l.Cmd != w.cmd || !reflect.DeepEqual(l.Args, w.args) {
+			t.Errorf("Line %d = %+v, want %s %s %q", i, l, w.nick, w.cmd, w.args)
+		}
+	}
+	if id := c.Identity(got[0]); !id.Known || id.Account != "" || id.Mask() != "dev!dev@console" {
+		t.Errorf("Identity() = %+v", id)
+	}
+
+	out.Reset()
+	c.Send(client.PRIVMSG, "#console", "one\ntwo")
+	c.Send(client.ACTION, "#console", "waves")
+	c.Send(client.NOTICE, "dev", "psst")
+	c.Nick("sp0rklf")
+	c.Send(client.PRIVMSG, "#console", "hi")
+	wantOut := "#console <sp0rkle> one\n#console <sp0rkle> two\n" +
+		"#console * sp0rkle waves\ndev -sp0rkle- psst\n" +
+		"* sp0rkle is now known as sp0rklf\n#console <sp0rklf> hi\n"
+	if out.String() != wantOut {
+		t.Errorf("Console output:\n%s\nwant:\n%s", out, wantOut)
+	}
+}
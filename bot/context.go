@@ -3,6 +3,7 @@ package bot
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/util"
@@ -21,23 +22,44 @@ func (c Chan) Lower() string {
 	return strings.ToLower(string(c))
 }
 
+// IsChannel is false for the nicks that private messages are sent to.
+func (c Chan) IsChannel() bool {
+	return isChannel(string(c))
+}
+
 // context encapsulates the bot's stuff and provides an interface
 // for the rest of the bot to interact with IRC.
 type Context struct {
 	*client.Line
 	Addressed bool
 
-	conn *client.Conn
-	rws  RewriteSet
+	t   Transport
+	rws RewriteSet
+	// Values of a command's arguments, if it has an argument spec.
+	args map[string]any
 }
 
+// reqContext returns a Context for a line from an IRC server.
 func reqContext(conn *client.Conn, line *client.Line) *Context {
-	ctx := &Context{conn: conn, Line: line.Copy(), rws: bot.rewriters}
+	ctx := newContext(bot.servers.Transport(conn), line)
+	// With server-time, this is when the server got the line, not us.
+	ctx.Time = serverTime(line)
+	bot.accounts.fromTags(conn, line)
+	return ctx
+}
+
+func newContext(t Transport, line *client.Line) *Context {
+	ctx := &Context{t: t, Line: line.Copy(), rws: bot.rewriters}
 	if ctx.Cmd != client.PRIVMSG {
 		return ctx
 	}
 	ctx.Args[1], ctx.Addressed = util.RemovePrefixedNick(
 		strings.TrimSpace(ctx.Args[1]), ctx.Me())
+	if !ctx.Addressed {
+		// Lines in channels can also be addressed to us with a trigger.
+		ctx.Args[1], ctx.Addressed = removeTrigger(
+			ctx.Args[1], bot.triggers.For(ctx.Args[0]))
+	}
 	// If we're being talked to in private, line.Args[0] will contain our Nick.
 	// We should consider this as "addressing" us, and set Addressed = true
 	if ctx.Args[0] == ctx.Me() {
@@ -46,6 +68,61 @@ func reqContext(conn *client.Conn, line *client.Line) *Context {
 	return ctx
 }
 
+// Identity resolves the sender of the line to what we know about them.
+func (ctx *Context) Identity() *Identity {
+	return ctx.t.Identity(ctx.Line)
+}
+
+// Role returns the role that the sender of the line has been granted.
+func (ctx *Context) Role() Role {
+	id := ctx.Identity()
+	if isRebuilder(id) {
+		return Owner
+	}
+	return bot.acl.Role(id)
+}
+
+// Can returns true if the sender of the line has at least role r.
+func (ctx *Context) Can(r Role) bool {
+	return r == User || ctx.Role() >= r
+}
+
+// Tag returns the value of an IRCv3 message tag on the line. Lines only
+// carry tags if the server supports the relevant capability, and
+// valueless tags are present with an empty value.
+func (ctx *Context) Tag(key string) (string, bool) {
+	v, ok := ctx.Tags[key]
+	return v, ok
+}
+
+// Arg returns the value of a command's string, nick or channel argument,
+// or "" if an optional argument was not given.
+func (ctx *Context) Arg(name string) string {
+	s, _ := ctx.args[name].(string)
+	return s
+}
+
+func (ctx *Context) ArgInt(name string) int {
+	i, _ := ctx.args[name].(int)
+	return i
+}
+
+func (ctx *Context) ArgDuration(name string) time.Duration {
+	d, _ := ctx.args[name].(time.Duration)
+	return d
+}
+
+// HasArg returns true if an optional argument was given, or has a default.
+func (ctx *Context) HasArg(name string) bool {
+	_, ok := ctx.args[name]
+	return ok
+}
+
+// Network returns the name of the network the line came from.
+func (ctx *Context) Network() string {
+	return ctx.t.Network()
+}
+
 func (ctx *Context) Storable() (Nick, Chan) {
 	return Nick(ctx.Nick), Chan(ctx.Args[0])
 }
@@ -58,27 +135,33 @@ func (ctx *Context) ReplyN(fm string, args ...any) {
 
 // whereas Reply() does not.
 func (ctx *Context) Reply(fm string, args ...any) {
-	ctx.conn.Privmsg(ctx.Target(),
+	ctx.send(client.PRIVMSG, ctx.Target(),
 		ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
 }
 
 func (ctx *Context) Do(fm string, args ...any) {
-	ctx.conn.Action(ctx.Target(),
+	ctx.send(client.ACTION, ctx.Target(),
 		ctx.rws.Rewrite(fmt.Sprintf(fm, args...), ctx))
 }
 
 func (ctx *Context) Privmsg(ch, text string) {
-	ctx.conn.Privmsg(ch, text)
+	ctx.send(client.PRIVMSG, ch, text)
 }
 
 func (ctx *Context) Action(ch, text string) {
-	ctx.conn.Action(ch, text)
+	ctx.send(client.ACTION, ch, text)
+}
+
+// send queues text to be sent to target, rate limited and split into
+// lines short enough for IRC.
+func (ctx *Context) send(cmd, target, text string) {
+	ctx.t.Send(cmd, target, text)
 }
 
 func (ctx *Context) Topic(ch string, topic ...string) {
-	ctx.conn.Topic(ch, topic...)
+	ctx.t.Topic(ch, topic...)
 }
 
 func (ctx *Context) Me() string {
-	return ctx.conn.Me().Nick
+	return ctx.t.Me()
 }
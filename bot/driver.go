@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+const (
+	driversNs = "drivers"
+	// Values stored in driversNs. These override the config file's
+	// disabled_drivers, and drivers are otherwise enabled by default.
+	driverDisabled = "disabled"
+	driverEnabled  = "enabled"
+)
+
+// A Driver registers handlers and commands on behalf of one of the bot's
+// drivers, so that they can be disabled in individual channels.
+type Driver struct {
+	name string
+}
+
+// NewDriver returns a Driver that registers things under name.
+func NewDriver(name string) *Driver {
+	d := &Driver{name: strings.ToLower(name)}
+	lock.Lock()
+	defer lock.Unlock()
+	bot.drivers[d.name] = d
+	return d
+}
+
+func (d *Driver) Name() string {
+	return d.name
+}
+
+// Driver implements LineFilter, rejecting lines from channels the driver
+// has been disabled in.
+func (d *Driver) ShouldProcess(line *client.Line) bool {
+	return bot.policy.Enabled(d.name, line.Target())
+}
+
+func (d *Driver) Handle(fn HandlerFunc, events ...string) {
+	for _, ev := range events {
+		bot.servers.HandleAll(ev, filteredHandler{d, fn})
+		bot.handlers.add(ev, filteredHandler{d, fn}, false)
+	}
+}
+
+func (d *Driver) HandleBG(fn HandlerFunc, events ...string) {
+	for _, ev := range events {
+		bot.servers.HandleAllBG(ev, filteredHandler{d, fn})
+		bot.handlers.add(ev, filteredHandler{d, fn}, true)
+	}
+}
+
+func (d *Driver) Command(fn HandlerFunc, prefix, help string, opts ...CommandOpt) {
+	Command(fn, prefix, help, append(opts, inDriver(d))...)
+}
+
+// Suggest is like bot.Suggest, but fn is only asked about lines in
+// channels where the driver is enabled.
+func (d *Driver) Suggest(fn SuggestFunc) {
+	Suggest(func(ctx *Context) (bool, []string) {
+		if !d.ShouldProcess(ctx.Line) {
+			return false, nil
+		}
+		return fn(ctx)
+	})
+}
+
+// Rewrite is like bot.Rewrite, but fn only rewrites replies to lines
+// from channels where the driver is enabled.
+func (d *Driver) Rewrite(fn RewriteFunc) {
+	Rewrite(func(in string, ctx *Context) string {
+		if !d.ShouldProcess(ctx.Line) {
+			return in
+		}
+		return fn(in, ctx)
+	})
+}
+
+// filteredHandler only passes lines on to its handler if they pass lf.
+type filteredHandler struct {
+	lf LineFilter
+	h  HandlerFunc
+}
+
+func (fh filteredHandler) Handle(conn *client.Conn, line *client.Line) {
+	if fh.lf.ShouldProcess(line) {
+		fh.h.Handle(conn, line)
+	}
+}
+
+func (fh filteredHandler) handleLine(t Transport, line *client.Line) {
+	if fh.lf.ShouldProcess(line) {
+		fh.h.handleLine(t, line)
+	}
+}
+
+// isDriver returns true if name has been registered with NewDriver.
+func isDriver(name string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	_, ok := bot.drivers[strings.ToLower(name)]
+	return ok
+}
+
+func driverNames() []string {
+	lock.Lock()
+	defer lock.Unlock()
+	names := make([]string, 0, len(bot.drivers))
+	for name := range bot.drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// driverPolicy persists the drivers that have been enabled or disabled
+// in each channel, keyed on "#chan driver".
+type driverPolicy struct {
+	ns conf.Namespace
+}
+
+func policyKey(driver, ch string) string {
+	return strings.ToLower(ch) + " " + strings.ToLower(driver)
+}
+
+// Enabled returns true unless driver has been disabled in ch. Drivers
+// can't be disabled in private messages.
+func (p *driverPolicy) Enabled(driver, ch string) bool {
+	if p == nil || !isChannel(ch) {
+		return true
+	}
+	switch p.ns.String(policyKey(driver, ch)) {
+	case driverDisabled:
+		return false
+	case driverEnabled:
+		return true
+	}
+	for _, d := range currentConfig().DisabledDrivers[strings.ToLower(ch)] {
+		if d == strings.ToLower(driver) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *driverPolicy) Disable(driver, ch string) {
+	p.ns.String(policyKey(driver, ch), driverDisabled)
+}
+
+func (p *driverPolicy) Enable(driver, ch string) {
+	p.ns.String(policyKey(driver, ch), driverEnabled)
+}
+
+// Disabled returns the sorted list of drivers disabled in ch.
+func (p *driverPolicy) Disabled(ch string) []string {
+	if p == nil {
+		return nil
+	}
+	ch = strings.ToLower(ch)
+	set := map[string]bool{}
+	for _, d := range currentConfig().DisabledDrivers[ch] {
+		set[d] = true
+	}
+	prefix := ch + " "
+	for _, e := range p.ns.All() {
+		if strings.HasPrefix(e.Key, prefix) {
+			set[e.Key[len(prefix):]] = e.Value == driverDisabled
+		}
+	}
+	drivers := []string{}
+	for _, d := range sortedKeys(set) {
+		if set[d] {
+			drivers = append(drivers, d)
+		}
+	}
+	return drivers
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestDriverPolicy(t *testing.T) {
+	p := &driverPolicy{ns: conf.InMem(driversNs)}
+
+	p.Disable("karma", "#Serious")
+	p.Disable("fact", "#serious")
+	p.Disable("url", "#other")
+
+	tests := []struct {
+		driver, ch string
+		want       bool
+	}{
+		{"karma", "#serious", false},
+		{"KARMA", "#SERIOUS", false},
+		{"fact", "#serious", false},
+		{"url", "#serious", true},
+		{"karma", "#other", true},
+		{"url", "#other", false},
+		// Private messages are never filtered.
+		{"karma", "somenick", true},
+		{"karma", "", true},
+	}
+	for _, test := range tests {
+		if got := p.Enabled(test.driver, test.ch); got != test.want {
+			t.Errorf("Enabled(%q, %q) = %t, want %t",
+				test.driver, test.ch, got, test.want)
+		}
+	}
+
+	if got, want := p.Disabled("#serious"), []string{"fact", "karma"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Disabled(\"#serious\") = %q, want %q", got, want)
+	}
+	p.Enable("karma", "#serious")
+	if !p.Enabled("karma", "#serious") {
+		t.Errorf("karma still disabled in #serious after Enable.")
+	}
+	if got, want := p.Disabled("#serious"), []string{"fact"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Disabled(\"#serious\") = %q, want %q", got, want)
+	}
+
+	var nilPolicy *driverPolicy
+	if !nilPolicy.Enabled("karma", "#serious") {
+		t.Errorf("nil policy disabled a driver.")
+	}
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/errlog"
+	"github.com/fluffle/sp0rkle/util"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// Where the error log is served.
+const errorsPath = "/errors"
+
+// Catch, log, and apologise for panics in handlers.
+func unfail(conn *client.Conn, line *client.Line) {
+	if err := recover(); err != nil {
+		reportError(bot.servers.Transport(conn), line, panicError(err))
+	}
+}
+
+// panicError describes a panic and where it happened. It must be called
+// by the function that recovered from the panic.
+func panicError(err any) *errlog.Error {
+	// Depth 6 is where our code usually starts.
+	// But if the panic is somewhere in the depths of the standard
+	// library or dependency code it's helpful to know what
+	// our code was up to at the time too, so walk up the stack.
+	return errlog.New(fmt.Sprintf("%T", err), fmt.Sprintf("panic: %v", err),
+		stack(6))
+}
+
+// stack describes the frames of the calling goroutine's stack, skipping
+// skip frames like runtime.Callers.
+func stack(skip int) []string {
+	callers := make([]uintptr, 20)
+	n := runtime.Callers(skip, callers)
+	frames := runtime.CallersFrames(callers[:n])
+	s := []string{}
+	for frame, ok := frames.Next(); ok; frame, ok = frames.Next() {
+		s = append(s, fmt.Sprintf("%s at %s:%d",
+			trimPath(frame.Function), trimPath(frame.File), frame.Line))
+	}
+	return s
+}
+
+func trimPath(path string) string {
+	prefixes := []string{"sp0rkle/", "fluffle/", runtime.GOROOT() + "/src/"}
+	for _, prefix := range prefixes {
+		trimmed, ok := trim(path, prefix)
+		if ok {
+			return trimmed
+		}
+	}
+	return path
+}
+
+func trim(path string, prefix string) (string, bool) {
+	i := strings.Index(path, prefix)
+	if i < 0 {
+		return path, false
+	}
+	return path[i+len(prefix):], true
+}
+
+// reportError logs and records e as having been caused by line, and
+// apologises to wherever line came from. The details are left for admins
+// to look up, rather than being spewed into the channel.
+func reportError(t Transport, line *client.Line, e *errlog.Error) {
+	if line != nil {
+		e.Network, e.Target, e.Nick, e.Text =
+			t.Network(), line.Target(), line.Nick, line.Text()
+	}
+	logging.Error("Error %s: %s, frames: %s", e.Id, e.Message,
+		strings.Join(e.Stack, ", "))
+	if bot != nil && bot.errors != nil {
+		e = bot.errors.Record(e, time.Now())
+	}
+	if line != nil && line.Target() != "" {
+		t.Send(client.PRIVMSG, line.Target(), fmt.Sprintf("Sorry, something "+
+			"went wrong. It has been recorded as error %s.", e.Id))
+	}
+}
+
+// ReportError is for handlers that can't carry on because of err. Like
+// a panic, it is recorded for admins to look at, and the line's sender
+// gets an apology.
+func (ctx *Context) ReportError(err error) {
+	// Skip runtime.Callers, stack and ReportError.
+	reportError(ctx.t, ctx.Line, errlog.New(fmt.Sprintf("%T", err),
+		err.Error(), stack(3)))
+}
+
+func listErrors(ctx *Context) {
+	errs := bot.errors.Recent()
+	if len(errs) == 0 {
+		ctx.ReplyN("No errors have been recorded.")
+		return
+	}
+	lines := []string{fmt.Sprintf("%d errors have been recorded:", len(errs))}
+	for _, e := range errs {
+		lines = append(lines, fmt.Sprintf("%s: %s (%d times, last %s ago)",
+			e.Id, e.Message, e.Count, util.TimeSince(e.LastSeen)))
+	}
+	ctx.PageN(lines...)
+}
+
+func showError(ctx *Context) {
+	e := bot.errors.ById(ctx.Arg("id"))
+	if e == nil {
+		ctx.ReplyN("There's no error with ID '%s'.", ctx.Arg("id"))
+		return
+	}
+	lines := []string{
+		fmt.Sprintf("%s: %s", e.Id, e.Message),
+		fmt.Sprintf("Seen %d times, first at %s, last at %s.", e.Count,
+			datetime.Format(e.FirstSeen), datetime.Format(e.LastSeen)),
+	}
+	switch {
+	case isChannel(e.Target):
+		lines = append(lines, fmt.Sprintf("Last caused by %s in %s on %s: %s",
+			e.Nick, e.Target, e.Network, e.Text))
+	case e.Target != "":
+		// Whoever is asking may be in a channel, so keep PMs private.
+		lines = append(lines, fmt.Sprintf("Last caused by a private message on %s.",
+			e.Network))
+	}
+	for i, frame := range e.Stack {
+		lines = append(lines, fmt.Sprintf("(%d) %s", i, frame))
+	}
+	ctx.PageN(lines...)
+}
+
+func forgetError(ctx *Context) {
+	e := bot.errors.ById(ctx.Arg("id"))
+	if e == nil {
+		ctx.ReplyN("There's no error with ID '%s'.", ctx.Arg("id"))
+		return
+	}
+	bot.errors.Forget(e.Id)
+	ctx.ReplyN("Forgot error %s.", e.Id)
+}
+
+var errorsTmpl = template.Must(template.New("errors").Parse(`<html>
+<head>
+  <title>sp0rkle's errors</title>
+</head>
+<body>
+  <h1>sp0rkle's errors</h1>
+{{ range . }}
+  <h2 id="{{ .Id }}">{{ .Id }}: {{ .Message }}</h2>
+  <p>Seen {{ .Count }} times, first at {{ .FirstSeen }}, last at {{ .LastSeen }}.</p>
+  <ol start="0">
+{{ range .Stack }}
+    <li><code>{{ . }}</code></li>
+{{ end }}
+  </ol>
+{{ else }}
+  <p>No errors have been recorded.</p>
+{{ end }}
+</body>
+</html>`))
+
+// errorsHTTP lists the recorded errors. What caused them isn't shown,
+// as that could be from a private message.
+func errorsHTTP(rw http.ResponseWriter, req *http.Request) {
+	var errs []*errlog.Error
+	if bot != nil && bot.errors != nil {
+		errs = bot.errors.Recent()
+	}
+	if err := errorsTmpl.Execute(rw, errs); err != nil {
+		logging.Error("Template execution failed: %v", err)
+	}
+}
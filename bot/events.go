@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+// An Event is something that happened in one driver that others might
+// care about. Drivers Publish events and Subscribe to them, rather than
+// reaching into each other's collections. Events are published as values
+// of the types below, not pointers.
+type Event interface {
+	// String describes the event, for logs and feeds.
+	String() string
+}
+
+// FactoidAdded is published when someone teaches the bot a factoid.
+type FactoidAdded struct {
+	Key, Value string
+	Nick       Nick
+	Chan       Chan
+}
+
+func (e FactoidAdded) String() string {
+	return fmt.Sprintf("%s taught me that '%s' is '%s' in %s", e.Nick, e.Key, e.Value, e.Chan)
+}
+
+// FactoidEdited is published when someone changes a factoid's value.
+type FactoidEdited struct {
+	Key, Old, Value string
+	Nick            Nick
+	Chan            Chan
+}
+
+func (e FactoidEdited) String() string {
+	return fmt.Sprintf("%s changed '%s' from '%s' to '%s' in %s",
+		e.Nick, e.Key, e.Old, e.Value, e.Chan)
+}
+
+// FactoidDeleted is published when someone makes the bot forget a factoid.
+type FactoidDeleted struct {
+	Key, Value string
+	Nick       Nick
+	Chan       Chan
+}
+
+func (e FactoidDeleted) String() string {
+	return fmt.Sprintf("%s made me forget that '%s' was '%s' in %s",
+		e.Nick, e.Key, e.Value, e.Chan)
+}
+
+// QuoteAdded is published when someone adds a quote.
+type QuoteAdded struct {
+	QID   int
+	Quote string
+	Nick  Nick
+	Chan  Chan
+}
+
+func (e QuoteAdded) String() string {
+	return fmt.Sprintf("%s added quote #%d in %s: %s", e.Nick, e.QID, e.Chan, e.Quote)
+}
+
+// ReminderFired is published when a reminder is due and has been
+// delivered, or kept as a tell if its target wasn't around.
+type ReminderFired struct {
+	Source, Target Nick
+	Chan           Chan
+	// What the target was reminded about, and the full text of the reminder.
+	Text, Reply string
+}
+
+func (e ReminderFired) String() string {
+	return fmt.Sprintf("reminded %s %s", e.Target, e.Text)
+}
+
+// TellStored is published when someone leaves a message for a nick.
+type TellStored struct {
+	Source, Target Nick
+	Chan           Chan
+	Text           string
+}
+
+func (e TellStored) String() string {
+	return fmt.Sprintf("%s in %s left a message for %s: %s", e.Source, e.Chan, e.Target, e.Text)
+}
+
+// TellRequested asks whatever looks after tells to leave a message for
+// Target from Source, to be delivered when they next speak.
+type TellRequested struct {
+	Source, Target Nick
+	Text           string
+}
+
+func (e TellRequested) String() string {
+	return fmt.Sprintf("%s wants to tell %s %s", e.Source, e.Target, e.Text)
+}
+
+// UrlSeen is published the first time someone mentions a URL.
+type UrlSeen struct {
+	Url  string
+	Nick Nick
+	Chan Chan
+}
+
+func (e UrlSeen) String() string {
+	return fmt.Sprintf("%s mentioned %s in %s", e.Nick, e.Url, e.Chan)
+}
+
+// KarmaChanged is published when someone gives something karma.
+type KarmaChanged struct {
+	Subject string
+	Score   int
+	Nick    Nick
+	Chan    Chan
+}
+
+func (e KarmaChanged) String() string {
+	return fmt.Sprintf("%s changed the karma of '%s' to %d in %s",
+		e.Nick, e.Subject, e.Score, e.Chan)
+}
+
+// NickSeen is published when the seen driver records what a nick did.
+type NickSeen struct {
+	Nick   Nick
+	Chan   Chan
+	Action string
+	Text   string
+}
+
+func (e NickSeen) String() string {
+	return fmt.Sprintf("%s was seen doing %s in %s: %s", e.Nick, e.Action, e.Chan, e.Text)
+}
+
+// EventName returns the name of ev's type, e.g. "FactoidAdded".
+func EventName(ev Event) string {
+	return reflect.TypeOf(ev).Name()
+}
+
+var eventsPublished = metrics.NewCounter("sp0rkle_events_total",
+	"Events published, by type.", "event")
+
+// eventBus keeps track of what is subscribed to each type of event.
+type eventBus struct {
+	sync.RWMutex
+	subs map[reflect.Type][]func(Event)
+	// Subscribed to every event.
+	all []func(Event)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[reflect.Type][]func(Event))}
+}
+
+func (eb *eventBus) subscribe(t reflect.Type, fn func(Event)) {
+	eb.Lock()
+	defer eb.Unlock()
+	if t == nil {
+		eb.all = append(eb.all, fn)
+		return
+	}
+	eb.subs[t] = append(eb.subs[t], fn)
+}
+
+func (eb *eventBus) subscribers(ev Event) []func(Event) {
+	eb.RLock()
+	defer eb.RUnlock()
+	t := reflect.TypeOf(ev)
+	fns := make([]func(Event), 0, len(eb.subs[t])+len(eb.all))
+	return append(append(fns, eb.subs[t]...), eb.all...)
+}
+
+// Subscribe calls fn with each event of type E that is published. Each
+// call is in its own goroutine, so events may arrive out of order.
+func Subscribe[E Event](fn func(E)) {
+	bot.events.subscribe(reflect.TypeFor[E](), func(ev Event) {
+		fn(ev.(E))
+	})
+}
+
+// SubscribeAll calls fn with every event that is published, e.g. to log
+// them or make them into a feed.
+func SubscribeAll(fn func(Event)) {
+	bot.events.subscribe(nil, fn)
+}
+
+// Publish sends ev to everything subscribed to it. It doesn't wait for
+// them to deal with it.
+func Publish(ev Event) {
+	eventsPublished.Inc(EventName(ev))
+	logging.Debug("Event: %s", ev)
+	for _, fn := range bot.events.subscribers(ev) {
+		Go(func() { notify(fn, ev) })
+	}
+}
+
+// notify calls fn with ev, recording any panic like handlers do.
+func notify(fn func(Event), ev Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			reportError(nil, nil, panicError(err))
+		}
+	}()
+	fn(ev)
+}
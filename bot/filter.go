@@ -2,6 +2,7 @@ package bot
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/sp0rkle/collections/conf"
@@ -17,34 +18,115 @@ func (lf LineFilterFunc) ShouldProcess(line *client.Line) bool {
 	return lf(line)
 }
 
+// nickIgnoreFilter ignores lines from nicks and masks given to the
+// "ignore" command, and in the config file. It isn't a LineFilter, as masks
+// are matched against the sender's Identity, which needs the Transport.
 type nickIgnoreFilter struct {
 	ns conf.Namespace
+
+	// The ignores from the command, which refresh makes us read again.
+	mu      sync.RWMutex
+	ignores []string
+	stale   bool
+}
+
+func newNickIgnoreFilter(ns conf.Namespace) *nickIgnoreFilter {
+	return &nickIgnoreFilter{ns: ns, stale: true}
 }
 
-func (nf nickIgnoreFilter) ShouldProcess(line *client.Line) bool {
+// refresh is called when the ignores have been changed.
+func (nf *nickIgnoreFilter) refresh() {
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+	nf.stale = true
+}
+
+func (nf *nickIgnoreFilter) cached() []string {
+	nf.mu.RLock()
+	ignores, stale := nf.ignores, nf.stale
+	nf.mu.RUnlock()
+	if !stale {
+		return ignores
+	}
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+	if nf.stale {
+		nf.ignores = nil
+		for _, e := range nf.ns.All() {
+			nf.ignores = append(nf.ignores, e.Key)
+		}
+		nf.stale = false
+	}
+	return nf.ignores
+}
+
+// ignored returns true if line is from someone being ignored. Ignores may
+// be bare nicks, or masks matched against the sender's Identity, so that
+// $a:account masks work whether or not the line has an account tag.
+func (nf *nickIgnoreFilter) ignored(t Transport, line *client.Line) bool {
 	if line.Nick == "" {
-		return true
+		return false
 	}
-	return nf.ns.String(strings.ToLower(line.Nick)) == ""
+	nick := strings.ToLower(line.Nick)
+	var id *Identity
+	matches := func(s string) bool {
+		if s == nick {
+			return true
+		}
+		if !IsMask(s) {
+			return false
+		}
+		if id == nil {
+			id = t.Identity(line)
+		}
+		return id.Matches(s)
+	}
+	for _, s := range nf.cached() {
+		if matches(s) {
+			return true
+		}
+	}
+	for _, s := range currentConfig().Ignore {
+		if matches(s) {
+			return true
+		}
+	}
+	return false
 }
 
 type FilterPipeline struct {
+	// Handlers may still be running when the filters are reset.
+	mu      sync.RWMutex
 	filters []LineFilter
 }
 
 func (p *FilterPipeline) Add(lf ...LineFilter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.filters = append(p.filters, lf...)
 }
 
 func (p *FilterPipeline) AddFunc(lf ...LineFilterFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	// Can't type-cast []LineFilterFunc to []LineFilter, rip.
 	for _, f := range lf {
 		p.filters = append(p.filters, f)
 	}
 }
 
+// Reset removes all the filters.
+func (p *FilterPipeline) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filters = nil
+}
+
 func (p *FilterPipeline) ShouldProcess(line *client.Line) bool {
-	for _, f := range p.filters {
+	p.mu.RLock()
+	filters := p.filters
+	p.mu.RUnlock()
+	for _, f := range filters {
 		if !f.ShouldProcess(line) {
 			return false
 		}
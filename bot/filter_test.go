@@ -42,12 +42,17 @@ func TestNickIgnoreFilter(t *testing.T) {
 	ns := conf.InMem(ignoreNs)
 	// Ignore the nick "ignored" for testing purposes.
 	ns.String("ignored", "ignored")
-	p := &FilterPipeline{}
-	p.Add(&nickIgnoreFilter{ns: ns})
+	ns.String("*!*@spammer.example.com", "ignore")
+	ns.String("$a:troll", "ignore")
+	nf := newNickIgnoreFilter(ns)
+	// The transport knows some accounts without them being in tags.
+	tr := accountTransport{accts: map[string]string{"lurker": "troll"}}
 
 	tests := []struct {
 		name      string
 		nick      string
+		host      string
+		account   string
 		want      bool
 	}{
 		{
@@ -70,15 +75,74 @@ func TestNickIgnoreFilter(t *testing.T) {
 			nick:     "",
 			want:     true,
 		},
+		{
+			name:     "ignore message from ignored hostmask",
+			nick:     "newnick",
+			host:     "spammer.example.com",
+			want:     false,
+		},
+		{
+			name:     "ignore message from ignored account",
+			nick:     "othernick",
+			account:  "troll",
+			want:     false,
+		},
+		{
+			name:     "permit message from other account",
+			nick:     "othernick",
+			account:  "nottroll",
+			want:     true,
+		},
+		{
+			name:     "ignore message from account looked up",
+			nick:     "lurker",
+			want:     false,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			line := &client.Line{Nick: test.nick}
-			got := p.ShouldProcess(line)
+			line := &client.Line{Nick: test.nick, Host: test.host}
+			if test.account != "" {
+				line.Tags = map[string]string{"account": test.account}
+			}
+			got := !nf.ignored(tr, line)
 			if got != test.want {
 				t.Errorf("NamespaceFilter(%q) = %t, want %t", test.nick, got, test.want)
 			}
 		})
 	}
 }
+
+// accountTransport knows the accounts of accts' nicks, like the accounts
+// that ircTransport has looked up.
+type accountTransport struct {
+	Transport
+	accts map[string]string
+}
+
+func (at accountTransport) Identity(line *client.Line) *Identity {
+	id := lineIdentity(line)
+	if acct, ok := at.accts[line.Nick]; ok && !id.Known {
+		id.Account, id.Known = acct, true
+	}
+	return id
+}
+
+func TestNickIgnoreFilterRefresh(t *testing.T) {
+	ns := conf.InMem(ignoreNs)
+	nf := newNickIgnoreFilter(ns)
+	line := &client.Line{Nick: "pest"}
+	if nf.ignored(accountTransport{}, line) {
+		t.Errorf("pest ignored before being added.")
+	}
+	// Ignores are cached until they're refreshed.
+	ns.String("pest", "ignore")
+	if nf.ignored(accountTransport{}, line) {
+		t.Errorf("pest ignored before refresh.")
+	}
+	nf.refresh()
+	if !nf.ignored(accountTransport{}, line) {
+		t.Errorf("pest not ignored after refresh.")
+	}
+}
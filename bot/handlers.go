@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 )
 
@@ -13,31 +14,29 @@ var (
 	channels *string = flag.String("channels", "#sp0rklf",
 		"Comma-separated list of channels to join.")
 	rebuilder *string = flag.String("rebuilder", "",
-		"Nick[:password] to accept rebuild command from.")
+		"Mask[:password] to accept rebuild command from. Mask is a services "+
+			"account name, $a:account, or nick!user@host glob.")
 	oper *string = flag.String("oper", "",
 		"user:password for server OPER command on connect, or $ENV_VAR or <file_path to secret.")
 	vhost *string = flag.String("vhost", "",
 		"user:password for server VHOST command on connect, or $ENV_VAR or <file_path to secret.")
 )
 
-func connected(ctx *Context) {
+// connected sets up IRC servers when we connect to them. Other
+// transports join channels themselves.
+func connected(conn *client.Conn, line *client.Line) {
 	// Set bot mode to keep people informed.
-	ctx.conn.Mode(ctx.Me(), "+B")
-	if GetSecret(*oper) != "" {
-		up := strings.SplitN(*oper, ":", 2)
-		if len(up) == 2 {
-			ctx.conn.Oper(up[0], up[1])
-		}
+	conn.Mode(bot.servers.Transport(conn).Me(), "+B")
+	n := bot.servers.Network(conn)
+	if user, pass, ok := splitSecret(n.Oper); ok {
+		conn.Oper(user, pass)
 	}
-	if GetSecret(*vhost) != "" {
-		up := strings.SplitN(*vhost, ":", 2)
-		if len(up) == 2 {
-			ctx.conn.VHost(up[0], up[1])
-		}
+	if user, pass, ok := splitSecret(n.VHost); ok {
+		conn.VHost(user, pass)
 	}
-	for _, c := range strings.Split(*channels, ",") {
-		logging.Info("Joining %s on startup.\n", c)
-		ctx.conn.Join(c)
+	for _, c := range channelsFor(n.Name).Channels(n.Channels) {
+		logging.Info("Joining %s on %s on startup.\n", c, n.Name)
+		conn.Join(c)
 	}
 }
 
@@ -48,15 +47,13 @@ func rebuild(ctx *Context) {
 
 	// Ok, we should be good to rebuild now.
 	logging.Info("Beginning rebuild")
-	ctx.conn.Notice(ctx.Nick, "Beginning rebuild")
+	ctx.send(client.NOTICE, ctx.Nick, "Beginning rebuild")
 	cmd := exec.Command("go", "get", "-u", "github.com/fluffle/sp0rkle")
 	out, err := cmd.CombinedOutput()
 	logging.Info("Output from go get:\n%s", out)
 	if err != nil {
-		ctx.conn.Notice(ctx.Nick, fmt.Sprintf("Rebuild failed: %s", err))
-		for _, l := range strings.Split(string(out), "\n") {
-			ctx.conn.Notice(ctx.Nick, l)
-		}
+		ctx.send(client.NOTICE, ctx.Nick, fmt.Sprintf("Rebuild failed: %s", err))
+		ctx.send(client.NOTICE, ctx.Nick, string(out))
 		return
 	}
 	bot.servers.Shutdown(true)
@@ -68,13 +65,33 @@ func shutdown(ctx *Context) {
 	}
 }
 
+// Splits --rebuilder into a mask and optional password.
+// The $a: account prefix contains a colon, so skip over it.
+func rebuilderMask() (mask, pass string) {
+	s := GetSecret(*rebuilder)
+	skip := 0
+	if strings.HasPrefix(s, accountPrefix) {
+		skip = len(accountPrefix)
+	}
+	if idx := strings.Index(s[skip:], ":"); idx != -1 {
+		return s[:skip+idx], s[skip+idx+1:]
+	}
+	return s, ""
+}
+
+// The rebuilder is implicitly granted the Owner role.
+func isRebuilder(id *Identity) bool {
+	mask, _ := rebuilderMask()
+	return mask != "" && id.Matches(mask)
+}
+
 func check_rebuilder(cmd string, ctx *Context) bool {
-	s := strings.Split(GetSecret(*rebuilder), ":")
-	if s[0] == "" || s[0] != ctx.Nick || !strings.HasPrefix(strings.ToLower(ctx.Text()), cmd) {
+	_, pass := rebuilderMask()
+	if !isRebuilder(ctx.Identity()) || !strings.HasPrefix(strings.ToLower(ctx.Text()), cmd) {
 		return false
 	}
 	fields := strings.Fields(ctx.Text())
-	if len(s) > 1 && fields[len(fields)-1] != s[1] {
+	if pass != "" && fields[len(fields)-1] != pass {
 		return false
 	}
 	return true
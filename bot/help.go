@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fluffle/golog/logging"
+)
+
+const (
+	// Where the list of every command is served.
+	helpPath = "/help"
+	// The category of commands that don't belong to a driver.
+	botCategory = "bot"
+)
+
+// usage splits a command's help into how to use it and what it does.
+// Help for commands without an argument spec is conventionally written
+// as "usage  -- description".
+func (c *command) usage() (string, string) {
+	if c.args != nil {
+		return c.prefix + " " + argsUsage(c.args), c.help
+	}
+	if u, d, ok := strings.Cut(c.help, " -- "); ok {
+		return strings.TrimSpace(u), strings.TrimSpace(d)
+	}
+	return c.prefix, c.help
+}
+
+// commands returns the registered commands, sorted by category and prefix.
+func (cs *commandSet) commands() []*command {
+	cs.RLock()
+	defer cs.RUnlock()
+	cmds := []*command{}
+	for _, r := range cs.set {
+		if c, ok := r.(*command); ok {
+			cmds = append(cmds, c)
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		if cmds[i].category != cmds[j].category {
+			return cmds[i].category < cmds[j].category
+		}
+		return cmds[i].prefix < cmds[j].prefix
+	})
+	return cmds
+}
+
+// categories returns the command categories in order, and the prefixes
+// of the commands in each.
+func (cs *commandSet) categories() ([]string, map[string][]string) {
+	names, prefixes := []string{}, map[string][]string{}
+	for _, c := range cs.commands() {
+		if _, ok := prefixes[c.category]; !ok {
+			names = append(names, c.category)
+		}
+		prefixes[c.category] = append(prefixes[c.category], c.prefix)
+	}
+	return names, prefixes
+}
+
+// search returns the prefixes of commands whose prefix, help or category
+// contain term.
+func (cs *commandSet) search(term string) []string {
+	term = strings.ToLower(term)
+	found := []string{}
+	for _, c := range cs.commands() {
+		if strings.Contains(strings.ToLower(c.prefix), term) ||
+			strings.Contains(strings.ToLower(c.help), term) ||
+			strings.Contains(c.category, term) {
+			found = append(found, c.prefix)
+		}
+	}
+	return found
+}
+
+// help returns the lines to reply to "help txt" with.
+func (cs *commandSet) help(txt string) []string {
+	lower := strings.ToLower(txt)
+	if txt == "" {
+		names, _ := cs.categories()
+		return []string{fmt.Sprintf("Help is available for: %s. "+
+			"Try 'help <category>', 'help <command>' or 'help search <term>', "+
+			"or see %s%s for everything.", strings.Join(names, ", "),
+			HttpHost(), helpPath)}
+	}
+	if lower == "search" || strings.HasPrefix(lower, "search ") {
+		term := strings.TrimSpace(txt[len("search"):])
+		if term == "" {
+			return []string{"Usage: help search <term>"}
+		}
+		if found := cs.search(term); len(found) > 0 {
+			return []string{fmt.Sprintf("Commands about '%s': %s.",
+				term, strings.Join(found, ", "))}
+		}
+		return []string{fmt.Sprintf("I don't have any commands about '%s'.", term)}
+	}
+	r, ln := cs.match(txt)
+	if _, prefixes := cs.categories(); len(prefixes[lower]) > 0 {
+		// Categories are often named after a command, e.g. "remind".
+		lines, others := []string{}, []string{}
+		for _, prefix := range prefixes[lower] {
+			if r != nil && ln == len(txt) && strings.ToLower(prefix) == lower {
+				lines = append(lines, r.Help())
+				continue
+			}
+			others = append(others, prefix)
+		}
+		if len(lines) > 0 && len(others) > 0 {
+			return append(lines, fmt.Sprintf("Other commands in %s: %s.",
+				lower, strings.Join(others, ", ")))
+		}
+		if len(others) > 0 {
+			return []string{fmt.Sprintf("Commands in %s: %s.",
+				lower, strings.Join(others, ", "))}
+		}
+		return lines
+	}
+	if r != nil {
+		return []string{r.Help()}
+	}
+	if poss := cs.possible(txt); len(poss) > 0 {
+		return []string{fmt.Sprintf("Commands matching %q: \"%s\".", txt,
+			strings.Join(poss, "\", \""))}
+	}
+	return []string{fmt.Sprintf("Unrecognised command '%s'.", txt)}
+}
+
+type helpCategory struct {
+	Name     string
+	Commands []helpCommand
+}
+
+type helpCommand struct {
+	Usage, Description string
+	Role               Role
+	Examples           []string
+}
+
+var helpTmpl = template.Must(template.New("help").Parse(`<html>
+<head>
+  <title>sp0rkle's commands</title>
+</head>
+<body>
+  <h1>sp0rkle's commands</h1>
+  <p>Commands must be addressed to sp0rkle in channels, e.g.
+  "sp0rkle: help", but not in private messages.</p>
+{{ range .Categories }}
+  <h2 id="{{ .Name }}">{{ .Name }}</h2>
+  <dl>
+{{ range .Commands }}
+    <dt><code>{{ .Usage }}</code>{{ if gt .Role 0 }} (needs {{ .Role }}){{ end }}</dt>
+    <dd>{{ .Description }}
+{{ range .Examples }}
+      <br />e.g. <code>{{ . }}</code>
+{{ end }}
+    </dd>
+{{ end }}
+  </dl>
+{{ end }}
+{{ with .Aliases }}
+  <h2 id="aliases">aliases</h2>
+  <dl>
+{{ range . }}
+    <dt><code>{{ .Name }}</code></dt>
+    <dd><code>{{ .Expansion }}</code></dd>
+{{ end }}
+  </dl>
+{{ end }}
+</body>
+</html>`))
+
+// ServeHTTP lists every command and alias, grouped by category.
+func (cs *commandSet) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	data := struct {
+		Categories []helpCategory
+		Aliases    []struct{ Name, Expansion string }
+	}{}
+	for _, c := range cs.commands() {
+		if n := len(data.Categories); n == 0 || data.Categories[n-1].Name != c.category {
+			data.Categories = append(data.Categories, helpCategory{Name: c.category})
+		}
+		usage, desc := c.usage()
+		cat := &data.Categories[len(data.Categories)-1]
+		cat.Commands = append(cat.Commands, helpCommand{
+			Usage: usage, Description: desc, Role: c.role, Examples: c.examples})
+	}
+	cs.RLock()
+	as := cs.aliases
+	cs.RUnlock()
+	for _, name := range as.Names() {
+		if a, _ := as.match(name); a != nil {
+			data.Aliases = append(data.Aliases,
+				struct{ Name, Expansion string }{name, a.String()})
+		}
+	}
+	if err := helpTmpl.Execute(rw, data); err != nil {
+		logging.Error("Template execution failed: %v", err)
+	}
+}
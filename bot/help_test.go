@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func testCommands() *commandSet {
+	cs := newCommandSet()
+	for _, c := range []*command{
+		{prefix: "remind", help: "remind <nick> <msg>  -- reminds nick.",
+			category: "remind", examples: []string{"remind me to eat"}},
+		{prefix: "remind list", help: "remind list  -- lists reminders.",
+			category: "remind"},
+		{prefix: "tell", help: "stores a message for nick.", category: "remind",
+			args: []Arg{NickArg("nick"), RestArg("msg")}},
+		{prefix: "say", help: "make the bot say <text>.", category: botCategory,
+			args: []Arg{RestArg("text")}, role: Admin},
+		{prefix: "calc", help: "calc <expr>  -- does maths, like a calculator.",
+			category: "calc"},
+		{prefix: "date", help: "date <time>  -- works out the time.",
+			category: "calc"},
+	} {
+		cs.Add(c, c.prefix)
+	}
+	return cs
+}
+
+func TestHelp(t *testing.T) {
+	cs := testCommands()
+	as := &aliasSet{ns: conf.InMem(aliasesNs)}
+	as.Set(newAlias("r", "remind"))
+	cs.setAliases(as)
+
+	tests := []struct {
+		txt  string
+		want []string
+	}{
+		{"", []string{"Help is available for: bot, calc, remind. " +
+			"Try 'help <category>', 'help <command>' or 'help search <term>', " +
+			"or see " + HttpHost() + "/help for everything."}},
+		{"say", []string{"say <text...>  -- make the bot say <text>. (needs admin)"}},
+		{"remind list", []string{"remind list  -- lists reminders."}},
+		{"Remind", []string{
+			"remind <nick> <msg>  -- reminds nick. (e.g. 'remind me to eat')",
+			"Other commands in remind: remind list, tell."}},
+		{"calc", []string{"calc <expr>  -- does maths, like a calculator.",
+			"Other commands in calc: date."}},
+		{"bot", []string{"Commands in bot: say."}},
+		{"r", []string{"'r' is an alias for 'remind'. " +
+			"remind <nick> <msg>  -- reminds nick. (e.g. 'remind me to eat')"}},
+		{"search", []string{"Usage: help search <term>"}},
+		{"search Time", []string{"Commands about 'Time': date."}},
+		{"search calc", []string{"Commands about 'calc': calc, date."}},
+		{"search nick", []string{"Commands about 'nick': remind, tell."}},
+		{"search fish", []string{"I don't have any commands about 'fish'."}},
+		{"list", []string{"Commands matching \"list\": \"remind list\"."}},
+		{"fish", []string{"Unrecognised command 'fish'."}},
+	}
+	for _, test := range tests {
+		if got := cs.help(test.txt); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("help(%q) = %q\nwant %q", test.txt, got, test.want)
+		}
+	}
+}
+
+func TestHelpHTTP(t *testing.T) {
+	cs := testCommands()
+	as := &aliasSet{ns: conf.InMem(aliasesNs)}
+	as.Set(newAlias("r", "remind me"))
+	cs.setAliases(as)
+
+	rw := httptest.NewRecorder()
+	cs.ServeHTTP(rw, httptest.NewRequest("GET", helpPath, nil))
+	body := rw.Body.String()
+	// Categories are in order, and commands are listed in them.
+	want := []string{
+		`<h2 id="bot">bot</h2>`,
+		`<code>say &lt;text...&gt;</code> (needs admin)`,
+		`<dd>make the bot say &lt;text&gt;.`,
+		`<h2 id="calc">calc</h2>`,
+		`<code>date &lt;time&gt;</code>`,
+		`<h2 id="remind">remind</h2>`,
+		`e.g. <code>remind me to eat</code>`,
+		`<code>tell &lt;nick&gt; &lt;msg...&gt;</code>`,
+		`<h2 id="aliases">aliases</h2>`,
+		`<dd><code>remind me</code></dd>`,
+	}
+	last := 0
+	for _, s := range want {
+		i := strings.Index(body[last:], s)
+		if i < 0 {
+			t.Fatalf("Help page doesn't contain %q after byte %d:\n%s", s, last, body)
+		}
+		last += i + len(s)
+	}
+	if strings.Contains(body, "If you have to ask") {
+		t.Errorf("Help page lists the help command.")
+	}
+}
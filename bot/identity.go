@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/fluffle/goirc/client"
+)
+
+// Masks starting with this match against a services account name.
+const accountPrefix = "$a:"
+
+// Identity describes who sent a line in terms that are harder to spoof
+// than a bare nick: their user@host and the services account they are
+// logged in to.
+type Identity struct {
+	Nick, Ident, Host string
+	// Account is empty if the nick is not logged in, or we don't know.
+	Account string
+	// Known is false if we have not yet found out whether the nick
+	// is logged in to an account or not.
+	Known bool
+}
+
+func lineIdentity(line *client.Line) *Identity {
+	id := &Identity{Nick: line.Nick, Ident: line.Ident, Host: line.Host}
+	id.Account, id.Known = line.Tags["account"]
+	return id
+}
+
+func (id *Identity) Mask() string {
+	return id.Nick + "!" + id.Ident + "@" + id.Host
+}
+
+// UniqueMask returns a mask that matches only this identity: its services
+// account if it is logged in to one, or its hostmask if not.
+func (id *Identity) UniqueMask() string {
+	if id.Account != "" {
+		return accountPrefix + id.Account
+	}
+	return id.Mask()
+}
+
+// IsMask returns true if s is an account or hostmask rather than a nick.
+func IsMask(s string) bool {
+	return strings.HasPrefix(s, accountPrefix) || strings.ContainsAny(s, "!@")
+}
+
+// Matches compares the identity against a mask, which may be one of:
+//
+//	$a:account       -- matches nicks logged in to that services account
+//	nick!user@host   -- a hostmask, which may contain * and ? globs
+//	nick             -- treated as the name of a services account, since
+//	                    that is what NickServ usually registers them as.
+func (id *Identity) Matches(mask string) bool {
+	switch {
+	case strings.HasPrefix(mask, accountPrefix):
+		mask = mask[len(accountPrefix):]
+	case IsMask(mask):
+		return globMatch(strings.ToLower(mask), strings.ToLower(id.Mask()))
+	}
+	return id.Account != "" && strings.EqualFold(id.Account, mask)
+}
+
+// globMatch is a simple IRC-style glob matcher supporting * and ?.
+func globMatch(pattern, s string) bool {
+	px, sx := 0, 0
+	// Where to resume from if a * needs to consume more of s.
+	starP, starS := -1, 0
+	for sx < len(s) {
+		switch {
+		case px < len(pattern) && pattern[px] == '*':
+			starP, starS = px, sx
+			px++
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == s[sx]):
+			px++
+			sx++
+		case starP >= 0:
+			starS++
+			px, sx = starP+1, starS
+		default:
+			return false
+		}
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// accountTracker keeps track of the services accounts that nicks are logged
+// in to, learned from IRCv3 extended-join and account-notify, and WHOIS.
+type accountTracker struct {
+	sync.RWMutex
+	// Per-server map of lowercased nick to account. An empty
+	// account means we know the nick is not logged in.
+	accts map[*client.Conn]map[string]string
+	// Nicks we have sent a WHOIS for and are awaiting a reply.
+	pending map[*client.Conn]map[string]bool
+}
+
+func newAccountTracker() *accountTracker {
+	return &accountTracker{
+		accts:   make(map[*client.Conn]map[string]string),
+		pending: make(map[*client.Conn]map[string]bool),
+	}
+}
+
+func (at *accountTracker) Lookup(conn *client.Conn, nick string) (string, bool) {
+	at.RLock()
+	defer at.RUnlock()
+	acct, ok := at.accts[conn][strings.ToLower(nick)]
+	return acct, ok
+}
+
+func (at *accountTracker) set(conn *client.Conn, nick, acct string) {
+	if acct == "*" || acct == "0" {
+		// Both of these mean "not logged in".
+		acct = ""
+	}
+	if at.accts[conn] == nil {
+		at.accts[conn] = make(map[string]string)
+	}
+	nick = strings.ToLower(nick)
+	at.accts[conn][nick] = acct
+	delete(at.pending[conn], nick)
+}
+
+// Whois asks the server which account nick is logged in to, if we aren't
+// already waiting to find out. The answer arrives asynchronously.
+func (at *accountTracker) Whois(conn *client.Conn, nick string) {
+	at.Lock()
+	defer at.Unlock()
+	if at.pending[conn] == nil {
+		at.pending[conn] = make(map[string]bool)
+	}
+	if at.pending[conn][strings.ToLower(nick)] {
+		return
+	}
+	at.pending[conn][strings.ToLower(nick)] = true
+	conn.Whois(nick)
+}
+
+// Learn the account of the sender of any line carrying IRCv3 account tags.
+func (at *accountTracker) fromTags(conn *client.Conn, line *client.Line) {
+	if acct, ok := line.Tags["account"]; ok && line.Nick != "" {
+		at.Lock()
+		defer at.Unlock()
+		at.set(conn, line.Nick, acct)
+	}
+}
+
+// accountTracker handles JOIN, ACCOUNT, NICK, QUIT, DISCONNECTED
+// and the WHOIS replies 330 (RPL_WHOISACCOUNT) and 318 (RPL_ENDOFWHOIS).
+func (at *accountTracker) Handle(conn *client.Conn, line *client.Line) {
+	at.Lock()
+	defer at.Unlock()
+	switch line.Cmd {
+	case client.JOIN:
+		// extended-join: JOIN #chan account :realname
+		if len(line.Args) >= 3 {
+			at.set(conn, line.Nick, line.Args[1])
+		}
+	case "ACCOUNT":
+		if len(line.Args) >= 1 {
+			at.set(conn, line.Nick, line.Args[0])
+		}
+	case "330":
+		if len(line.Args) >= 3 {
+			at.set(conn, line.Args[1], line.Args[2])
+		}
+	case "318":
+		// If we got to the end of the WHOIS without a 330,
+		// the nick isn't logged in.
+		if len(line.Args) >= 2 && at.pending[conn][strings.ToLower(line.Args[1])] {
+			at.set(conn, line.Args[1], "")
+		}
+	case client.NICK:
+		old, neu := strings.ToLower(line.Nick), strings.ToLower(line.Args[0])
+		if acct, ok := at.accts[conn][old]; ok {
+			delete(at.accts[conn], old)
+			at.accts[conn][neu] = acct
+		}
+	case client.QUIT:
+		delete(at.accts[conn], strings.ToLower(line.Nick))
+	case client.DISCONNECTED:
+		delete(at.accts, conn)
+		delete(at.pending, conn)
+	}
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*!*@*.example.com", "nick!user@host.example.com", true},
+		{"*!*@*.example.com", "nick!user@example.com", false},
+		{"*!*@user/fluffle", "fluffle!f@user/fluffle", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+
+	for _, test := range tests {
+		if got := globMatch(test.pattern, test.s); got != test.want {
+			t.Errorf("globMatch(%q, %q) = %t, want %t",
+				test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestIdentityMatches(t *testing.T) {
+	id := &Identity{Nick: "Fluffle_", Ident: "fluffle", Host: "user/fluffle",
+		Account: "fluffle", Known: true}
+	anon := &Identity{Nick: "fluffle", Ident: "evil", Host: "evil.org", Known: true}
+
+	tests := []struct {
+		mask     string
+		id, anon bool
+	}{
+		{"$a:fluffle", true, false},
+		{"$a:FLUFFLE", true, false},
+		{"$a:someone", false, false},
+		{"fluffle", true, false},
+		{"*!*@user/fluffle", true, false},
+		{"fluffle!*@*", false, true},
+		{"*!evil@*", false, true},
+	}
+
+	for _, test := range tests {
+		if got := id.Matches(test.mask); got != test.id {
+			t.Errorf("%s.Matches(%q) = %t, want %t", id.Mask(), test.mask, got, test.id)
+		}
+		if got := anon.Matches(test.mask); got != test.anon {
+			t.Errorf("%s.Matches(%q) = %t, want %t", anon.Mask(), test.mask, got, test.anon)
+		}
+	}
+}
+
+func TestAccountTracker(t *testing.T) {
+	at := newAccountTracker()
+	// A nil conn is good enough to key the per-server maps.
+	var conn *client.Conn
+
+	if _, ok := at.Lookup(conn, "nick"); ok {
+		t.Errorf("Lookup found account for unknown nick")
+	}
+
+	lines := []*client.Line{
+		// extended-join
+		{Nick: "nick", Cmd: client.JOIN, Args: []string{"#chan", "acct", "Real Name"}},
+		// not logged in
+		{Nick: "other", Cmd: client.JOIN, Args: []string{"#chan", "*", "Real Name"}},
+		// RPL_WHOISACCOUNT
+		{Cmd: "330", Args: []string{"me", "Whois", "whoacct", "is logged in as"}},
+	}
+	for _, l := range lines {
+		at.Handle(conn, l)
+	}
+
+	tests := []struct {
+		nick, acct string
+		known      bool
+	}{
+		{"nick", "acct", true},
+		{"NICK", "acct", true},
+		{"other", "", true},
+		{"whois", "whoacct", true},
+		{"unknown", "", false},
+	}
+	for _, test := range tests {
+		acct, known := at.Lookup(conn, test.nick)
+		if acct != test.acct || known != test.known {
+			t.Errorf("Lookup(%q) = %q, %t; want %q, %t",
+				test.nick, acct, known, test.acct, test.known)
+		}
+	}
+
+	at.Handle(conn, &client.Line{Nick: "nick", Cmd: client.NICK, Args: []string{"newnick"}})
+	if acct, _ := at.Lookup(conn, "newnick"); acct != "acct" {
+		t.Errorf("Account didn't follow nick change, got %q", acct)
+	}
+	at.Handle(conn, &client.Line{Nick: "newnick", Cmd: "ACCOUNT", Args: []string{"*"}})
+	if acct, known := at.Lookup(conn, "newnick"); acct != "" || !known {
+		t.Errorf("Account not cleared by ACCOUNT *, got %q, %t", acct, known)
+	}
+	at.Handle(conn, &client.Line{Cmd: client.DISCONNECTED})
+	if _, known := at.Lookup(conn, "whois"); known {
+		t.Errorf("Accounts not wiped on disconnect")
+	}
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+const (
+	// Where Prometheus can scrape metrics from, see util/metrics.
+	metricsPath = "/metrics"
+	// Where load balancers and monitoring can check the bot is connected.
+	healthPath = "/healthz"
+)
+
+var (
+	connectedNetworks = metrics.NewGauge("sp0rkle_connected",
+		"Whether the bot is connected to each network.", "network")
+	reconnects = metrics.NewCounter("sp0rkle_reconnects_total",
+		"Connection attempts after the first to each network.", "network")
+	handlerLines = metrics.NewCounter("sp0rkle_handler_lines_total",
+		"Lines processed by each handler.", "handler")
+	handlerSeconds = metrics.NewHistogram("sp0rkle_handler_duration_seconds",
+		"How long each handler takes to process a line.",
+		metrics.DefBuckets, "handler")
+	commandLines = metrics.NewCounter("sp0rkle_command_lines_total",
+		"Lines processed by each command.", "command")
+	commandSeconds = metrics.NewHistogram("sp0rkle_command_duration_seconds",
+		"How long each command takes to run.", metrics.DefBuckets, "command")
+	jobRuns = metrics.NewCounter("sp0rkle_job_runs_total",
+		"Runs of each scheduled job, by result.", "job", "result")
+	jobSeconds = metrics.NewHistogram("sp0rkle_job_duration_seconds",
+		"How long each scheduled job takes to run.", metrics.DefBuckets, "job")
+)
+
+// setConnected records whether the bot is connected to network.
+func setConnected(network string, connected bool) {
+	v := 0.0
+	if connected {
+		v = 1
+	}
+	connectedNetworks.Set(v, network)
+}
+
+// funcNames caches the names of handler funcs, keyed on their address.
+var funcNames sync.Map
+
+// funcName returns a readable name for fn, like "drivers/factdriver.lookup".
+func funcName(fn any) string {
+	pc := reflect.ValueOf(fn).Pointer()
+	if name, ok := funcNames.Load(pc); ok {
+		return name.(string)
+	}
+	name := "unknown"
+	if f := runtime.FuncForPC(pc); f != nil {
+		name = trimPath(f.Name())
+	}
+	funcNames.Store(pc, name)
+	return name
+}
+
+// observeHandler records that hf processed a line, starting at start.
+func observeHandler(hf HandlerFunc, start time.Time) {
+	name := funcName(hf)
+	handlerLines.Inc(name)
+	handlerSeconds.Since(start, name)
+}
+
+// observeCommand records that the command prefix ran, starting at start.
+func observeCommand(prefix string, start time.Time) {
+	commandLines.Inc(prefix)
+	commandSeconds.Since(start, prefix)
+}
+
+// healthHTTP reports whether the bot is connected to all of its networks,
+// with a 503 if it isn't, so that monitoring can tell when it has silently
+// dropped off one.
+func healthHTTP(rw http.ResponseWriter, req *http.Request) {
+	var connected map[string]bool
+	if bot != nil {
+		connected = bot.servers.Connected()
+	}
+	lines, healthy := []string{}, len(connected) > 0
+	for _, name := range sortedKeys(connected) {
+		state := "connected"
+		if !connected[name] {
+			state, healthy = "disconnected", false
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", name, state))
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if healthy {
+		fmt.Fprintln(rw, "ok")
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(rw, "unhealthy")
+	}
+	fmt.Fprintln(rw, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,315 @@
+package bot
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+	"gopkg.in/yaml.v2"
+)
+
+// Network describes how to connect to an IRC network and what to do there.
+// Oper, VHost and SASL passwords are secrets, see GetSecret.
+type Network struct {
+	// Name identifies the network, e.g. to bot.Context.Network().
+	// Defaults to the server's hostname.
+	Name string `yaml:"name"`
+	// Either "irc", the default, or "chat" for the protocol described in
+	// chat.go, which only uses Server, Nick, TLS, Channels and Pause.
+	Protocol string        `yaml:"protocol"`
+	Server   string        `yaml:"server"`
+	Nick     string        `yaml:"nick"`
+	Ident    string        `yaml:"ident"`
+	Realname string        `yaml:"realname"`
+	TLS      TLSConfig     `yaml:"tls"`
+	SASL     SASLConfig    `yaml:"sasl"`
+	Channels []string      `yaml:"channels"`
+	Oper     string        `yaml:"oper"`
+	VHost    string        `yaml:"vhost"`
+	Pause    time.Duration `yaml:"pause"`
+}
+
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Defaults to the server's hostname.
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// A PEM client certificate and key, which are secrets. These are
+	// required for SASL EXTERNAL, and sent to the server if set.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+const (
+	protocolIRC  = "irc"
+	protocolChat = "chat"
+
+	saslPlain    = "plain"
+	saslExternal = "external"
+)
+
+// SASLConfig enables SASL authentication. PLAIN, the default mechanism,
+// needs a user and password. EXTERNAL authenticates with the TLS client
+// certificate, and User is an optional authorization identity.
+type SASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	User      string `yaml:"user"`
+	Password  string `yaml:"password"`
+}
+
+// Config is the structure of the --config YAML file. Apart from Networks,
+// these settings are in addition to any made at runtime with commands.
+type Config struct {
+	Networks []*Network `yaml:"networks"`
+	// Nicks or masks to ignore, like the "ignore" command.
+	Ignore []string `yaml:"ignore"`
+	// Map of channel to drivers disabled there, like "driver disable".
+	// Drivers can be enabled again at runtime.
+	DisabledDrivers map[string][]string `yaml:"disabled_drivers"`
+	// Overrides the default rate limits used by drivers, keyed by name.
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+	// Map of channel to characters that address the bot when they start
+	// a line there, like "trigger set". Triggers can be changed at runtime.
+	Triggers map[string]string `yaml:"triggers"`
+	// Tokens and templates for the HTTP API, see api.go.
+	API APIConfig `yaml:"api"`
+}
+
+// parseConfig unmarshals and validates a YAML config.
+func parseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Networks) == 0 {
+		return nil, fmt.Errorf("no networks configured")
+	}
+	cfg.normalise()
+	if err := cfg.API.validate(); err != nil {
+		return nil, err
+	}
+	for ch, t := range cfg.Triggers {
+		if !validTriggers(t) {
+			return nil, fmt.Errorf("triggers for %s must be punctuation, not %q", ch, t)
+		}
+	}
+	names := map[string]bool{}
+	for i, n := range cfg.Networks {
+		if n.Server == "" {
+			return nil, fmt.Errorf("network %d has no server", i)
+		}
+		n.setDefaults()
+		if err := n.validate(); err != nil {
+			return nil, fmt.Errorf("network %q: %v", n.Name, err)
+		}
+		if names[n.Name] {
+			return nil, fmt.Errorf("network name %q used more than once", n.Name)
+		}
+		names[n.Name] = true
+	}
+	return cfg, nil
+}
+
+// normalise lowercases everything that is matched case-insensitively.
+func (cfg *Config) normalise() {
+	for i, s := range cfg.Ignore {
+		cfg.Ignore[i] = strings.ToLower(s)
+	}
+	drivers := make(map[string][]string, len(cfg.DisabledDrivers))
+	for ch, ds := range cfg.DisabledDrivers {
+		ch = strings.ToLower(ch)
+		for _, d := range ds {
+			drivers[ch] = append(drivers[ch], strings.ToLower(d))
+		}
+	}
+	cfg.DisabledDrivers = drivers
+	triggers := make(map[string]string, len(cfg.Triggers))
+	for ch, t := range cfg.Triggers {
+		triggers[strings.ToLower(ch)] = t
+	}
+	cfg.Triggers = triggers
+	for _, t := range cfg.API.Tokens {
+		for i, ch := range t.Channels {
+			t.Channels[i] = strings.ToLower(ch)
+		}
+	}
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// flagConfig builds a Config from the --servers, --nick, --ssl,
+// --channels, --oper, --vhost and --pause flags, for when there's
+// no config file. Every server gets the same settings.
+func flagConfig() *Config {
+	cfg := &Config{}
+	for _, hostport := range strings.Split(*servers, ",") {
+		if hostport = strings.TrimSpace(hostport); hostport == "" {
+			continue
+		}
+		n := &Network{
+			Server: hostport,
+			TLS:    TLSConfig{Enabled: *ssl},
+			Oper:   *oper,
+			VHost:  *vhost,
+		}
+		for _, ch := range strings.Split(*channels, ",") {
+			if ch = strings.TrimSpace(ch); ch != "" {
+				n.Channels = append(n.Channels, ch)
+			}
+		}
+		n.setDefaults()
+		cfg.Networks = append(cfg.Networks, n)
+	}
+	return cfg
+}
+
+// initialConfig loads --config if it is set, or builds one from flags.
+func initialConfig() *Config {
+	cfg := flagConfig()
+	if *config != "" {
+		var err error
+		if cfg, err = loadConfig(*config); err != nil {
+			logging.Fatal("Couldn't load --config: %v", err)
+		}
+	}
+	if len(cfg.Networks) == 0 && !*console {
+		// Don't call logging.Fatal as we don't want a backtrace in this case
+		logging.Error("--servers, --config or --console option required. \nOptions are:\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func (n *Network) host() string {
+	if host, _, err := net.SplitHostPort(n.Server); err == nil {
+		return host
+	}
+	return n.Server
+}
+
+func (n *Network) setDefaults() {
+	if n.Name == "" {
+		n.Name = n.host()
+	}
+	n.Name = strings.ToLower(n.Name)
+	if n.Protocol = strings.ToLower(n.Protocol); n.Protocol == "" {
+		n.Protocol = protocolIRC
+	}
+	if n.Nick == "" {
+		n.Nick = *nick
+	}
+	if n.Ident == "" {
+		n.Ident = "boing"
+	}
+	if n.Realname == "" {
+		n.Realname = "slowly becoming sp0rkle"
+	}
+	if n.Pause == 0 {
+		n.Pause = *pause
+	}
+	n.SASL.Mechanism = strings.ToLower(n.SASL.Mechanism)
+	if n.SASL.Mechanism == "" && n.SASL.User != "" {
+		n.SASL.Mechanism = saslPlain
+	}
+}
+
+func (n *Network) validate() error {
+	switch n.Protocol {
+	case protocolIRC:
+	case protocolChat:
+		if n.SASL.Mechanism != "" {
+			return fmt.Errorf("the chat protocol doesn't support SASL")
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q", n.Protocol)
+	}
+	switch n.SASL.Mechanism {
+	case "":
+	case saslPlain:
+		if n.SASL.User == "" || n.SASL.Password == "" {
+			return fmt.Errorf("SASL PLAIN needs a user and password")
+		}
+	case saslExternal:
+		if !n.TLS.Enabled || n.TLS.Cert == "" {
+			return fmt.Errorf("SASL EXTERNAL needs TLS with a client cert")
+		}
+	default:
+		return fmt.Errorf("unknown SASL mechanism %q", n.SASL.Mechanism)
+	}
+	if (n.TLS.Cert == "") != (n.TLS.Key == "") {
+		return fmt.Errorf("TLS client cert and key must be set together")
+	}
+	return nil
+}
+
+// clientConfig turns the network into configuration for goirc.
+func (n *Network) clientConfig() (*client.Config, error) {
+	cfg := client.NewConfig(n.Nick, n.Ident, n.Realname)
+	cfg.Server = n.Server
+	// The outQueue takes care of flood protection.
+	cfg.Flood = true
+	cfg.EnableCapabilityNegotiation = true
+	cfg.Capabilites = capabilities
+	if n.TLS.Enabled {
+		var err error
+		cfg.SSL = true
+		if cfg.SSLConfig, err = n.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	switch n.SASL.Mechanism {
+	case saslPlain:
+		cfg.Sasl = sasl.NewPlainClient("", n.SASL.User, GetSecret(n.SASL.Password))
+	case saslExternal:
+		cfg.Sasl = sasl.NewExternalClient(n.SASL.User)
+	}
+	cfg.Recover = unfail
+	return cfg, nil
+}
+
+func (n *Network) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         n.TLS.ServerName,
+		InsecureSkipVerify: n.TLS.InsecureSkipVerify,
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = n.host()
+	}
+	if n.TLS.Cert != "" {
+		cert, err := tls.X509KeyPair(
+			[]byte(GetSecret(n.TLS.Cert)), []byte(GetSecret(n.TLS.Key)))
+		if err != nil {
+			return nil, fmt.Errorf("loading client cert: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// splitSecret splits a "user:password" secret, e.g. for OPER and VHOST.
+func splitSecret(s string) (string, string, bool) {
+	up := strings.SplitN(GetSecret(s), ":", 2)
+	if len(up) != 2 {
+		return "", "", false
+	}
+	return up[0], up[1], true
+}
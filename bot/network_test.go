@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+networks:
+  - name: Libera
+    server: irc.libera.chat:6697
+    nick: sp0rkle
+    tls:
+      enabled: true
+    sasl:
+      mechanism: PLAIN
+      user: sp0rkle
+      password: $SASL_PASSWORD
+    channels: ["#sp0rklf", "#other"]
+    pause: 30s
+  - server: irc.example.org:6667
+`))
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+	if len(cfg.Networks) != 2 {
+		t.Fatalf("parseConfig returned %d networks, want 2", len(cfg.Networks))
+	}
+
+	libera, other := cfg.Networks[0], cfg.Networks[1]
+	if libera.Name != "libera" || libera.Nick != "sp0rkle" ||
+		libera.Pause != 30*time.Second || len(libera.Channels) != 2 {
+		t.Errorf("Unexpected libera config: %#v", libera)
+	}
+	c, err := libera.clientConfig()
+	if err != nil {
+		t.Fatalf("clientConfig() for libera returned error: %v", err)
+	}
+	if !c.SSL || c.SSLConfig.ServerName != "irc.libera.chat" {
+		t.Errorf("TLS not configured for libera: SSL=%t, %#v", c.SSL, c.SSLConfig)
+	}
+	if c.Sasl == nil {
+		t.Errorf("SASL not configured for libera.")
+	}
+
+	// Unset fields get defaults.
+	if other.Name != "irc.example.org" || other.Nick != *nick ||
+		other.Ident != "boing" || other.Pause != *pause {
+		t.Errorf("Defaults not set for other network: %#v", other)
+	}
+	c, err = other.clientConfig()
+	if err != nil {
+		t.Fatalf("clientConfig() for other returned error: %v", err)
+	}
+	if c.SSL || c.Sasl != nil || c.Server != "irc.example.org:6667" {
+		t.Errorf("Unexpected client config for other network: %#v", c)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		yaml, err string
+	}{
+		{"", "no networks"},
+		{"networks:\n  - nick: foo\n", "has no server"},
+		{"networks:\n  - server: a:1\n  - server: a:2\n", "used more than once"},
+		{"networks:\n  - server: a:1\n    nickname: foo\n", "not found"},
+		{"networks:\n  - server: a:1\n    pause: soon\n", "cannot unmarshal"},
+		{"networks:\n  - server: a:1\n    sasl: {user: foo}\n", "needs a user and password"},
+		{"networks:\n  - server: a:1\n    sasl: {mechanism: external}\n", "needs TLS"},
+		{"networks:\n  - server: a:1\n    sasl: {mechanism: scram}\n", "unknown SASL mechanism"},
+		{"networks:\n  - server: a:1\n    tls: {enabled: true, cert: foo}\n", "set together"},
+		{"networks: [{server: a:1}]\ntriggers: {\"#a\": \"x\"}\n", "must be punctuation"},
+		{"networks: [{server: a:1}]\napi: {tokens: [{name: ci}]}\n", "needs a name and token"},
+		{"networks: [{server: a:1}]\napi: {tokens: [{name: ci, token: x}]}\n", "has no channels"},
+		{"networks: [{server: a:1}]\napi: {templates: {ci: \"{{.foo\"}}\n", "api template"},
+	}
+	for _, test := range tests {
+		_, err := parseConfig([]byte(test.yaml))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("parseConfig(%q) = %v, want error containing %q",
+				test.yaml, err, test.err)
+		}
+	}
+}
+
+func TestClientConfigBadCert(t *testing.T) {
+	n := &Network{Server: "irc.example.org:6697", TLS: TLSConfig{
+		Enabled: true, Cert: "not a cert", Key: "not a key"}}
+	n.setDefaults()
+	if _, err := n.clientConfig(); err == nil {
+		t.Errorf("clientConfig() with bad client cert didn't return an error.")
+	}
+}
+
+func TestSplitSecret(t *testing.T) {
+	tests := []struct {
+		in, user, pass string
+		ok             bool
+	}{
+		{"user:pass", "user", "pass", true},
+		{"user:pass:word", "user", "pass:word", true},
+		{"user", "", "", false},
+		{"", "", "", false},
+	}
+	for _, test := range tests {
+		user, pass, ok := splitSecret(test.in)
+		if user != test.user || pass != test.pass || ok != test.ok {
+			t.Errorf("splitSecret(%q) = %q, %q, %t; want %q, %q, %t",
+				test.in, user, pass, ok, test.user, test.pass, test.ok)
+		}
+	}
+}
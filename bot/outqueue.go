@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+)
+
+// Default limits on the rate we send lines to each target, and to each
+// server as a whole. These can be changed with rate_limits in the config
+// file, using the names "target" and "flood".
+var (
+	targetLimit = RateLimit{Every: 2 * time.Second, Burst: 5}
+	floodLimit  = RateLimit{Every: time.Second, Burst: 10}
+)
+
+const (
+	// Lines queued for a target beyond this are dropped.
+	maxPending = 50
+	// The longest line a server will relay, excluding the trailing CRLF.
+	maxLineLen = 510
+	// We don't know our hostname as others see it, so assume the worst.
+	maxHostLen = 63
+)
+
+// outQueue rate limits the lines the bot sends to a server. Each target
+// has its own token bucket, and targets with lines waiting take turns,
+// so that one chatty channel doesn't hold up replies in another.
+type outQueue struct {
+	send func(string)
+
+	mu sync.Mutex
+	// Lines waiting to be sent, keyed on lowercased target.
+	pending map[string][]string
+	// Targets with lines waiting, in the order they'll be served.
+	order   []string
+	buckets map[string]*bucket
+	flood   bucket
+
+	wake chan struct{}
+	quit chan struct{}
+}
+
+func newOutQueue(send func(string)) *outQueue {
+	return &outQueue{
+		send:    send,
+		pending: make(map[string][]string),
+		buckets: make(map[string]*bucket),
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+}
+
+// queue adds raw lines for target to the end of the queue.
+func (q *outQueue) queue(target string, lines ...string) {
+	if len(lines) == 0 {
+		// Otherwise target is queued with nothing for next to send.
+		return
+	}
+	key := strings.ToLower(target)
+	q.mu.Lock()
+	if len(q.pending[key]) == 0 {
+		q.order = append(q.order, key)
+	}
+	if n := maxPending - len(q.pending[key]); len(lines) > n {
+		logging.Warn("Dropping %d lines to %s, the queue is full.",
+			len(lines)-n, target)
+		lines = lines[:n]
+	}
+	q.pending[key] = append(q.pending[key], lines...)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next line that can be sent at now, or how long to wait
+// before one can be. If there's nothing waiting, both are empty.
+func (q *outQueue) next(now time.Time) (string, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.order) == 0 {
+		return "", 0
+	}
+	flood := configLimit("flood", floodLimit)
+	if wait := q.flood.wait(flood, now); wait > 0 {
+		return "", wait
+	}
+	limit := configLimit("target", targetLimit)
+	var wait time.Duration
+	for i, key := range q.order {
+		b, ok := q.buckets[key]
+		if !ok {
+			b = &bucket{}
+			q.buckets[key] = b
+		}
+		if w := b.wait(limit, now); w > 0 {
+			if wait == 0 || w < wait {
+				wait = w
+			}
+			continue
+		}
+		b.take()
+		q.flood.take()
+		line := q.pending[key][0]
+		q.pending[key] = q.pending[key][1:]
+		q.order = append(q.order[:i], q.order[i+1:]...)
+		if len(q.pending[key]) > 0 {
+			q.order = append(q.order, key)
+		} else {
+			delete(q.pending, key)
+			q.prune(limit, now)
+		}
+		return line, 0
+	}
+	return "", wait
+}
+
+// prune forgets targets with full buckets and nothing waiting, since
+// they're no different to targets we've never sent anything to.
+func (q *outQueue) prune(limit RateLimit, now time.Time) {
+	if len(q.order) > 0 {
+		return
+	}
+	for key, b := range q.buckets {
+		if b.full(limit, now) {
+			delete(q.buckets, key)
+		}
+	}
+}
+
+// clear throws away any lines waiting to be sent and resets the rate
+// limits, for when we're disconnected.
+func (q *outQueue) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = make(map[string][]string)
+	q.order = nil
+	q.buckets = make(map[string]*bucket)
+	q.flood = bucket{}
+}
+
+// run sends lines as fast as the rate limits allow, until stop is called.
+func (q *outQueue) run() {
+	for {
+		line, wait := q.next(time.Now())
+		if line != "" {
+			q.send(line)
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-q.wake:
+		case <-timer:
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+func (q *outQueue) stop() {
+	close(q.quit)
+}
+
+// formatLines turns text into raw lines of a PRIVMSG, NOTICE or ACTION,
+// splitting it at newlines and wherever else necessary to make sure the
+// server doesn't truncate it when it adds our nick!ident@host prefix.
+func formatLines(cmd, target, text, nick, ident string) []string {
+	prefix, suffix := cmd+" "+target+" :", ""
+	if cmd == client.ACTION {
+		prefix = client.PRIVMSG + " " + target + " :\001" + client.ACTION + " "
+		suffix = "\001"
+	}
+	lines := []string{}
+	for _, s := range splitText(text, textLength(prefix+suffix, nick, ident)) {
+		lines = append(lines, prefix+s+suffix)
+	}
+	return lines
+}
+
+// textLength returns how much text fits in a line with the given command
+// and trailing syntax, once the server has added our prefix.
+func textLength(syntax, nick, ident string) int {
+	// The ident may have been prefixed with a "~".
+	return maxLineLen - len(":!~@ ") - len(nick) - len(ident) - maxHostLen -
+		len(syntax)
+}
+
+// splitText splits text into non-empty lines at newlines, and then splits
+// lines longer than length bytes at the last space before the limit if
+// possible, or the last UTF-8 character boundary if not.
+func splitText(text string, length int) []string {
+	length = max(length, utf8.UTFMax)
+	split := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		for len(line) > length {
+			idx, skip := strings.LastIndexByte(line[:length+1], ' '), 1
+			if idx <= 0 {
+				idx, skip = length, 0
+				for !utf8.RuneStart(line[idx]) {
+					idx--
+				}
+			}
+			split = append(split, line[:idx])
+			line = line[idx+skip:]
+		}
+		if line != "" {
+			split = append(split, line)
+		}
+	}
+	return split
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutQueueNext(t *testing.T) {
+	q := newOutQueue(nil)
+	q.queue("#Busy", strings.Split("1 2 3 4 5 6 7", " ")...)
+	q.queue("#quiet", "a", "b")
+	now := time.Now()
+
+	// Targets take turns until the busy one runs out of tokens.
+	want := []string{"1", "a", "2", "b", "3", "4", "5"}
+	for _, w := range want {
+		if line, wait := q.next(now); line != w || wait != 0 {
+			t.Errorf("next() = %q, %s; want %q", line, wait, w)
+		}
+	}
+	if line, wait := q.next(now); line != "" || wait != targetLimit.Every {
+		t.Errorf("next() with empty bucket = %q, %s; want wait %s",
+			line, wait, targetLimit.Every)
+	}
+	now = now.Add(targetLimit.Every)
+	if line, _ := q.next(now); line != "6" {
+		t.Errorf("next() after wait = %q, want \"6\"", line)
+	}
+
+	// A new target is served as soon as the busy one's turn is over,
+	// until the whole connection runs out of tokens.
+	q.queue("#other", "x", "y", "z")
+	for _, w := range []string{"x", "y", "z"} {
+		if line, _ := q.next(now); line != w {
+			t.Errorf("next() = %q, want %q", line, w)
+		}
+	}
+	if line, wait := q.next(now); line != "" || wait == 0 {
+		t.Errorf("next() after flood = %q, %s; want a wait", line, wait)
+	}
+
+	q.clear()
+	if line, wait := q.next(now); line != "" || wait != 0 {
+		t.Errorf("next() after clear = %q, %s", line, wait)
+	}
+}
+
+func TestOutQueuePrune(t *testing.T) {
+	q := newOutQueue(nil)
+	now := time.Now()
+	q.queue("#a", "1")
+	q.next(now)
+	q.queue("#b", "2")
+	q.next(now.Add(time.Minute))
+	// #a's bucket has refilled, but #b's was just used.
+	if _, ok := q.buckets["#a"]; ok || len(q.buckets) != 1 {
+		t.Errorf("Buckets not pruned: %v", q.buckets)
+	}
+}
+
+func TestOutQueueEmpty(t *testing.T) {
+	q := newOutQueue(nil)
+	// Text that is all newlines formats to no lines at all.
+	q.queue("#chan", formatLines("PRIVMSG", "#chan", "\n\n", "sp0rkle", "sp0rkle")...)
+	if line, wait := q.next(time.Now()); line != "" || wait != 0 || len(q.order) != 0 {
+		t.Errorf("next() = %q, %s with %v queued", line, wait, q.order)
+	}
+}
+
+func TestOutQueueFull(t *testing.T) {
+	q := newOutQueue(nil)
+	q.queue("#chan", make([]string, maxPending-1)...)
+	q.queue("#chan", "a", "b", "c")
+	if n := len(q.pending["#chan"]); n != maxPending {
+		t.Errorf("Queue has %d lines, want %d", n, maxPending)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		text   string
+		length int
+		want   []string
+	}{
+		{"", 10, []string{}},
+		{"short", 10, []string{"short"}},
+		{"one\r\ntwo\n\nthree", 10, []string{"one", "two", "three"}},
+		{"a sentence that is too long", 10,
+			[]string{"a sentence", "that is", "too long"}},
+		{"unbreakablewords", 10, []string{"unbreakabl", "ewords"}},
+		{"ééééééé", 5, []string{"éé", "éé", "éé", "é"}},
+	}
+	for _, test := range tests {
+		if got := splitText(test.text, test.length); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitText(%q, %d) = %q, want %q",
+				test.text, test.length, got, test.want)
+		}
+	}
+}
+
+func TestFormatLines(t *testing.T) {
+	lines := formatLines("ACTION", "#chan", "waves", "bot", "ident")
+	if want := []string{"PRIVMSG #chan :\001ACTION waves\001"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("formatLines(ACTION) = %q, want %q", lines, want)
+	}
+	lines = formatLines("PRIVMSG", "#chan", strings.Repeat("word ", 200), "bot", "ident")
+	if len(lines) != 3 {
+		t.Errorf("formatLines(long) = %d lines, want 3", len(lines))
+	}
+	for _, l := range lines {
+		prefix := ":bot!~ident@" + strings.Repeat("h", maxHostLen) + " "
+		if len(prefix+l) > maxLineLen {
+			t.Errorf("Line too long: %d > %d", len(prefix+l), maxLineLen)
+		}
+	}
+}
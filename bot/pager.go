@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+)
+
+const (
+	// How many lines are sent at once by Page, and with each "more".
+	publicPage  = 4
+	privatePage = 10
+	// Unread pages are forgotten after this long.
+	pageExpiry = 10 * time.Minute
+)
+
+// pager keeps the lines of long replies that haven't been sent yet,
+// keyed on the network, target and nick that asked for them.
+type pager struct {
+	sync.Mutex
+	pages map[string]*paged
+}
+
+type paged struct {
+	lines   []string
+	expires time.Time
+}
+
+func newPager() *pager {
+	return &pager{pages: make(map[string]*paged)}
+}
+
+// set replaces the lines waiting for key, forgetting any that expired.
+func (p *pager) set(key string, lines []string, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	for k, pg := range p.pages {
+		if now.After(pg.expires) {
+			delete(p.pages, k)
+		}
+	}
+	p.pages[key] = &paged{lines: lines, expires: now.Add(pageExpiry)}
+}
+
+// next returns up to n of the lines waiting for key, and how many remain.
+func (p *pager) next(key string, n int, now time.Time) ([]string, int) {
+	p.Lock()
+	defer p.Unlock()
+	pg, ok := p.pages[key]
+	if !ok || now.After(pg.expires) {
+		delete(p.pages, key)
+		return nil, 0
+	}
+	n = min(n, len(pg.lines))
+	page := pg.lines[:n]
+	if pg.lines = pg.lines[n:]; len(pg.lines) == 0 {
+		delete(p.pages, key)
+	} else {
+		pg.expires = now.Add(pageExpiry)
+	}
+	return page, len(pg.lines)
+}
+
+// Page replies with lines a page at a time, splitting them so that each
+// fits on one line of IRC. Further pages are fetched with "more". Like
+// Privmsg, the lines aren't rewritten.
+func (ctx *Context) Page(lines ...string) {
+	ident := ""
+	if t, ok := ctx.t.(ircTransport); ok {
+		ident = t.server.Network().Ident
+	}
+	length := textLength(client.PRIVMSG+" "+ctx.Target()+" :", ctx.Me(), ident)
+	split := []string{}
+	for _, line := range lines {
+		split = append(split, splitText(line, length)...)
+	}
+	bot.pager.set(ctx.pageKey(), split, time.Now())
+	ctx.nextPage()
+}
+
+// PageN is like Page, but adds a prefix of "nick: " like ReplyN.
+func (ctx *Context) PageN(lines ...string) {
+	if len(lines) > 0 {
+		lines[0] = ctx.Nick + ": " + lines[0]
+	}
+	ctx.Page(lines...)
+}
+
+func (ctx *Context) pageKey() string {
+	return strings.ToLower(ctx.Network() + " " + ctx.Target() + " " + ctx.Nick)
+}
+
+// nextPage sends the next page of lines waiting for the context's nick,
+// returning false if there aren't any.
+func (ctx *Context) nextPage() bool {
+	size := publicPage
+	if !ctx.Public() {
+		size = privatePage
+	}
+	page, left := bot.pager.next(ctx.pageKey(), size, time.Now())
+	for _, line := range page {
+		ctx.send(client.PRIVMSG, ctx.Target(), line)
+	}
+	if left > 0 {
+		ctx.ReplyN("%d more lines, ask for \"more\".", left)
+	}
+	return len(page) > 0
+}
+
+func more(ctx *Context) {
+	if !ctx.nextPage() {
+		ctx.ReplyN("There's nothing more to show you.")
+	}
+}
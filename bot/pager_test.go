@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPager(t *testing.T) {
+	p := newPager()
+	now := time.Now()
+	p.set("net #chan nick", []string{"1", "2", "3", "4", "5"}, now)
+
+	tests := []struct {
+		after time.Duration
+		page  []string
+		left  int
+	}{
+		{0, []string{"1", "2"}, 3},
+		{time.Minute, []string{"3", "4"}, 1},
+		{2 * time.Minute, []string{"5"}, 0},
+		{3 * time.Minute, nil, 0},
+	}
+	for _, test := range tests {
+		page, left := p.next("net #chan nick", 2, now.Add(test.after))
+		if !reflect.DeepEqual(page, test.page) || left != test.left {
+			t.Errorf("next() after %s = %q, %d; want %q, %d",
+				test.after, page, left, test.page, test.left)
+		}
+	}
+
+	// Pages are only for the nick that asked for them.
+	p.set("net #chan nick", []string{"1", "2", "3"}, now)
+	if page, _ := p.next("net #chan other", 2, now); page != nil {
+		t.Errorf("next() for other nick = %q", page)
+	}
+	// Each page extends the expiry...
+	if page, _ := p.next("net #chan nick", 1, now.Add(pageExpiry-time.Second)); len(page) != 1 {
+		t.Errorf("next() before expiry = %q", page)
+	}
+	if page, _ := p.next("net #chan nick", 1, now.Add(2*pageExpiry-2*time.Second)); len(page) != 1 {
+		t.Errorf("next() before extended expiry = %q", page)
+	}
+	// ... until they go unread for too long.
+	if page, _ := p.next("net #chan nick", 1, now.Add(4*pageExpiry)); page != nil {
+		t.Errorf("next() after expiry = %q", page)
+	}
+	if len(p.pages) != 0 {
+		t.Errorf("Expired pages not forgotten: %v", p.pages)
+	}
+
+	// Setting new pages replaces old ones and forgets expired ones.
+	p.set("a", []string{"1"}, now)
+	p.set("b", []string{"1"}, now)
+	p.set("b", []string{"2"}, now.Add(2*pageExpiry))
+	if page, _ := p.next("b", 2, now.Add(2*pageExpiry)); !reflect.DeepEqual(page, []string{"2"}) {
+		t.Errorf("next() after replacing = %q", page)
+	}
+	if _, ok := p.pages["a"]; ok {
+		t.Errorf("Expired page not forgotten on set.")
+	}
+}
@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows something to be done once Every interval on average,
+// with up to Burst uses in quick succession.
+type RateLimit struct {
+	Every time.Duration `yaml:"every"`
+	Burst int           `yaml:"burst"`
+}
+
+func (rl RateLimit) String() string {
+	return fmt.Sprintf("1 per %s, burst %d", rl.Every, rl.Burst)
+}
+
+type usage struct {
+	badness  time.Duration
+	lastsent time.Time
+}
+
+var (
+	limitLock sync.Mutex
+	// Usage of each named rate limit, per lowercased nick.
+	usages = map[string]map[string]*usage{}
+)
+
+// RateLimited returns true if nick has exceeded the named rate limit. The
+// config file's rate_limits can override the default limit, def.
+func RateLimited(name, nick string, def RateLimit) bool {
+	rl := configLimit(name, def)
+	limitLock.Lock()
+	defer limitLock.Unlock()
+	if usages[name] == nil {
+		usages[name] = map[string]*usage{}
+	}
+	nick = strings.ToLower(nick)
+	u, ok := usages[name][nick]
+	if !ok {
+		u = new(usage)
+		usages[name][nick] = u
+	}
+	return u.limited(rl, time.Now())
+}
+
+func (u *usage) limited(rl RateLimit, now time.Time) bool {
+	elapsed := now.Sub(u.lastsent)
+	if u.badness += rl.Every - elapsed; u.badness < 0 {
+		u.badness = 0
+	}
+	if u.badness > time.Duration(rl.Burst)*rl.Every {
+		return true
+	}
+	u.lastsent = now
+	return false
+}
+
+// configLimit returns the config file's rate limit for name, or def.
+func configLimit(name string, def RateLimit) RateLimit {
+	if rl, ok := currentConfig().RateLimits[name]; ok {
+		return rl
+	}
+	return def
+}
+
+// A bucket holds up to Burst tokens, and gains a token Every interval.
+// It starts off full.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(rl RateLimit, now time.Time) {
+	burst := float64(max(rl.Burst, 1))
+	switch {
+	case b.last.IsZero():
+		b.tokens = burst
+	case now.After(b.last):
+		b.tokens += float64(now.Sub(b.last)) / float64(rl.Every)
+		b.tokens = math.Min(b.tokens, burst)
+	default:
+		return
+	}
+	b.last = now
+}
+
+// wait returns how long it will be until there's a token in the bucket.
+// A limit with no interval never waits.
+func (b *bucket) wait(rl RateLimit, now time.Time) time.Duration {
+	if rl.Every <= 0 {
+		return 0
+	}
+	b.refill(rl, now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(rl.Every))
+}
+
+// full returns true if the bucket is as full as it can get.
+func (b *bucket) full(rl RateLimit, now time.Time) bool {
+	if rl.Every <= 0 {
+		return true
+	}
+	b.refill(rl, now)
+	return b.tokens >= float64(max(rl.Burst, 1))
+}
+
+// take removes a token from the bucket, if there is one.
+func (b *bucket) take() {
+	if b.tokens >= 1 {
+		b.tokens--
+	}
+}
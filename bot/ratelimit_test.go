@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageLimited(t *testing.T) {
+	rl := RateLimit{Every: 15 * time.Second, Burst: 4}
+	u := &usage{}
+	now := time.Now()
+
+	// The first few in quick succession are allowed...
+	for i := 0; i < 5; i++ {
+		if u.limited(rl, now) {
+			t.Errorf("Use %d in a burst was limited.", i)
+		}
+	}
+	// ... but not one more.
+	if !u.limited(rl, now) {
+		t.Errorf("Use after burst wasn't limited.")
+	}
+	// After waiting long enough, another is allowed.
+	if u.limited(rl, now.Add(time.Minute)) {
+		t.Errorf("Use after waiting was limited.")
+	}
+	// And after waiting a long time, the whole burst is available.
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		if u.limited(rl, now) {
+			t.Errorf("Use %d in a burst after an hour was limited.", i)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	rl := RateLimit{Every: 2 * time.Second, Burst: 3}
+	b := &bucket{}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if w := b.wait(rl, now); w != 0 {
+			t.Errorf("Take %d in a burst waited %s.", i, w)
+		}
+		b.take()
+	}
+	if w := b.wait(rl, now); w != 2*time.Second {
+		t.Errorf("Take after burst waited %s, want 2s.", w)
+	}
+	if w := b.wait(rl, now.Add(1500*time.Millisecond)); w != 500*time.Millisecond {
+		t.Errorf("Take after 1.5s waited %s, want 500ms.", w)
+	}
+	if b.full(rl, now.Add(5*time.Second)) {
+		t.Errorf("Bucket full after 5s.")
+	}
+	if !b.full(rl, now.Add(6*time.Second)) {
+		t.Errorf("Bucket not full after 6s.")
+	}
+	// No interval means no limit.
+	if w := b.wait(RateLimit{}, now); w != 0 {
+		t.Errorf("Unlimited bucket waited %s.", w)
+	}
+}
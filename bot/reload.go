@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fluffle/golog/logging"
+)
+
+// currentConfig returns the config the bot is running with.
+func currentConfig() *Config {
+	if bot == nil {
+		return &Config{}
+	}
+	bot.cfgLock.RLock()
+	defer bot.cfgLock.RUnlock()
+	if bot.config == nil {
+		return &Config{}
+	}
+	return bot.config
+}
+
+// Reload re-reads the --config file and applies it to the running bot
+// without disconnecting from any servers, returning a list of changes.
+func Reload() ([]string, error) {
+	if *config == "" {
+		return nil, fmt.Errorf("there's no --config file to reload")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	cfg, err := loadConfig(*config)
+	if err != nil {
+		return nil, err
+	}
+	bot.cfgLock.Lock()
+	old := bot.config
+	bot.config = cfg
+	bot.cfgLock.Unlock()
+
+	changes := old.diff(cfg)
+	changes = append(changes, bot.servers.Reconfigure(cfg)...)
+	for _, c := range changes {
+		logging.Info("Config reload: %s", c)
+	}
+	return changes, nil
+}
+
+func reload(ctx *Context) {
+	changes, err := Reload()
+	if err != nil {
+		ctx.ReplyN("Couldn't reload config: %v", err)
+		return
+	}
+	if len(changes) == 0 {
+		ctx.ReplyN("Config reloaded, nothing changed.")
+		return
+	}
+	ctx.ReplyN("Config reloaded: %s.", strings.Join(changes, "; "))
+}
+
+// diff describes changes to the settings that are applied by swapping
+// in the new config, i.e. everything except the networks.
+func (old *Config) diff(cfg *Config) []string {
+	changes := []string{}
+	if add, del := diffStrings(old.Ignore, cfg.Ignore); len(add)+len(del) > 0 {
+		changes = append(changes, "ignore "+describe(add, del))
+	}
+	chans := map[string]bool{}
+	for ch := range old.DisabledDrivers {
+		chans[ch] = true
+	}
+	for ch := range cfg.DisabledDrivers {
+		chans[ch] = true
+	}
+	for _, ch := range sortedKeys(chans) {
+		add, del := diffStrings(old.DisabledDrivers[ch], cfg.DisabledDrivers[ch])
+		if len(add)+len(del) > 0 {
+			changes = append(changes, "drivers disabled in "+ch+" "+describe(add, del))
+		}
+	}
+	names := map[string]bool{}
+	for name := range old.RateLimits {
+		names[name] = true
+	}
+	for name := range cfg.RateLimits {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		was, wok := old.RateLimits[name]
+		now, nok := cfg.RateLimits[name]
+		switch {
+		case !wok:
+			changes = append(changes, fmt.Sprintf("%s rate limit set to %s", name, now))
+		case !nok:
+			changes = append(changes, fmt.Sprintf("%s rate limit reset to default", name))
+		case was != now:
+			changes = append(changes, fmt.Sprintf("%s rate limit %s -> %s", name, was, now))
+		}
+	}
+	chans = map[string]bool{}
+	for ch := range old.Triggers {
+		chans[ch] = true
+	}
+	for ch := range cfg.Triggers {
+		chans[ch] = true
+	}
+	for _, ch := range sortedKeys(chans) {
+		was, now := old.Triggers[ch], cfg.Triggers[ch]
+		switch {
+		case was == "":
+			changes = append(changes, fmt.Sprintf("triggers in %s set to '%s'", ch, now))
+		case now == "":
+			changes = append(changes, "triggers in "+ch+" removed")
+		case was != now:
+			changes = append(changes, fmt.Sprintf("triggers in %s '%s' -> '%s'", ch, was, now))
+		}
+	}
+	if !reflect.DeepEqual(old.API.Tokens, cfg.API.Tokens) {
+		changes = append(changes, "api tokens changed")
+	}
+	if !reflect.DeepEqual(old.API.Templates, cfg.API.Templates) {
+		changes = append(changes, "api templates changed")
+	}
+	return changes
+}
+
+// Reconfigure applies the configuration of each network to its server,
+// joining and parting channels and changing nick as necessary.
+func (ss *serverSet) Reconfigure(cfg *Config) []string {
+	changes := []string{}
+	if ss.console != nil {
+		// There aren't any servers to reconfigure.
+		return changes
+	}
+	byName := map[string]reconfigurer{}
+	protocols := map[string]string{}
+	for _, s := range ss.servers {
+		byName[s.Network().Name] = s
+		protocols[s.Network().Name] = protocolIRC
+	}
+	for _, c := range ss.chats {
+		byName[c.Network()] = c
+		protocols[c.Network()] = protocolChat
+	}
+	for _, n := range cfg.Networks {
+		s, ok := byName[n.Name]
+		switch {
+		case !ok:
+			changes = append(changes, n.Name+": new network, restart to connect")
+			continue
+		case protocols[n.Name] != n.Protocol:
+			changes = append(changes, n.Name+": protocol changed, restart to apply")
+		default:
+			changes = append(changes, s.reconfigure(n)...)
+		}
+		delete(byName, n.Name)
+	}
+	for _, name := range sortedKeys(byName) {
+		changes = append(changes, name+": removed network, restart to disconnect")
+	}
+	return changes
+}
+
+// reconfigurer is implemented by servers and chatServers.
+type reconfigurer interface {
+	reconfigure(n *Network) []string
+}
+
+func (s *server) reconfigure(n *Network) []string {
+	old := s.Network()
+	s.setNetwork(n)
+	changes := []string{}
+	if old.Nick != n.Nick {
+		if s.Connected() {
+			s.Nick(n.Nick)
+		}
+		changes = append(changes, fmt.Sprintf("nick %s -> %s", old.Nick, n.Nick))
+	}
+	cl := channelsFor(n.Name)
+	join, part := diffStrings(cl.Channels(old.Channels), cl.Channels(n.Channels))
+	if s.Connected() {
+		for _, ch := range join {
+			s.Join(ch)
+		}
+		for _, ch := range part {
+			s.Part(ch)
+		}
+	}
+	if len(join)+len(part) > 0 {
+		changes = append(changes, "channels "+describe(join, part))
+	}
+	if old.Pause != n.Pause {
+		changes = append(changes, fmt.Sprintf("reconnect pause %s -> %s", old.Pause, n.Pause))
+	}
+	if old.Oper != n.Oper || old.VHost != n.VHost {
+		changes = append(changes, "oper/vhost changed, used on reconnect")
+	}
+	if old.Server != n.Server || old.Ident != n.Ident || old.Realname != n.Realname ||
+		old.TLS != n.TLS || old.SASL != n.SASL {
+		changes = append(changes, "connection settings changed, restart to apply")
+	}
+	for i, c := range changes {
+		changes[i] = n.Name + ": " + c
+	}
+	return changes
+}
+
+// diffStrings returns the strings added to and removed from old in neu.
+func diffStrings(old, neu []string) (add, del []string) {
+	was := map[string]bool{}
+	for _, s := range old {
+		was[s] = true
+	}
+	for _, s := range neu {
+		if !was[s] {
+			add = append(add, s)
+		}
+		delete(was, s)
+	}
+	return add, sortedKeys(was)
+}
+
+func describe(add, del []string) string {
+	s := []string{}
+	for _, a := range add {
+		s = append(s, "+"+a)
+	}
+	for _, d := range del {
+		s = append(s, "-"+d)
+	}
+	return strings.Join(s, " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffStrings(t *testing.T) {
+	tests := []struct {
+		old, neu, add, del []string
+	}{
+		{nil, nil, nil, []string{}},
+		{[]string{"a", "b"}, []string{"a", "b"}, nil, []string{}},
+		{[]string{"a"}, []string{"a", "c"}, []string{"c"}, []string{}},
+		{[]string{"c", "b", "a"}, []string{"d"}, []string{"d"}, []string{"a", "b", "c"}},
+	}
+	for _, test := range tests {
+		add, del := diffStrings(test.old, test.neu)
+		if !reflect.DeepEqual(add, test.add) || !reflect.DeepEqual(del, test.del) {
+			t.Errorf("diffStrings(%q, %q) = %q, %q; want %q, %q",
+				test.old, test.neu, add, del, test.add, test.del)
+		}
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	old, err := parseConfig([]byte(`
+networks: [{server: "a:1"}]
+ignore: [Spammer, "*!*@evil.org"]
+disabled_drivers:
+  "#Serious": [karma, Markov]
+rate_limits:
+  quote: {every: 15s, burst: 4}
+  url: {every: 1m, burst: 1}
+triggers: {"#a": "!", "#B": "."}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig(old) returned error: %v", err)
+	}
+	neu, err := parseConfig([]byte(`
+networks: [{server: "a:1"}]
+ignore: [spammer, troll]
+disabled_drivers:
+  "#serious": [karma]
+  "#other": [fact]
+rate_limits:
+  quote: {every: 10s, burst: 4}
+  calc: {every: 5s, burst: 2}
+triggers: {"#b": "!.", "#c": "@"}
+`))
+	if err != nil {
+		t.Fatalf("parseConfig(new) returned error: %v", err)
+	}
+
+	want := []string{
+		"ignore +troll -*!*@evil.org",
+		"drivers disabled in #other +fact",
+		"drivers disabled in #serious -markov",
+		"calc rate limit set to 1 per 5s, burst 2",
+		"quote rate limit 1 per 15s, burst 4 -> 1 per 10s, burst 4",
+		"url rate limit reset to default",
+		"triggers in #a removed",
+		"triggers in #b '.' -> '!.'",
+		"triggers in #c set to '@'",
+	}
+	if got := old.diff(neu); !reflect.DeepEqual(got, want) {
+		t.Errorf("diff() = %q\nwant %q", got, want)
+	}
+	if got := neu.diff(neu); len(got) != 0 {
+		t.Errorf("diff() of identical configs = %q", got)
+	}
+}
@@ -0,0 +1,472 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/collections/jobs"
+	"github.com/fluffle/sp0rkle/util/datetime"
+	"github.com/fluffle/sp0rkle/util/schedule"
+)
+
+// The longest the scheduler sleeps without checking for due jobs.
+const maxSleep = time.Hour
+
+// A JobFunc does a scheduled job's work. It is passed Contexts for the
+// networks the bot is connected to, which may be none, and returns an
+// error if the job failed.
+type JobFunc func(ctxs []*Context) error
+
+// JobOpt sets optional properties of a job when it is scheduled.
+type JobOpt func(*job)
+
+// Disabled jobs don't run until an admin enables them.
+func Disabled() JobOpt {
+	return func(j *job) {
+		j.defEnabled = false
+	}
+}
+
+type job struct {
+	name string
+	fn   JobFunc
+	// What the job was scheduled with, which admins can override.
+	defSpec    string
+	defEnabled bool
+	// Set for jobs admins added, which are deleted rather than replaced.
+	defined bool
+
+	sched   schedule.Schedule
+	enabled bool
+	// When the job will next run, if it is enabled.
+	next time.Time
+}
+
+// scheduler runs jobs on cron-style or interval schedules, see
+// util/schedule. How admins have changed jobs, how they've been running,
+// and the jobs admins have added, are persisted in the jobs collection.
+type scheduler struct {
+	sync.Mutex
+	set map[string]*job
+	// Jobs that are running, by name rather than *job so that a job that
+	// is replaced while it runs isn't run twice at once, or never again.
+	running map[string]bool
+	// Set by start, once the database is available.
+	store *jobs.Collection
+	// Contexts for the transports we're connected to, passed to jobs.
+	conns map[Transport]*Context
+	// Tells the run loop that jobs have changed.
+	wake chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		set:     make(map[string]*job),
+		running: make(map[string]bool),
+		conns:   make(map[Transport]*Context),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// now returns the current time in the bot's --timezone, which cron-style
+// schedules are in.
+func now() time.Time {
+	if tz := datetime.TZ(); tz != nil {
+		return time.Now().In(tz)
+	}
+	return time.Now()
+}
+
+// Add schedules fn to run as the job name, replacing any job with that
+// name. Admins can change spec, and whether the job is enabled.
+func (s *scheduler) Add(name, spec string, fn JobFunc, opts ...JobOpt) error {
+	if _, err := schedule.Parse(spec); err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	j := &job{name: strings.ToLower(name), fn: fn, defSpec: spec, defEnabled: true}
+	for _, opt := range opts {
+		opt(j)
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.add(j)
+	return nil
+}
+
+// add schedules j. s must be locked.
+func (s *scheduler) add(j *job) {
+	s.set[j.name] = j
+	s.apply(j)
+	s.poke()
+}
+
+// define schedules a job that admins added. Jobs that drivers have
+// scheduled under the same name take precedence.
+func (s *scheduler) define(sj *jobs.Job) {
+	s.Lock()
+	defer s.Unlock()
+	if j, ok := s.set[sj.Name]; ok && !j.defined {
+		logging.Warn("Not scheduling job %s, it's scheduled by the bot.", sj.Name)
+		return
+	}
+	s.add(&job{name: sj.Name, fn: sayJob(sj.Target, sj.Text),
+		defSpec: sj.Spec, defEnabled: true, defined: true})
+}
+
+// sayJob returns a JobFunc that says text in ch, on every network the
+// bot is in ch on.
+func sayJob(ch, text string) JobFunc {
+	return func(ctxs []*Context) error {
+		said := false
+		for _, ctx := range ctxs {
+			if ctx.IsOn(ch, ctx.Me()) {
+				ctx.Privmsg(ch, text)
+				said = true
+			}
+		}
+		if !said {
+			return fmt.Errorf("not in %s", ch)
+		}
+		return nil
+	}
+}
+
+// Remove stops the job name from running again.
+func (s *scheduler) Remove(name string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.set, strings.ToLower(name))
+	s.poke()
+}
+
+// apply works out a job's schedule and when it will next run from its
+// defaults and anything admins have changed. s must be locked.
+func (s *scheduler) apply(j *job) {
+	stored := &jobs.Job{}
+	if s.store != nil {
+		stored = s.store.Get(j.name)
+	}
+	spec := j.defSpec
+	if stored.Schedule != "" {
+		spec = stored.Schedule
+	}
+	sched, err := schedule.Parse(spec)
+	if err != nil {
+		logging.Error("Job %s has bad schedule %q, using %q: %v",
+			j.name, spec, j.defSpec, err)
+		sched, _ = schedule.Parse(j.defSpec)
+	}
+	j.sched = sched
+	switch stored.State {
+	case jobs.Enabled:
+		j.enabled = true
+	case jobs.Disabled:
+		j.enabled = false
+	default:
+		j.enabled = j.defEnabled
+	}
+	j.next = time.Time{}
+	if j.enabled {
+		j.next = j.sched.Next(now())
+	}
+}
+
+// poke wakes up the run loop to look at the jobs again.
+func (s *scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// start runs jobs as they become due until ctx is done.
+func (s *scheduler) start(ctx context.Context, store *jobs.Collection) {
+	s.load(store)
+	Go(func() { s.loop(ctx) })
+}
+
+// load applies what is stored to the jobs scheduled so far, and schedules
+// the jobs that admins have added.
+func (s *scheduler) load(store *jobs.Collection) {
+	s.Lock()
+	s.store = store
+	for _, j := range s.set {
+		s.apply(j)
+	}
+	s.Unlock()
+	for _, sj := range store.Defined() {
+		s.define(sj)
+	}
+}
+
+func (s *scheduler) loop(ctx context.Context) {
+	timer := time.NewTimer(maxSleep)
+	defer timer.Stop()
+	for {
+		timer.Reset(s.runDue())
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runDue starts any jobs that are due, and returns how long until the
+// next one is.
+func (s *scheduler) runDue() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+	t, sleep := now(), maxSleep
+	for _, j := range s.set {
+		if !j.enabled || j.next.IsZero() {
+			continue
+		}
+		if !j.next.After(t) {
+			j.next = j.sched.Next(t)
+			if s.running[j.name] {
+				logging.Warn("Job %s is still running, skipping this run.", j.name)
+			} else {
+				s.running[j.name] = true
+				job := j
+				Go(func() { s.run(job) })
+			}
+		}
+		if d := j.next.Sub(t); !j.next.IsZero() && d < sleep {
+			sleep = d
+		}
+	}
+	return sleep
+}
+
+// run runs j and records how it went.
+func (s *scheduler) run(j *job) error {
+	start := time.Now()
+	err := s.call(j)
+	took := time.Since(start)
+	result := "ok"
+	if err != nil {
+		result = "failed"
+		logging.Error("Job %s failed: %v", j.name, err)
+	}
+	jobRuns.Inc(j.name, result)
+	jobSeconds.Observe(took.Seconds(), j.name)
+
+	s.Lock()
+	delete(s.running, j.name)
+	store := s.store
+	s.Unlock()
+	if store != nil {
+		store.Update(j.name, func(sj *jobs.Job) {
+			sj.Runs++
+			sj.LastRun, sj.LastDuration, sj.LastError = start, took, ""
+			if err != nil {
+				sj.Failures++
+				sj.LastError = err.Error()
+			}
+		})
+	}
+	return err
+}
+
+// call calls j's func, turning a panic into an error after reporting it.
+func (s *scheduler) call(j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e := panicError(r)
+			reportError(nil, nil, e)
+			err = fmt.Errorf("%s (error %s)", e.Message, e.Id)
+		}
+	}()
+	return j.fn(s.contexts())
+}
+
+// The scheduler handles both CONNECTED and DISCONNECTED events, to keep
+// track of the servers jobs can talk to.
+func (s *scheduler) Handle(conn *client.Conn, line *client.Line) {
+	s.connected(bot.servers.Transport(conn), reqContext(conn, line))
+}
+
+// handleLine does the same for other transports.
+func (s *scheduler) handleLine(t Transport, line *client.Line) {
+	s.connected(t, newContext(t, line))
+}
+
+func (s *scheduler) connected(t Transport, ctx *Context) {
+	s.Lock()
+	defer s.Unlock()
+	switch ctx.Cmd {
+	case client.CONNECTED:
+		s.conns[t] = ctx
+	case client.DISCONNECTED:
+		delete(s.conns, t)
+	}
+}
+
+func (s *scheduler) contexts() []*Context {
+	s.Lock()
+	defer s.Unlock()
+	ctxs := make([]*Context, 0, len(s.conns))
+	for _, ctx := range s.conns {
+		ctxs = append(ctxs, ctx)
+	}
+	return ctxs
+}
+
+// get returns the job name, replying if there isn't one.
+func (s *scheduler) get(ctx *Context, name string) *job {
+	s.Lock()
+	defer s.Unlock()
+	j, ok := s.set[strings.ToLower(name)]
+	if !ok {
+		ctx.ReplyN("There's no job called '%s'.", name)
+		return nil
+	}
+	return j
+}
+
+// update changes what is stored about j, then reapplies it. It returns
+// j's schedule afterwards.
+func (s *scheduler) update(j *job, fn func(*jobs.Job)) schedule.Schedule {
+	s.store.Update(j.name, fn)
+	s.Lock()
+	defer s.Unlock()
+	s.apply(j)
+	s.poke()
+	return j.sched
+}
+
+func listJobs(ctx *Context) {
+	s := bot.scheduler
+	s.Lock()
+	names := sortedKeys(s.set)
+	lines := []string{}
+	for _, name := range names {
+		j := s.set[name]
+		state := "disabled"
+		if s.running[name] {
+			state = "running now"
+		} else if j.enabled {
+			state = "next run at " + datetime.Format(j.next)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s, %s", name, j.sched, state))
+	}
+	s.Unlock()
+	if len(lines) == 0 {
+		ctx.ReplyN("No jobs have been scheduled.")
+		return
+	}
+	for i, name := range names {
+		stored := s.store.Get(name)
+		if stored.Runs == 0 {
+			lines[i] += ", never run."
+			continue
+		}
+		lines[i] += fmt.Sprintf(", last ran at %s for %s, %d runs, %d failed",
+			datetime.Format(stored.LastRun), stored.LastDuration.Round(time.Millisecond),
+			stored.Runs, stored.Failures)
+		if stored.LastError != "" {
+			lines[i] += ", last error: " + stored.LastError
+		} else {
+			lines[i] += "."
+		}
+	}
+	ctx.PageN(lines...)
+}
+
+func addJob(ctx *Context) {
+	s := bot.scheduler
+	name, spec := strings.ToLower(ctx.Arg("job")), ctx.Arg("schedule")
+	s.Lock()
+	_, exists := s.set[name]
+	s.Unlock()
+	if exists {
+		ctx.ReplyN("There's already a job called '%s'.", name)
+		return
+	}
+	sched, err := schedule.Parse(spec)
+	if err != nil {
+		ctx.ReplyN("Bad schedule '%s': %s.", spec, err)
+		return
+	}
+	sj := &jobs.Job{Name: name, Spec: spec,
+		Target: ctx.Arg("channel"), Text: ctx.Arg("text")}
+	s.store.Update(name, func(j *jobs.Job) {
+		j.Spec, j.Target, j.Text = sj.Spec, sj.Target, sj.Text
+	})
+	s.define(sj)
+	ctx.ReplyN("%s will run %s, saying that in %s.", name, sched, sj.Target)
+}
+
+func delJob(ctx *Context) {
+	s := bot.scheduler
+	j := s.get(ctx, ctx.Arg("job"))
+	if j == nil {
+		return
+	}
+	if !j.defined {
+		ctx.ReplyN("%s is scheduled by the bot, it can only be disabled.", j.name)
+		return
+	}
+	s.store.Delete(j.name)
+	s.Remove(j.name)
+	ctx.ReplyN("Deleted %s.", j.name)
+}
+
+func enableJob(ctx *Context) {
+	if j := bot.scheduler.get(ctx, ctx.Arg("job")); j != nil {
+		sched := bot.scheduler.update(j, func(sj *jobs.Job) { sj.State = jobs.Enabled })
+		ctx.ReplyN("%s will run %s.", j.name, sched)
+	}
+}
+
+func disableJob(ctx *Context) {
+	if j := bot.scheduler.get(ctx, ctx.Arg("job")); j != nil {
+		bot.scheduler.update(j, func(sj *jobs.Job) { sj.State = jobs.Disabled })
+		ctx.ReplyN("%s won't run until it is enabled again.", j.name)
+	}
+}
+
+func scheduleJob(ctx *Context) {
+	j := bot.scheduler.get(ctx, ctx.Arg("job"))
+	if j == nil {
+		return
+	}
+	spec := ctx.Arg("schedule")
+	if strings.ToLower(spec) == "default" {
+		spec = ""
+	} else if _, err := schedule.Parse(spec); err != nil {
+		ctx.ReplyN("Bad schedule '%s': %s.", spec, err)
+		return
+	}
+	sched := bot.scheduler.update(j, func(sj *jobs.Job) { sj.Schedule = spec })
+	ctx.ReplyN("%s will run %s.", j.name, sched)
+}
+
+func runJob(ctx *Context) {
+	s := bot.scheduler
+	j := s.get(ctx, ctx.Arg("job"))
+	if j == nil {
+		return
+	}
+	s.Lock()
+	if s.running[j.name] {
+		s.Unlock()
+		ctx.ReplyN("%s is already running.", j.name)
+		return
+	}
+	s.running[j.name] = true
+	s.Unlock()
+	start := time.Now()
+	if err := s.run(j); err != nil {
+		ctx.ReplyN("%s failed: %s", j.name, err)
+		return
+	}
+	ctx.ReplyN("Ran %s in %s.", j.name, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/jobs"
+	"github.com/fluffle/sp0rkle/db/dbtest"
+)
+
+func TestSchedulerReplaceRunning(t *testing.T) {
+	s := newScheduler()
+	calls := 0
+	fn := func([]*Context) error {
+		calls++
+		return nil
+	}
+	if err := s.Add("poll", "every 5m", fn); err != nil {
+		t.Fatal(err)
+	}
+	// Start the job as runDue does, then replace it while it runs, as
+	// changing a driver's settings can.
+	old := s.set["poll"]
+	s.running["poll"] = true
+	if err := s.Add("Poll", "every 10m", fn); err != nil {
+		t.Fatal(err)
+	}
+	if !s.running["poll"] {
+		t.Errorf("Replacing a running job forgot it is running.")
+	}
+	s.run(old)
+	if calls != 1 || s.running["poll"] {
+		t.Errorf("After run, calls = %d, running = %t; want 1, false", calls, s.running["poll"])
+	}
+	if j := s.set["poll"]; j == old || j.defSpec != "every 10m" {
+		t.Errorf("Job wasn't replaced: %+v", j)
+	}
+}
+
+func TestSchedulerLoad(t *testing.T) {
+	dbtest.Init(t)
+	store := jobs.Init()
+	// A job added by an admin before the bot restarted, and one that
+	// clashes with a job a driver schedules.
+	for _, name := range []string{"standup", "poll"} {
+		store.Update(name, func(j *jobs.Job) {
+			j.Spec, j.Target, j.Text = "0 9 * * mon-fri", "#dev", "Standup time!"
+		})
+	}
+	store.Update("quiet", func(j *jobs.Job) { j.State = jobs.Disabled })
+
+	s := newScheduler()
+	if err := s.Add("poll", "every 5m", func([]*Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	s.load(store)
+	if len(s.set) != 2 {
+		t.Errorf("Loaded jobs %v, want poll and standup.", sortedKeys(s.set))
+	}
+	if j := s.set["standup"]; j == nil || !j.defined || !j.enabled ||
+		j.defSpec != "0 9 * * mon-fri" || j.next.IsZero() {
+		t.Errorf("standup = %+v", j)
+	}
+	if j := s.set["poll"]; j.defined || j.defSpec != "every 5m" {
+		t.Errorf("poll was replaced: %+v", j)
+	}
+}
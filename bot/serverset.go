@@ -1,11 +1,8 @@
 package bot
 
 import (
-	"crypto/tls"
 	"flag"
-	"fmt"
 	"os"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -23,41 +20,88 @@ var (
 		"Use SSL when connecting to servers.")
 	pause *time.Duration = flag.Duration("pause", 300*time.Second,
 		"Wait time between server reconnection attempts.")
+	config *string = flag.String("config", "",
+		"Path to a YAML file describing the networks to connect to. "+
+			"If set, --servers, --ssl, --channels, --oper and --vhost are "+
+			"ignored, and --nick and --pause are only defaults.")
 )
 
 type server struct {
 	*client.Conn
-	hostport string
 	shutdown bool
 
+	// The network's configuration can change if the config is reloaded.
+	// Our nick can change too, and goirc's Conn.Me() isn't safe to call
+	// from concurrent handlers, so it's kept here by setMe.
+	mu      sync.RWMutex
+	network *Network
+	me      string
+
+	out  *outQueue
 	wg   *sync.WaitGroup
 	wait chan struct{}
 }
 
 func (s *server) connectLoop() {
-	for {
-		logging.Info("Connecting to %s.", s.hostport)
+	// Decrement wait group when connectLoop exits.
+	defer s.wg.Done()
+	defer s.out.stop()
+	for attempt := 0; ; attempt++ {
+		n := s.Network()
+		if attempt > 0 {
+			reconnects.Inc(n.Name)
+		}
+		logging.Info("Connecting to %s (%s).", n.Server, n.Name)
 		if err := s.Connect(); err == nil {
+			setConnected(n.Name, true)
 			// Wait here for a disconnect signal
 			<-s.wait
+			setConnected(n.Name, false)
 			if s.shutdown {
-				break
+				return
 			}
 		} else {
 			logging.Error("Connection error: %s", err)
+			setConnected(n.Name, false)
 			select {
 			case <-s.wait:
 				// If we are waiting for a reconnect to this server
 				// and someone calls Shutdown, we need to shut down
 				if s.shutdown {
-					break
+					return
 				}
-			case <-time.After(*pause):
+			case <-time.After(n.Pause):
 			}
 		}
 	}
-	// Decrement wait group when connectLoop exits.
-	s.wg.Done()
+}
+
+func (s *server) Network() *Network {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.network
+}
+
+func (s *server) setNetwork(n *Network) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.network = n
+}
+
+func (s *server) Me() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.me
+}
+
+// setMe keeps our nick up to date. The state tracker has seen the line
+// by the time this is called, and is safe to ask.
+func (s *server) setMe(conn *client.Conn, line *client.Line) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if line.Cmd == client.CONNECTED || strings.EqualFold(line.Nick, s.me) {
+		s.me = conn.StateTracker().Me().Nick
+	}
 }
 
 type ServerSet interface {
@@ -65,48 +109,61 @@ type ServerSet interface {
 	Connect() chan bool
 	HandleAll(event string, h client.Handler)
 	HandleAllBG(event string, h client.Handler)
+	Network(conn *client.Conn) *Network
+	Connected() map[string]bool
+	Reconfigure(cfg *Config) []string
+	Transport(conn *client.Conn) Transport
 	Shutdown(rebuild bool)
 }
 
 type serverSet struct {
 	servers map[*client.Conn]*server
+	// Networks using the chat protocol rather than IRC.
+	chats []*chatServer
+	// Set instead of any servers in --console mode.
+	console *consoleTransport
 	wg      *sync.WaitGroup
 	rebuild chan bool
 }
 
-func newServerSet() *serverSet {
-	list := strings.Split(*servers, ",")
-	if len(list) == 0 {
-		// Don't call logging.Fatal as we don't want a backtrace in this case
-		logging.Error("--server option required. \nOptions are:\n")
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-
+func newServerSet(cfg *Config) *serverSet {
 	ss := &serverSet{
 		servers: make(map[*client.Conn]*server),
 		rebuild: make(chan bool),
 		wg:      &sync.WaitGroup{},
 	}
-	for _, hostport := range list {
-		// Configure IRC client
-		cfg := client.NewConfig(*nick, "boing", "slowly becoming sp0rkle")
-		cfg.Flood = true
-		if *ssl {
-			cfg.SSL = true
-			cfg.SSLConfig = &tls.Config{
-				ServerName: strings.Split(hostport, ":")[0],
-			}
+	if *console {
+		ss.console = newConsole(os.Stdout)
+		return ss
+	}
+	for _, n := range cfg.Networks {
+		if n.Protocol == protocolChat {
+			ss.chats = append(ss.chats, newChatServer(n, ss.wg))
+			continue
+		}
+		c, err := n.clientConfig()
+		if err != nil {
+			logging.Fatal("Couldn't configure network %q: %v", n.Name, err)
 		}
-		cfg.Recover = unfail
-		cfg.Server = hostport
-		conn := client.Client(cfg)
-		ss.servers[conn] = &server{
-			Conn:     conn,
-			hostport: hostport,
-			wg:       ss.wg,
-			wait:     make(chan struct{}),
+		conn := client.Client(c)
+		conn.EnableStateTracking()
+		conn.HandleFunc("353", fixNames)
+		out := newOutQueue(func(line string) {
+			if conn.Connected() {
+				conn.Raw(line)
+			}
+		})
+		s := &server{
+			Conn:    conn,
+			network: n,
+			me:      n.Nick,
+			out:     out,
+			wg:      ss.wg,
+			wait:    make(chan struct{}),
 		}
+		conn.HandleFunc(client.CONNECTED, s.setMe)
+		conn.HandleFunc(client.NICK, s.setMe)
+		ss.servers[conn] = s
 	}
 	ss.HandleAll(client.DISCONNECTED, ss)
 	return ss
@@ -114,9 +171,23 @@ func newServerSet() *serverSet {
 
 func (ss *serverSet) Connect() chan bool {
 	for _, server := range ss.servers {
+		go server.out.run()
 		go server.connectLoop()
 		ss.wg.Add(1)
 	}
+	for _, chat := range ss.chats {
+		ss.wg.Add(1)
+		go chat.out.run()
+		go chat.connectLoop()
+	}
+	if ss.console != nil {
+		ss.wg.Add(1)
+		go func() {
+			defer ss.wg.Done()
+			// Running out of input is like being sent SIGTERM.
+			ss.console.run(os.Stdin, func() { go Shutdown() })
+		}()
+	}
 	return ss.rebuild
 }
 
@@ -138,6 +209,12 @@ func (ss *serverSet) Shutdown(rebuild bool) {
 			server.wait <- struct{}{}
 		}
 	}
+	for _, chat := range ss.chats {
+		chat.shutdown()
+	}
+	if ss.console != nil {
+		ss.console.shutdown()
+	}
 	// Wait for all connectLoops to terminate
 	ss.wg.Wait()
 	ss.rebuild <- rebuild
@@ -146,62 +223,49 @@ func (ss *serverSet) Shutdown(rebuild bool) {
 // serverSet's Handle() deals with disconnects from individual servers
 func (ss *serverSet) Handle(conn *client.Conn, line *client.Line) {
 	server := ss.servers[conn]
-	logging.Info("Disconnected from %s...", server.hostport)
+	logging.Info("Disconnected from %s...", server.Network().Server)
+	server.out.clear()
 	server.wait <- struct{}{}
 }
 
-// HandleAll() registers Handlers with all the servers in the set
-func (ss *serverSet) HandleAll(ev string, h client.Handler) {
-	for conn, _ := range ss.servers {
-		conn.Handle(ev, h)
+// Network() returns the configuration of the network conn is connected to.
+func (ss *serverSet) Network(conn *client.Conn) *Network {
+	if server, ok := ss.servers[conn]; ok {
+		return server.Network()
 	}
+	return nil
 }
 
-// HandleAllBG() registers background Handlers with all the servers in the set
-func (ss *serverSet) HandleAllBG(ev string, h client.Handler) {
-	for conn, _ := range ss.servers {
-		conn.HandleBG(ev, h)
+// Connected() returns whether the bot is connected to each network, by name.
+func (ss *serverSet) Connected() map[string]bool {
+	connected := make(map[string]bool)
+	for _, server := range ss.servers {
+		connected[server.Network().Name] = server.Connected()
+	}
+	for _, chat := range ss.chats {
+		connected[chat.Network()] = chat.connected()
+	}
+	if ss.console != nil {
+		connected[ss.console.Network()] = true
 	}
+	return connected
 }
 
-// Catch, log, and complain about panics in handlers.
-func unfail(conn *client.Conn, line *client.Line) {
-	if err := recover(); err != nil {
-		// Depth 4 is where our code usually starts.
-		// But if the panic is somewhere in the depths of the standard
-		// library or dependency code it's helpful to know what
-		// our code was up to at the time too, so walk up the stack.
-		callers := make([]uintptr, 20)
-		depth := 4
-		n := runtime.Callers(depth, callers)
-		frames := runtime.CallersFrames(callers[:n])
-		msgs := []string{fmt.Sprintf("panic: %v frames:", err)}
-		for frame, ok := frames.Next(); ok; frame, ok = frames.Next() {
-			msgs = append(msgs, fmt.Sprintf("(%d) %s at %s:%d",
-				depth, trimPath(frame.Function), trimPath(frame.File), frame.Line))
-			depth++
-		}
-		msg := strings.Join(msgs, ", ")
-		logging.Error(msg)
-		conn.Privmsg(line.Target(), msg)
-	}
+// Transport() returns the Transport for conn.
+func (ss *serverSet) Transport(conn *client.Conn) Transport {
+	return ircTransport{ss.servers[conn]}
 }
 
-func trimPath(path string) string {
-	prefixes := []string{"sp0rkle/", "fluffle/", runtime.GOROOT() + "/src/"}
-	for _, prefix := range prefixes {
-		trimmed, ok := trim(path, prefix)
-		if ok {
-			return trimmed
-		}
+// HandleAll() registers Handlers with all the servers in the set
+func (ss *serverSet) HandleAll(ev string, h client.Handler) {
+	for conn, _ := range ss.servers {
+		conn.Handle(ev, h)
 	}
-	return path
 }
 
-func trim(path string, prefix string) (string, bool) {
-	i := strings.Index(path, prefix)
-	if i < 0 {
-		return path, false
+// HandleAllBG() registers background Handlers with all the servers in the set
+func (ss *serverSet) HandleAllBG(ev string, h client.Handler) {
+	for conn, _ := range ss.servers {
+		conn.HandleBG(ev, h)
 	}
-	return path[i+len(prefix):], true
 }
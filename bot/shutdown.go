@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"sync"
+)
+
+// inflight keeps track of running handlers and background goroutines,
+// so that shutdown can wait for them to finish.
+type inflight struct {
+	sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// start returns false if we're shutting down and nothing new should start.
+func (f *inflight) start() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return false
+	}
+	f.wg.Add(1)
+	return true
+}
+
+func (f *inflight) done() {
+	f.wg.Done()
+}
+
+// wait stops anything new starting and waits for everything running to
+// finish, or ctx to be done. It returns false in the latter case.
+func (f *inflight) wait(ctx context.Context) bool {
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Go runs fn in a goroutine that Wait will wait for. Long-running
+// goroutines should return once Ctx() is cancelled. fn is not run
+// at all if the bot is shutting down.
+func Go(fn func()) {
+	if !bot.inflight.start() {
+		return
+	}
+	go func() {
+		defer bot.inflight.done()
+		fn()
+	}()
+}
+
+// Wait should be called after cancelling the context passed to Init and
+// disconnecting from servers. It waits for running handlers, jobs and
+// goroutines started by Go to finish, returning false if ctx is done first.
+func Wait(ctx context.Context) bool {
+	return bot.inflight.wait(ctx)
+}
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInflight(t *testing.T) {
+	f := &inflight{}
+	if !f.start() {
+		t.Fatalf("start() before wait() returned false.")
+	}
+	release := make(chan struct{})
+	go func() {
+		<-release
+		f.done()
+	}()
+
+	// wait() times out while something is still running...
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if f.wait(ctx) {
+		t.Errorf("wait() returned true with something still running.")
+	}
+	// ... and nothing new can start once it has been called.
+	if f.start() {
+		t.Errorf("start() after wait() returned true.")
+	}
+
+	close(release)
+	if !f.wait(context.Background()) {
+		t.Errorf("wait() returned false after everything finished.")
+	}
+}
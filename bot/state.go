@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/goirc/state"
+)
+
+// goirc's state tracker knows about the channels the bot is in, and the
+// nicks in those channels. The methods below look things up for the
+// network a Context's line came from, ignoring case, which the tracker
+// doesn't. We can only see nicks in our channels, so they don't know
+// about anyone else. Other transports don't track state at all.
+
+// The channel modes that nicks' prefixes in NAMES replies stand for.
+var prefixModes = map[rune]string{'~': "q", '&': "a", '@': "o", '%': "h", '+': "v"}
+
+// With multi-prefix, NAMES replies list all of a nick's prefixes, e.g.
+// "@+alice". The tracker only strips the first, and so thinks "+alice" is
+// in the channel. fixNames runs after the tracker has handled the reply,
+// and puts the channel's real nicks and their modes in its place.
+func fixNames(conn *client.Conn, line *client.Line) {
+	st := conn.StateTracker()
+	if st == nil || len(line.Args) < 4 || st.GetChannel(line.Args[2]) == nil {
+		return
+	}
+	ch := line.Args[2]
+	for _, name := range strings.Fields(line.Args[len(line.Args)-1]) {
+		nick := strings.TrimLeft(name, "~&@%+")
+		prefixes := name[:len(name)-len(nick)]
+		if len(prefixes) < 2 {
+			continue
+		}
+		if st.GetNick(name[1:]) != nil {
+			st.Dissociate(ch, name[1:])
+		}
+		if st.GetNick(nick) == nil {
+			st.NewNick(nick)
+		}
+		if _, ok := st.IsOn(ch, nick); !ok {
+			st.Associate(ch, nick)
+		}
+		for _, p := range prefixes {
+			st.ChannelModes(ch, "+"+prefixModes[p], nick)
+		}
+	}
+}
+
+// tracker returns the state tracker for IRC servers, or nil for other
+// transports, which don't keep track of channels.
+func (ctx *Context) tracker() state.Tracker {
+	if t, ok := ctx.t.(ircTransport); ok {
+		return t.StateTracker()
+	}
+	return nil
+}
+
+// channel returns the state of a channel the bot is in, or nil.
+func (ctx *Context) channel(ch string) *state.Channel {
+	st := ctx.tracker()
+	if st == nil {
+		return nil
+	}
+	if c := st.GetChannel(ch); c != nil {
+		return c
+	}
+	for name := range st.Me().Channels {
+		if strings.EqualFold(name, ch) {
+			return st.GetChannel(name)
+		}
+	}
+	return nil
+}
+
+// nick returns the state of a nick that's in a channel with the bot, or nil.
+func (ctx *Context) nick(nick string) *state.Nick {
+	st := ctx.tracker()
+	if st == nil {
+		return nil
+	}
+	if n := st.GetNick(nick); n != nil {
+		return n
+	}
+	for name := range st.Me().Channels {
+		if c := st.GetChannel(name); c != nil {
+			for n := range c.Nicks {
+				if strings.EqualFold(n, nick) {
+					return st.GetNick(n)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Nicks returns the sorted nicks in ch, or nil if the bot isn't in it.
+func (ctx *Context) Nicks(ch string) []string {
+	c := ctx.channel(ch)
+	if c == nil {
+		return nil
+	}
+	return sortedKeys(c.Nicks)
+}
+
+// IsOn returns true if nick is in ch.
+func (ctx *Context) IsOn(ch, nick string) bool {
+	_, ok := ctx.Privs(ch, nick)
+	return ok
+}
+
+// Privs returns the modes nick has in ch, e.g. whether they're an op,
+// and false if they aren't in ch.
+func (ctx *Context) Privs(ch, nick string) (state.ChanPrivs, bool) {
+	c := ctx.channel(ch)
+	if c == nil {
+		return state.ChanPrivs{}, false
+	}
+	for n, cp := range c.Nicks {
+		if strings.EqualFold(n, nick) {
+			return *cp, true
+		}
+	}
+	return state.ChanPrivs{}, false
+}
+
+// Online returns true if nick is in any channel the bot is in.
+func (ctx *Context) Online(nick string) bool {
+	return ctx.nick(nick) != nil
+}
+
+// CommonChannels returns the sorted channels that nick and the bot are in.
+func (ctx *Context) CommonChannels(nick string) []string {
+	n := ctx.nick(nick)
+	if n == nil {
+		return nil
+	}
+	return sortedKeys(n.Channels)
+}
+
+// MyModes returns the bot's own user modes on the network.
+func (ctx *Context) MyModes() state.NickMode {
+	if st := ctx.tracker(); st != nil {
+		return *st.Me().Modes
+	}
+	return state.NickMode{}
+}
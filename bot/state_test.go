@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/goirc/client"
+)
+
+func TestState(t *testing.T) {
+	conn := client.Client(client.NewConfig("sp0rkle"))
+	ctx := &Context{t: ircTransport{&server{Conn: conn}}}
+	if ctx.IsOn("#chan", "alice") || ctx.Nicks("#chan") != nil {
+		t.Errorf("State known without a tracker.")
+	}
+
+	conn.EnableStateTracking()
+	st := conn.StateTracker()
+	st.NewChannel("#Chan")
+	st.NewChannel("#other")
+	st.NewNick("Alice")
+	st.NewNick("bob")
+	st.Associate("#Chan", "sp0rkle")
+	st.Associate("#Chan", "Alice")
+	st.Associate("#Chan", "bob")
+	st.Associate("#other", "sp0rkle")
+	st.Associate("#other", "Alice")
+	st.ChannelModes("#Chan", "+o", "Alice")
+
+	if got, want := ctx.Nicks("#chan"), []string{"Alice", "bob", "sp0rkle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Nicks(#chan) = %q, want %q", got, want)
+	}
+	if ctx.Nicks("#missing") != nil {
+		t.Errorf("Nicks(#missing) not nil")
+	}
+	if !ctx.IsOn("#CHAN", "alice") || ctx.IsOn("#other", "bob") {
+		t.Errorf("IsOn() wrong")
+	}
+	if p, ok := ctx.Privs("#chan", "ALICE"); !ok || !p.Op {
+		t.Errorf("Privs(#chan, alice) = %+v, %t; want op", p, ok)
+	}
+	if p, ok := ctx.Privs("#other", "alice"); !ok || p.Op {
+		t.Errorf("Privs(#other, alice) = %+v, %t; want no op", p, ok)
+	}
+	if !ctx.Online("BOB") || ctx.Online("carol") {
+		t.Errorf("Online() wrong")
+	}
+	if got, want := ctx.CommonChannels("alice"), []string{"#Chan", "#other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CommonChannels(alice) = %q, want %q", got, want)
+	}
+	if ctx.CommonChannels("carol") != nil {
+		t.Errorf("CommonChannels(carol) not nil")
+	}
+}
+
+func TestFixNames(t *testing.T) {
+	conn := client.Client(client.NewConfig("sp0rkle"))
+	ctx := &Context{t: ircTransport{&server{Conn: conn}}}
+	conn.EnableStateTracking()
+	st := conn.StateTracker()
+	st.NewChannel("#chan")
+	st.Associate("#chan", "sp0rkle")
+	// This is what the tracker makes of "@+alice bob %~carol".
+	for _, n := range []string{"+alice", "bob", "~carol"} {
+		st.NewNick(n)
+		st.Associate("#chan", n)
+	}
+	st.ChannelModes("#chan", "+o", "+alice")
+	st.ChannelModes("#chan", "+h", "~carol")
+
+	fixNames(conn, &client.Line{Cmd: "353",
+		Args: []string{"sp0rkle", "=", "#chan", "@+alice bob %~carol"}})
+	if got, want := ctx.Nicks("#chan"), []string{"alice", "bob", "carol", "sp0rkle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Nicks(#chan) = %q, want %q", got, want)
+	}
+	if st.GetNick("+alice") != nil || st.GetNick("~carol") != nil {
+		t.Errorf("Prefixed nicks still tracked.")
+	}
+	if p, _ := ctx.Privs("#chan", "alice"); !p.Op || !p.Voice {
+		t.Errorf("Privs(#chan, alice) = %+v, want op and voice", p)
+	}
+	if p, _ := ctx.Privs("#chan", "carol"); !p.HalfOp || !p.Owner {
+		t.Errorf("Privs(#chan, carol) = %+v, want halfop and owner", p)
+	}
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluffle/sp0rkle/util"
+)
+
+// How many things "did you mean" suggests at once.
+const maxSuggestions = 3
+
+// How often "did you mean" suggestions are made in each channel, unless
+// the config file's rate_limits says otherwise.
+var suggestLimit = RateLimit{Every: time.Minute, Burst: 1}
+
+// A SuggestFunc is asked what an addressed line that didn't match any
+// command might have meant. It returns true if the line already meant
+// something to it, in which case nothing is suggested, or otherwise
+// anything close to the line, closest first.
+type SuggestFunc func(ctx *Context) (known bool, close []string)
+
+func (cs *commandSet) Suggest(fn SuggestFunc) {
+	cs.Lock()
+	defer cs.Unlock()
+	cs.suggesters = append(cs.suggesters, fn)
+}
+
+// near returns the commands and aliases that the start of txt is a few
+// typos away from, closest first. Only commands that someone with role
+// could run in ch are included.
+func (cs *commandSet) near(txt string, role Role, ch string) []string {
+	cs.RLock()
+	prefixes := cs.aliases.Names()
+	for prefix, r := range cs.set {
+		if c, ok := r.(*command); ok && (c.role > role ||
+			c.driver != nil && !bot.policy.Enabled(c.driver.name, ch)) {
+			continue
+		}
+		prefixes = append(prefixes, strings.ToLower(prefix))
+	}
+	cs.RUnlock()
+
+	words := strings.Fields(strings.ToLower(txt))
+	dists := map[string]int{}
+	for _, prefix := range prefixes {
+		n := len(strings.Fields(prefix))
+		if n > len(words) {
+			continue
+		}
+		d := util.EditDistance(strings.Join(words[:n], " "), prefix)
+		if d > 0 && d <= util.MaxTypos(prefix) {
+			dists[prefix] = d
+		}
+	}
+	near := sortedKeys(dists)
+	sort.SliceStable(near, func(i, j int) bool {
+		if dists[near[i]] != dists[near[j]] {
+			return dists[near[i]] < dists[near[j]]
+		}
+		// "remnid list" is more likely to be "remind list" than "remind".
+		return len(near[i]) > len(near[j])
+	})
+	found := []string{}
+outer:
+	for _, prefix := range near {
+		for _, f := range found {
+			if strings.HasPrefix(f, prefix+" ") {
+				continue outer
+			}
+		}
+		found = append(found, prefix)
+	}
+	return found
+}
+
+// suggest replies to an addressed line that didn't match any command with
+// what it might have meant, unless that has been done too often lately.
+func (cs *commandSet) suggest(ctx *Context) {
+	if ctx.Text() == "" {
+		return
+	}
+	close := cs.near(ctx.Text(), ctx.Role(), ctx.Target())
+	cs.RLock()
+	suggesters := cs.suggesters
+	cs.RUnlock()
+	for _, fn := range suggesters {
+		known, more := fn(ctx)
+		if known {
+			return
+		}
+		close = append(close, more...)
+	}
+	if len(close) == 0 || RateLimited("suggest", ctx.Target(), suggestLimit) {
+		return
+	}
+	ctx.ReplyN("Did you mean %s?", orList(close[:min(len(close), maxSuggestions)]))
+}
+
+// orList returns "'a', 'b' or 'c'".
+func orList(s []string) string {
+	q := make([]string, len(s))
+	for i := range s {
+		q[i] = fmt.Sprintf("'%s'", s[i])
+	}
+	if len(q) == 1 {
+		return q[0]
+	}
+	return strings.Join(q[:len(q)-1], ", ") + " or " + q[len(q)-1]
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestNear(t *testing.T) {
+	cs := newCommandSet()
+	for _, c := range []*command{
+		{prefix: "remind"},
+		{prefix: "remind list"},
+		{prefix: "remind del"},
+		{prefix: "say", role: Admin},
+		{prefix: "driver disable", role: Admin},
+		{prefix: "karma"},
+		{prefix: "Fact Search"},
+	} {
+		cs.Add(c, c.prefix)
+	}
+	as := &aliasSet{ns: conf.InMem(aliasesNs)}
+	as.Set(newAlias("quote random", "quote"))
+	cs.setAliases(as)
+
+	tests := []struct {
+		txt  string
+		role Role
+		want []string
+	}{
+		{"remnid list", User, []string{"remind list"}},
+		{"remnid me to eat", User, []string{"remind"}},
+		{"REMNID", User, []string{"remind"}},
+		{"remind lsit", User, []string{"remind list"}},
+		{"remind dle 1", User, []string{"remind del"}},
+		{"kamra", User, []string{"karma"}},
+		{"fatc search foo", User, []string{"fact search"}},
+		{"qoute random", User, []string{"quote random"}},
+		{"help", User, []string{}},
+		{"hello there", User, []string{}},
+		// Only commands the caller can run are suggested.
+		{"drvier disable fact", User, []string{}},
+		{"drvier disable fact", Admin, []string{"driver disable"}},
+		// Short commands need to be spelled right.
+		{"sya hi", Admin, []string{}},
+		{"", User, []string{}},
+	}
+	for _, test := range tests {
+		if got := cs.near(test.txt, test.role, "#chan"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("near(%q, %s) = %q, want %q", test.txt, test.role, got, test.want)
+		}
+	}
+}
+
+func TestOrList(t *testing.T) {
+	tests := []struct {
+		s    []string
+		want string
+	}{
+		{[]string{"a"}, "'a'"},
+		{[]string{"a", "b"}, "'a' or 'b'"},
+		{[]string{"a", "b", "c"}, "'a', 'b' or 'c'"},
+	}
+	for _, test := range tests {
+		if got := orList(test.s); got != test.want {
+			t.Errorf("orList(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/fluffle/goirc/client"
+)
+
+// A Transport connects the bot to a chat network. IRC servers are the
+// usual transport, and chat.go has another. Lines from every transport
+// look like IRC lines, so handlers don't need to care where they're from.
+type Transport interface {
+	// Network returns the name of the network, see Network.Name.
+	Network() string
+	// Me returns the bot's nick on the network.
+	Me() string
+	// Send sends text to a channel or nick as a PRIVMSG, NOTICE or ACTION.
+	Send(cmd, target, text string)
+	Topic(ch string, topic ...string)
+	Join(ch string)
+	Part(ch string, msg ...string)
+	Nick(nick string)
+	// Identity resolves the sender of a line to what we know about them.
+	Identity(line *client.Line) *Identity
+	// Account returns the account nick is logged in to, or "" if they
+	// aren't, and false if we don't know yet.
+	Account(nick string) (string, bool)
+	// Whois asks the network which account nick is logged in to, if it
+	// can. The answer arrives asynchronously.
+	Whois(nick string)
+}
+
+// ircTransport is the Transport for an IRC server.
+type ircTransport struct {
+	*server
+}
+
+func (t ircTransport) Network() string {
+	return t.server.Network().Name
+}
+
+func (t ircTransport) Me() string {
+	return t.server.Me()
+}
+
+// Send rate limits text with the server's outQueue, and splits it into as
+// many lines as necessary.
+func (t ircTransport) Send(cmd, target, text string) {
+	t.out.queue(target, formatLines(
+		cmd, target, text, t.Me(), t.server.Network().Ident)...)
+}
+
+func (t ircTransport) Join(ch string) {
+	t.Conn.Join(ch)
+}
+
+func (t ircTransport) Identity(line *client.Line) *Identity {
+	id := lineIdentity(line)
+	if !id.Known && t.HasCapability("account-tag") {
+		// With account-tag, a missing tag means they're not logged in.
+		id.Known = true
+	}
+	if !id.Known && line.Nick != "" {
+		id.Account, id.Known = t.Account(line.Nick)
+	}
+	return id
+}
+
+func (t ircTransport) Account(nick string) (string, bool) {
+	return bot.accounts.Lookup(t.Conn, nick)
+}
+
+func (t ircTransport) Whois(nick string) {
+	bot.accounts.Whois(t.Conn, nick)
+}
+
+// A lineHandler handles lines from transports other than IRC, for which
+// goirc dispatches lines to client.Handlers instead.
+type lineHandler interface {
+	handleLine(t Transport, line *client.Line)
+}
+
+// handlerSet dispatches lines from non-IRC transports to the handlers
+// registered for their Cmd, the same way goirc does.
+type handlerSet struct {
+	sync.RWMutex
+	fg, bg map[string][]lineHandler
+}
+
+func newHandlerSet() *handlerSet {
+	return &handlerSet{
+		fg: make(map[string][]lineHandler),
+		bg: make(map[string][]lineHandler),
+	}
+}
+
+func (hs *handlerSet) add(ev string, h lineHandler, bg bool) {
+	hs.Lock()
+	defer hs.Unlock()
+	ev = strings.ToLower(ev)
+	if bg {
+		hs.bg[ev] = append(hs.bg[ev], h)
+	} else {
+		hs.fg[ev] = append(hs.fg[ev], h)
+	}
+}
+
+// dispatch runs the handlers for a line concurrently, waiting for the
+// foreground ones to finish.
+func (hs *handlerSet) dispatch(t Transport, line *client.Line) {
+	hs.RLock()
+	ev := strings.ToLower(line.Cmd)
+	fg, bg := hs.fg[ev], hs.bg[ev]
+	hs.RUnlock()
+	for _, h := range bg {
+		go handleLine(h, t, line.Copy())
+	}
+	wg := &sync.WaitGroup{}
+	for _, h := range fg {
+		wg.Add(1)
+		go func(h lineHandler) {
+			defer wg.Done()
+			handleLine(h, t, line.Copy())
+		}(h)
+	}
+	wg.Wait()
+}
+
+// handleLine catches, logs and apologises for panics, like unfail.
+func handleLine(h lineHandler, t Transport, line *client.Line) {
+	defer func() {
+		if err := recover(); err != nil {
+			reportError(t, line, panicError(err))
+		}
+	}()
+	h.handleLine(t, line)
+}
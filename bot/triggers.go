@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+const (
+	triggersNs = "triggers"
+	// Stored in triggersNs to turn off triggers set by the config file.
+	noTriggers = "none"
+)
+
+// triggerSet persists the characters that address the bot when they start
+// a line in a channel, keyed on lowercased channel. These override the
+// config file's triggers.
+type triggerSet struct {
+	ns conf.Namespace
+}
+
+// For returns the trigger characters for ch. There are no triggers in
+// private messages, which are always addressed to the bot.
+func (ts *triggerSet) For(ch string) string {
+	if ts == nil || !isChannel(ch) {
+		return ""
+	}
+	ch = strings.ToLower(ch)
+	switch t := ts.ns.String(ch); t {
+	case noTriggers:
+		return ""
+	case "":
+		return currentConfig().Triggers[ch]
+	default:
+		return t
+	}
+}
+
+// Set changes the triggers for ch. If t is empty, ch has no triggers.
+func (ts *triggerSet) Set(ch, t string) {
+	if t == "" {
+		t = noTriggers
+	}
+	ts.ns.String(strings.ToLower(ch), t)
+}
+
+// Reset forgets any triggers set for ch, leaving those from the config.
+func (ts *triggerSet) Reset(ch string) {
+	ts.ns.Delete(strings.ToLower(ch))
+}
+
+// validTriggers returns true if t is only punctuation and symbols, so
+// that triggers can't be confused with words.
+func validTriggers(t string) bool {
+	for _, r := range t {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return false
+		}
+	}
+	return t != ""
+}
+
+// removeTrigger returns text without a leading trigger character, and
+// true if it had one. Triggers must be followed immediately by a letter
+// or digit, so that lines like "..." or "!!!" don't address the bot.
+func removeTrigger(text, triggers string) (string, bool) {
+	r, n := utf8.DecodeRuneInString(text)
+	if n == 0 || !strings.ContainsRune(triggers, r) {
+		return text, false
+	}
+	if next, _ := utf8.DecodeRuneInString(text[n:]); !unicode.IsLetter(next) &&
+		!unicode.IsDigit(next) {
+		return text, false
+	}
+	return text[n:], true
+}
+
+func setTriggers(ctx *Context) {
+	t, ch := ctx.Arg("chars"), ctx.Arg("#chan")
+	if strings.ToLower(t) == noTriggers {
+		bot.triggers.Set(ch, "")
+		ctx.ReplyN("Only lines starting with my nick are for me in %s.", ch)
+		return
+	}
+	if !validTriggers(t) {
+		ctx.ReplyN("Triggers must be punctuation, like '!' or '.'.")
+		return
+	}
+	bot.triggers.Set(ch, t)
+	ctx.ReplyN("Lines starting with one of '%s' are now for me in %s.", t, ch)
+}
+
+func resetTriggers(ctx *Context) {
+	ch := ctx.Arg("#chan")
+	bot.triggers.Reset(ch)
+	listTriggers(ctx)
+}
+
+func listTriggers(ctx *Context) {
+	ch := ctx.Arg("#chan")
+	if t := bot.triggers.For(ch); t != "" {
+		ctx.ReplyN("Lines starting with one of '%s' are for me in %s.", t, ch)
+		return
+	}
+	ctx.ReplyN("Only lines starting with my nick are for me in %s.", ch)
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/fluffle/sp0rkle/collections/conf"
+)
+
+func TestTriggerSet(t *testing.T) {
+	ts := &triggerSet{ns: conf.InMem(triggersNs)}
+	ts.Set("#Bots", "!.")
+	ts.Set("#quiet", "")
+
+	tests := []struct {
+		ch, want string
+	}{
+		{"#bots", "!."},
+		{"#BOTS", "!."},
+		{"#quiet", ""},
+		{"#other", ""},
+		// There are no triggers in private messages.
+		{"somenick", ""},
+	}
+	for _, test := range tests {
+		if got := ts.For(test.ch); got != test.want {
+			t.Errorf("For(%q) = %q, want %q", test.ch, got, test.want)
+		}
+	}
+	ts.Reset("#bots")
+	if got := ts.For("#bots"); got != "" {
+		t.Errorf("For(\"#bots\") = %q after Reset", got)
+	}
+	var nilTriggers *triggerSet
+	if got := nilTriggers.For("#bots"); got != "" {
+		t.Errorf("nil triggerSet has triggers %q", got)
+	}
+}
+
+func TestRemoveTrigger(t *testing.T) {
+	tests := []struct {
+		text, triggers, want string
+		ok                   bool
+	}{
+		{"!remind me", "!.", "remind me", true},
+		{".8ball hi", "!.", "8ball hi", true},
+		{"¡hola", "¡", "hola", true},
+		{"!remind me", "", "!remind me", false},
+		{"@remind me", "!.", "@remind me", false},
+		// Triggers must be followed by a word.
+		{"...", ".", "...", false},
+		{"!!!", "!", "!!!", false},
+		{"! remind", "!", "! remind", false},
+		{"!", "!", "!", false},
+		{"", "!", "", false},
+	}
+	for _, test := range tests {
+		got, ok := removeTrigger(test.text, test.triggers)
+		if got != test.want || ok != test.ok {
+			t.Errorf("removeTrigger(%q, %q) = %q, %t, want %q, %t",
+				test.text, test.triggers, got, ok, test.want, test.ok)
+		}
+	}
+	for _, s := range []string{"!", "!.@~", "¡"} {
+		if !validTriggers(s) {
+			t.Errorf("validTriggers(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"", "a", "! ", "!1"} {
+		if validTriggers(s) {
+			t.Errorf("validTriggers(%q) = true", s)
+		}
+	}
+}
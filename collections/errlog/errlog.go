@@ -0,0 +1,121 @@
+package errlog
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/db"
+)
+
+const COLLECTION = "errors"
+
+// An Error is a panic in, or an error reported by, one of the bot's
+// handlers. Errors that happen in the same place are only stored once,
+// with a count of how many times they've happened.
+type Error struct {
+	Id      string
+	Message string
+	// Stack frames, innermost first, as "function at file:line".
+	Stack     []string
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Where the most recent occurrence came from, and the line that
+	// caused it, if any.
+	Network, Target, Nick, Text string
+}
+
+// New returns an Error for message with the given stack. Its Id is
+// derived from the stack and the kind of error, so that repeats of the
+// same error have the same Id even if their messages differ slightly.
+func New(kind, message string, stack []string) *Error {
+	h := sha1.New()
+	fmt.Fprintln(h, kind)
+	for _, frame := range stack {
+		fmt.Fprintln(h, frame)
+	}
+	return &Error{
+		Id:      fmt.Sprintf("%x", h.Sum(nil))[:8],
+		Message: message,
+		Stack:   stack,
+	}
+}
+
+func (e *Error) K() db.Key {
+	return db.K{db.S{"id", e.Id}}
+}
+
+var _ db.Keyer = (*Error)(nil)
+
+// Where returns the innermost stack frame, or "" if there isn't one.
+func (e *Error) Where() string {
+	if len(e.Stack) == 0 {
+		return ""
+	}
+	return e.Stack[0]
+}
+
+type Collection struct {
+	db.C
+	// Serialises Record's read-modify-write.
+	mu sync.Mutex
+}
+
+func Init() *Collection {
+	ec := &Collection{}
+	ec.Init(db.Bolt.Keyed(), COLLECTION, nil)
+	return ec
+}
+
+// Record stores e at time now. If an error with the same Id has been
+// recorded before, that is updated instead and returned.
+func (ec *Collection) Record(e *Error, now time.Time) *Error {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if old := ec.ById(e.Id); old != nil {
+		e.Count, e.FirstSeen = old.Count, old.FirstSeen
+	} else {
+		e.Count, e.FirstSeen = 0, now
+	}
+	e.Count++
+	e.LastSeen = now
+	if err := ec.Put(e); err != nil {
+		logging.Error("Couldn't record error %s: %v", e.Id, err)
+	}
+	return e
+}
+
+// ById returns the error with the given Id, or nil. Ids are matched
+// case-insensitively.
+func (ec *Collection) ById(id string) *Error {
+	e := &Error{Id: strings.ToLower(id)}
+	if err := ec.Get(e.K(), e); err != nil || e.Count == 0 {
+		return nil
+	}
+	return e
+}
+
+// Recent returns all the errors, most recently seen first.
+func (ec *Collection) Recent() []*Error {
+	var errs []*Error
+	if err := ec.All(db.K{}, &errs); err != nil {
+		logging.Error("Couldn't list errors: %v", err)
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].LastSeen.After(errs[j].LastSeen)
+	})
+	return errs
+}
+
+// Forget deletes the error with the given Id.
+func (ec *Collection) Forget(id string) {
+	if err := ec.Del(&Error{Id: strings.ToLower(id)}); err != nil {
+		logging.Error("Couldn't forget error %s: %v", id, err)
+	}
+}
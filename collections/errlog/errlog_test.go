@@ -0,0 +1,60 @@
+package errlog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/db/dbtest"
+)
+
+func TestNew(t *testing.T) {
+	stack := []string{"bot.foo at bot/foo.go:12", "bot.bar at bot/bar.go:34"}
+	a := New("runtime.boundsError", "panic: index out of range [5]", stack)
+	b := New("runtime.boundsError", "panic: index out of range [7]", stack)
+	if a.Id != b.Id || len(a.Id) != 8 {
+		t.Errorf("Ids %q and %q differ for the same error.", a.Id, b.Id)
+	}
+	if c := New("*errors.errorString", "panic: oops", stack); c.Id == a.Id {
+		t.Errorf("Different kinds of error have the same Id %q.", c.Id)
+	}
+	if c := New("runtime.boundsError", "", stack[1:]); c.Id == a.Id {
+		t.Errorf("Different stacks have the same Id %q.", c.Id)
+	}
+	if a.Where() != stack[0] || (&Error{}).Where() != "" {
+		t.Errorf("Where() = %q, want %q", a.Where(), stack[0])
+	}
+}
+
+func TestRecord(t *testing.T) {
+	dbtest.Init(t)
+	ec := Init()
+
+	then := time.Date(2024, 7, 15, 12, 30, 0, 0, time.UTC)
+	stack := []string{"bot.foo at bot/foo.go:12"}
+	e := ec.Record(New("string", "panic: one", stack), then)
+	if e.Count != 1 || !e.FirstSeen.Equal(then) || !e.LastSeen.Equal(then) {
+		t.Errorf("First Record() = %+v", e)
+	}
+	e = ec.Record(New("string", "panic: two", stack), then.Add(time.Hour))
+	if e.Count != 2 || !e.FirstSeen.Equal(then) || !e.LastSeen.Equal(then.Add(time.Hour)) {
+		t.Errorf("Second Record() = %+v", e)
+	}
+	other := ec.Record(New("string", "panic: other", nil), then.Add(time.Minute))
+
+	got := ec.ById(e.Id)
+	if got == nil || got.Message != "panic: two" || got.Count != 2 ||
+		!reflect.DeepEqual(got.Stack, stack) {
+		t.Errorf("ById(%q) = %+v", e.Id, got)
+	}
+	if recent := ec.Recent(); len(recent) != 2 || recent[0].Id != e.Id || recent[1].Id != other.Id {
+		t.Errorf("Recent() = %+v, want %s then %s", recent, e.Id, other.Id)
+	}
+	ec.Forget(e.Id)
+	if got := ec.ById(e.Id); got != nil {
+		t.Errorf("ById(%q) = %+v after Forget", e.Id, got)
+	}
+	if ec.ById("nothing") != nil {
+		t.Errorf("ById(\"nothing\") isn't nil.")
+	}
+}
@@ -3,8 +3,11 @@ package factoids
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
@@ -144,6 +147,12 @@ type Collection struct {
 
 	// cache of objectIds for PseudoRand
 	seen map[string]map[bson.ObjectId]bool
+
+	// Keys by their length in runes, for GetKeysNear. Read from the db
+	// when first needed, then added to by Put. Deleted keys are only
+	// noticed when GetKeysNear would return them.
+	mu   sync.Mutex
+	keys map[int]map[string]bool
 }
 
 // Wrapper to get hold of a factoid collection handle
@@ -158,6 +167,63 @@ func Init() *Collection {
 	return fc
 }
 
+// Put stores a factoid, adding its key to the keys GetKeysNear knows.
+func (fc *Collection) Put(value any) error {
+	if err := fc.C.Put(value); err != nil {
+		return err
+	}
+	if f, ok := value.(*Factoid); ok {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		fc.addKey(f.Key)
+	}
+	return nil
+}
+
+// addKey adds key to the keys GetKeysNear knows, if it has read them
+// yet. fc.mu must be held.
+func (fc *Collection) addKey(key string) {
+	if fc.keys == nil {
+		return
+	}
+	n := utf8.RuneCountInString(key)
+	if fc.keys[n] == nil {
+		fc.keys[n] = map[string]bool{}
+	}
+	fc.keys[n][key] = true
+}
+
+// forgetKey removes a deleted key from the keys GetKeysNear knows.
+func (fc *Collection) forgetKey(key string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	delete(fc.keys[utf8.RuneCountInString(key)], key)
+}
+
+// keysOfLength returns the keys that are from lo to hi runes long.
+func (fc *Collection) keysOfLength(lo, hi int) []string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.keys == nil {
+		// Reading every key is slow, so it's only done once.
+		keys := fc.GetKeysMatching(".")
+		if keys == nil {
+			return nil
+		}
+		fc.keys = map[int]map[string]bool{}
+		for _, k := range keys {
+			fc.addKey(k)
+		}
+	}
+	res := []string{}
+	for n := lo; n <= hi; n++ {
+		for k := range fc.keys[n] {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
 // Can't call this Count because that'd override mgo.Collection.Count()
 func (fc *Collection) GetCount(key string) int {
 	// TODO(fluffle): less-wasteful GetCount()
@@ -245,6 +311,34 @@ func (fc *Collection) GetKeysMatching(regex string) []string {
 	return res
 }
 
+// GetKeysNear returns the keys that are at most dist edits away from
+// key, but not key itself, closest first.
+func (fc *Collection) GetKeysNear(key string, dist int) []string {
+	if dist <= 0 {
+		return nil
+	}
+	n := utf8.RuneCountInString(key)
+	// Only keys of a similar length can be close enough.
+	res, dists := []string{}, map[string]int{}
+	for _, k := range fc.keysOfLength(n-dist, n+dist) {
+		if d := util.EditDistance(key, k); d > 0 && d <= dist {
+			if fc.GetCount(k) == 0 {
+				fc.forgetKey(k)
+				continue
+			}
+			res = append(res, k)
+			dists[k] = d
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if dists[res[i]] != dists[res[j]] {
+			return dists[res[i]] < dists[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	return res
+}
+
 func (fc *Collection) GetLast(key string) (c *Factoid, m *Factoid, a *Factoid) {
 	facts := fc.GetAll(key)
 	for _, fact := range facts {
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/db"
+)
+
+const (
+	COLLECTION = "jobs"
+	// Values for Job.State.
+	Enabled  = "enabled"
+	Disabled = "disabled"
+)
+
+// A Job is what is stored about one of the bot's scheduled jobs: how
+// admins have changed it from its defaults, and how it has been running.
+// Jobs that admins define with "jobs add" are stored here entirely, and
+// scheduled again from here when the bot starts.
+type Job struct {
+	Name string
+	// The definition of a job added by admins, which says Text in Target
+	// on the Spec schedule. Jobs that drivers schedule leave these empty.
+	Spec, Target, Text string
+	// If set, the schedule admins have given the job instead of its own.
+	Schedule string
+	// If set to Enabled or Disabled, overrides the job's default.
+	State string
+
+	Runs, Failures int
+	LastRun        time.Time
+	LastDuration   time.Duration
+	LastError      string
+}
+
+func (j *Job) K() db.Key {
+	return db.K{db.S{"name", j.Name}}
+}
+
+var _ db.Keyer = (*Job)(nil)
+
+type Collection struct {
+	db.C
+	// Serialises Update's read-modify-write.
+	mu sync.Mutex
+}
+
+func Init() *Collection {
+	jc := &Collection{}
+	jc.Init(db.Bolt.Keyed(), COLLECTION, nil)
+	return jc
+}
+
+// Get returns what is stored about the named job. Jobs that haven't been
+// stored yet are returned with no changes and no runs.
+func (jc *Collection) Get(name string) *Job {
+	j := &Job{Name: strings.ToLower(name)}
+	if err := jc.C.Get(j.K(), j); err != nil {
+		logging.Error("Couldn't get job %s: %v", name, err)
+	}
+	return j
+}
+
+// Defined returns the jobs that admins have defined.
+func (jc *Collection) Defined() []*Job {
+	var all []*Job
+	if err := jc.All(db.K{}, &all); err != nil {
+		logging.Error("Couldn't list jobs: %v", err)
+		return nil
+	}
+	defined := []*Job{}
+	for _, j := range all {
+		if j.Spec != "" {
+			defined = append(defined, j)
+		}
+	}
+	return defined
+}
+
+// Delete forgets everything stored about the named job.
+func (jc *Collection) Delete(name string) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	if err := jc.Del(&Job{Name: strings.ToLower(name)}); err != nil {
+		logging.Error("Couldn't delete job %s: %v", name, err)
+	}
+}
+
+// Update changes what is stored about the named job with fn.
+func (jc *Collection) Update(name string, fn func(*Job)) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	j := jc.Get(name)
+	fn(j)
+	if err := jc.Put(j); err != nil {
+		logging.Error("Couldn't update job %s: %v", name, err)
+	}
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/db/dbtest"
+)
+
+func TestUpdate(t *testing.T) {
+	dbtest.Init(t)
+	jc := Init()
+
+	if j := jc.Get("Poll"); !reflect.DeepEqual(j, &Job{Name: "poll"}) {
+		t.Errorf("Unstored job = %#v", j)
+	}
+	now := time.Date(2024, 2, 28, 9, 30, 0, 0, time.UTC)
+	jc.Update("Poll", func(j *Job) {
+		j.Schedule, j.State = "every 5m", Disabled
+	})
+	jc.Update("poll", func(j *Job) {
+		j.Runs, j.Failures = 2, 1
+		j.LastRun, j.LastDuration, j.LastError = now, 1500*time.Millisecond, "oops"
+	})
+	want := &Job{Name: "poll", Schedule: "every 5m", State: Disabled,
+		Runs: 2, Failures: 1, LastRun: now, LastDuration: 1500 * time.Millisecond,
+		LastError: "oops"}
+	j := jc.Get("POLL")
+	if !j.LastRun.Equal(now) {
+		t.Errorf("LastRun = %s, want %s", j.LastRun, now)
+	}
+	j.LastRun = now
+	if !reflect.DeepEqual(j, want) {
+		t.Errorf("Stored job = %#v\nwant %#v", j, want)
+	}
+}
+
+func TestDefined(t *testing.T) {
+	dbtest.Init(t)
+	jc := Init()
+
+	jc.Update("poll", func(j *Job) { j.State = Disabled })
+	jc.Update("Standup", func(j *Job) {
+		j.Spec, j.Target, j.Text = "0 9 * * mon-fri", "#dev", "Standup time!"
+	})
+	want := []*Job{{Name: "standup", Spec: "0 9 * * mon-fri",
+		Target: "#dev", Text: "Standup time!"}}
+	if got := jc.Defined(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Defined() = %#v\nwant %#v", got, want)
+	}
+	jc.Delete("STANDUP")
+	if got := jc.Defined(); len(got) != 0 {
+		t.Errorf("Defined() after Delete = %#v", got)
+	}
+	if j := jc.Get("poll"); j.State != Disabled {
+		t.Errorf("Delete deleted too much, poll = %#v", j)
+	}
+}
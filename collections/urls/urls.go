@@ -103,8 +103,9 @@ func Init() *Collection {
 }
 
 func (uc *Collection) GetById(id bson.ObjectId) *Url {
-	res := &Url{Id_: id}
-	if err := uc.Get(res.byId(), res); err == nil && res.Exists() {
+	// Get leaves res alone if there's no such url, so its Id_ must be empty.
+	res := &Url{}
+	if err := uc.Get((&Url{Id_: id}).byId(), res); err == nil && res.Exists() {
 		return res
 	}
 	return nil
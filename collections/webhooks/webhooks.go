@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/db"
+)
+
+const COLLECTION = "webhooks"
+
+// A Webhook is a URL that bot events are POSTed to as JSON.
+type Webhook struct {
+	Id  string
+	Url string
+	// Who registered the webhook, and a mask matching their identity.
+	Nick  bot.Nick
+	Owner string
+	// If set, only events from this channel are delivered.
+	Chan bot.Chan
+	// If set, only these types of event are delivered, e.g. "KarmaChanged".
+	Events []string
+	// Set for webhooks added by admins, which may be sent to loopback and
+	// private addresses. Other webhooks are only sent to public ones.
+	AnyHost bool
+	// Payloads are signed with this, so receivers can check they came
+	// from the bot.
+	Secret  string
+	Created time.Time
+	// How the most recent delivery went.
+	LastDelivery time.Time
+	LastError    string
+}
+
+func New(url string, id *bot.Identity, c bot.Chan, events []string) *Webhook {
+	return &Webhook{
+		Id:      randHex(3),
+		Url:     url,
+		Nick:    bot.Nick(id.Nick),
+		Owner:   id.UniqueMask(),
+		Chan:    c,
+		Events:  events,
+		Secret:  randHex(16),
+		Created: time.Now(),
+	}
+}
+
+func randHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		logging.Fatal("Couldn't read random bytes: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (wh *Webhook) K() db.Key {
+	return db.K{db.S{"id", wh.Id}}
+}
+
+var _ db.Keyer = (*Webhook)(nil)
+
+// Wants returns true if the webhook should be sent events of type event
+// that happened in channel c. Events from private messages never are.
+func (wh *Webhook) Wants(event string, c bot.Chan) bool {
+	if !c.IsChannel() {
+		return false
+	}
+	if wh.Chan != "" && !strings.EqualFold(string(wh.Chan), string(c)) {
+		return false
+	}
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, e := range wh.Events {
+		if strings.EqualFold(e, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the hex HMAC-SHA256 of body, keyed with the webhook's secret.
+func (wh *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(wh.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type Collection struct {
+	db.C
+	// Serialises Delivered's read-modify-write.
+	mu sync.Mutex
+}
+
+func Init() *Collection {
+	wc := &Collection{}
+	wc.Init(db.Bolt.Keyed(), COLLECTION, nil)
+	return wc
+}
+
+// ById returns the webhook with the given Id, or nil.
+func (wc *Collection) ById(id string) *Webhook {
+	wh := &Webhook{Id: strings.ToLower(id)}
+	if err := wc.Get(wh.K(), wh); err != nil || wh.Url == "" {
+		return nil
+	}
+	return wh
+}
+
+// OwnedBy returns true if wh was added by id.
+func (wh *Webhook) OwnedBy(id *bot.Identity) bool {
+	return id.Matches(wh.Owner)
+}
+
+// All returns every webhook, oldest first.
+func (wc *Collection) All() []*Webhook {
+	var whs []*Webhook
+	if err := wc.C.All(db.K{}, &whs); err != nil {
+		logging.Error("Couldn't list webhooks: %v", err)
+		return nil
+	}
+	sort.Slice(whs, func(i, j int) bool {
+		return whs[i].Created.Before(whs[j].Created)
+	})
+	return whs
+}
+
+// Delivered records how a delivery to the webhook with the given Id went.
+func (wc *Collection) Delivered(id string, at time.Time, err error) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wh := wc.ById(id)
+	if wh == nil {
+		// It was deleted while we were delivering to it.
+		return
+	}
+	wh.LastDelivery, wh.LastError = at, ""
+	if err != nil {
+		wh.LastError = err.Error()
+	}
+	if err := wc.Put(wh); err != nil {
+		logging.Error("Couldn't update webhook %s: %v", id, err)
+	}
+}
+
+// Forget deletes the webhook with the given Id.
+func (wc *Collection) Forget(id string) error {
+	return wc.Del(&Webhook{Id: strings.ToLower(id)})
+}
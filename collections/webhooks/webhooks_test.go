@@ -0,0 +1,116 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/db/dbtest"
+)
+
+var alice = &bot.Identity{Nick: "alice", Ident: "alice", Host: "example.com"}
+
+func TestWants(t *testing.T) {
+	all := New("http://example.com/", alice, "", nil)
+	chan1 := New("http://example.com/", alice, "#chan", nil)
+	karma := New("http://example.com/", alice, "#Chan", []string{"KarmaChanged", "QuoteAdded"})
+	tests := []struct {
+		wh    *Webhook
+		event string
+		c     string
+		want  bool
+	}{
+		{all, "FactoidAdded", "#other", true},
+		{all, "ReminderFired", "alice", false},
+		{all, "ReminderFired", "", false},
+		{chan1, "FactoidAdded", "#other", false},
+		{chan1, "FactoidAdded", "#CHAN", true},
+		{karma, "karmachanged", "#chan", true},
+		{karma, "FactoidAdded", "#chan", false},
+		{karma, "QuoteAdded", "#other", false},
+	}
+	for i, test := range tests {
+		if got := test.wh.Wants(test.event, bot.Chan(test.c)); got != test.want {
+			t.Errorf("%d: Wants(%q, %q) = %t, want %t", i, test.event, test.c, got, test.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	wh := &Webhook{Secret: "key"}
+	want := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got := wh.Sign([]byte("The quick brown fox jumps over the lazy dog")); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	a, b := New("u", alice, "", nil), New("u", alice, "", nil)
+	if a.Id == b.Id || a.Secret == b.Secret || len(a.Id) != 6 || len(a.Secret) != 32 {
+		t.Errorf("New() made %+v and %+v", a, b)
+	}
+}
+
+func TestOwnedBy(t *testing.T) {
+	bob := &bot.Identity{Nick: "bob", Ident: "bob", Host: "example.org", Account: "Bob", Known: true}
+	byAlice, byBob := New("u", alice, "#chan", nil), New("u", bob, "#chan", nil)
+	tests := []struct {
+		wh   *Webhook
+		id   *bot.Identity
+		want bool
+	}{
+		{byAlice, alice, true},
+		// Someone else using alice's nick doesn't own alice's webhooks.
+		{byAlice, &bot.Identity{Nick: "alice", Ident: "mallory", Host: "example.net"}, false},
+		{byBob, bob, true},
+		// Logging in to bob's account does, whatever the nick.
+		{byBob, &bot.Identity{Nick: "bobby", Ident: "b", Host: "example.net", Account: "bob"}, true},
+		{byBob, &bot.Identity{Nick: "bob", Ident: "bob", Host: "example.org"}, false},
+	}
+	for i, test := range tests {
+		if got := test.wh.OwnedBy(test.id); got != test.want {
+			t.Errorf("%d: %s.OwnedBy(%s) = %t, want %t", i, test.wh.Owner, test.id.Mask(), got, test.want)
+		}
+	}
+}
+
+func TestCollection(t *testing.T) {
+	dbtest.Init(t)
+	wc := Init()
+
+	first := New("http://example.com/1", alice, "", nil)
+	second := New("http://example.com/2", &bot.Identity{Nick: "bob", Account: "Bob"},
+		"#chan", []string{"QuoteAdded"})
+	second.Created = first.Created.Add(time.Second)
+	for _, wh := range []*Webhook{second, first} {
+		if err := wc.Put(wh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if all := wc.All(); len(all) != 2 || all[0].Id != first.Id || all[1].Id != second.Id {
+		t.Errorf("All() = %+v, want %s then %s", all, first.Id, second.Id)
+	}
+
+	at := time.Date(2024, 7, 15, 12, 30, 0, 0, time.UTC)
+	wc.Delivered(second.Id, at, errors.New("got 500 Internal Server Error"))
+	got := wc.ById(second.Id)
+	if got == nil || !got.LastDelivery.Equal(at) || got.LastError != "got 500 Internal Server Error" ||
+		got.Chan != "#chan" || len(got.Events) != 1 || got.Secret != second.Secret ||
+		got.Nick != "bob" || got.Owner != "$a:Bob" {
+		t.Errorf("ById(%q) = %+v", second.Id, got)
+	}
+	wc.Delivered(second.Id, at, nil)
+	if got := wc.ById(second.Id); got == nil || got.LastError != "" {
+		t.Errorf("LastError not cleared by a successful delivery: %+v", got)
+	}
+
+	if err := wc.Forget(first.Id); err != nil {
+		t.Errorf("Forget(%q) = %v", first.Id, err)
+	}
+	if wc.ById(first.Id) != nil {
+		t.Errorf("ById(%q) isn't nil after Forget.", first.Id)
+	}
+	// Deliveries to forgotten webhooks are ignored.
+	wc.Delivered(first.Id, at, nil)
+	if wc.ById(first.Id) != nil {
+		t.Errorf("Delivered() resurrected %q.", first.Id)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"os"
 	"path"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/util/bson"
+	"github.com/fluffle/sp0rkle/util/metrics"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -59,10 +61,52 @@ type boltDatabase struct {
 	dir   string
 	every time.Duration
 	quit  chan struct{}
+	// Closed by backupLoop when it returns.
+	looped chan struct{}
+	// When the last successful backup was written, in unix seconds.
+	backedUp atomic.Int64
 }
 
 var Bolt = &boltDatabase{}
 
+var backups = metrics.NewCounter("sp0rkle_db_backups_total",
+	"BoltDB backups written, by result.", "result")
+
+func init() {
+	metrics.NewGaugeFunc("sp0rkle_db_size_bytes",
+		"Size of the BoltDB file.", Bolt.size)
+	metrics.NewGaugeFunc("sp0rkle_db_last_backup_timestamp_seconds",
+		"When the last successful BoltDB backup was written.",
+		func() float64 { return float64(Bolt.backedUp.Load()) })
+	metrics.NewGaugeFunc("sp0rkle_db_last_backup_age_seconds",
+		"How long ago the last successful BoltDB backup was written.",
+		func() float64 {
+			if t := Bolt.backedUp.Load(); t > 0 {
+				return time.Since(time.Unix(t, 0)).Seconds()
+			}
+			return 0
+		})
+	// BoltDB keeps its own transaction stats.
+	stat := func(fn func(bolt.Stats) float64) func() float64 {
+		return func() float64 { return fn(Bolt.stats()) }
+	}
+	metrics.NewCounterFunc("sp0rkle_db_tx_total",
+		"BoltDB read transactions started.",
+		stat(func(s bolt.Stats) float64 { return float64(s.TxN) }))
+	metrics.NewGaugeFunc("sp0rkle_db_open_tx",
+		"BoltDB read transactions currently open.",
+		stat(func(s bolt.Stats) float64 { return float64(s.OpenTxN) }))
+	metrics.NewCounterFunc("sp0rkle_db_write_seconds_total",
+		"Time BoltDB write transactions spent writing to disk.",
+		stat(func(s bolt.Stats) float64 { return s.TxStats.GetWriteTime().Seconds() }))
+	metrics.NewCounterFunc("sp0rkle_db_spill_seconds_total",
+		"Time BoltDB write transactions spent spilling pages.",
+		stat(func(s bolt.Stats) float64 { return s.TxStats.GetSpillTime().Seconds() }))
+	metrics.NewCounterFunc("sp0rkle_db_rebalance_seconds_total",
+		"Time BoltDB write transactions spent rebalancing.",
+		stat(func(s bolt.Stats) float64 { return s.TxStats.GetRebalanceTime().Seconds() }))
+}
+
 func (b *boltDatabase) Init(path, backupDir string, backupEvery time.Duration) error {
 	b.Lock()
 	defer b.Unlock()
@@ -73,7 +117,8 @@ func (b *boltDatabase) Init(path, backupDir string, backupEvery time.Duration) e
 	if err != nil {
 		return err
 	}
-	b.db, b.dir, b.every, b.quit = db, backupDir, backupEvery, make(chan struct{})
+	b.db, b.dir, b.every = db, backupDir, backupEvery
+	b.quit, b.looped = make(chan struct{}), make(chan struct{})
 	// Do a backup on startup and error if it is not successful.
 	if err := os.MkdirAll(b.dir, 0700); err != nil {
 		return fmt.Errorf("could not create backup dir %q: %v", b.dir, err)
@@ -92,19 +137,63 @@ func (b *boltDatabase) Close() {
 	if b.db == nil {
 		return
 	}
+	// Wait for any backup in progress, so it isn't writing the same
+	// file as the final one.
+	close(b.quit)
+	<-b.looped
+	if err := b.doBackup(); err != nil {
+		logging.Error("Final backup error: %v", err)
+	}
 	if err := b.db.Close(); err != nil {
 		logging.Error("Unable to close BoltDB: %v", err)
 	}
 	b.db = nil
-	close(b.quit)
+}
+
+// boltOf returns the BoltDB that a Database from Keyed or Indexed uses.
+func boltOf(db Database) *bolt.DB {
+	switch d := db.(type) {
+	case *keyedDatabase:
+		return d.db
+	case *indexedDatabase:
+		return d.db
+	}
+	return nil
 }
 
 func (b *boltDatabase) DB() *bolt.DB {
 	return b.db
 }
 
+// stats returns BoltDB's stats, which are zero if the database isn't open.
+func (b *boltDatabase) stats() bolt.Stats {
+	b.Lock()
+	defer b.Unlock()
+	if b.db == nil {
+		return bolt.Stats{}
+	}
+	return b.db.Stats()
+}
+
+// size returns the size of the database in bytes, or 0 if it isn't open.
+func (b *boltDatabase) size() float64 {
+	b.Lock()
+	defer b.Unlock()
+	if b.db == nil {
+		return 0
+	}
+	var n int64
+	b.db.View(func(tx *bolt.Tx) error {
+		n = tx.Size()
+		return nil
+	})
+	return float64(n)
+}
+
 func (b *boltDatabase) backupLoop() {
+	defer close(b.looped)
 	tick := time.NewTicker(b.every)
+	defer tick.Stop()
 	for {
 		select {
 		case <-tick.C:
@@ -112,13 +201,22 @@ func (b *boltDatabase) backupLoop() {
 				logging.Error("Backup error: %v", err)
 			}
 		case <-b.quit:
-			tick.Stop()
 			return
 		}
 	}
 }
 
 func (b *boltDatabase) doBackup() error {
+	if err := b.backup(); err != nil {
+		backups.Inc("failed")
+		return err
+	}
+	backups.Inc("ok")
+	b.backedUp.Store(time.Now().Unix())
+	return nil
+}
+
+func (b *boltDatabase) backup() error {
 	fn := path.Join(b.dir, fmt.Sprintf("sp0rkle.boltdb.%s.gz",
 		time.Now().Format("2006-01-02.15:04")))
 	fh, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/fluffle/sp0rkle/util/datetime"
 	"github.com/fluffle/sp0rkle/util/bson"
+	bolt "go.etcd.io/bbolt"
 )
 
 const (
@@ -79,9 +80,14 @@ func (unimplementedCollection) Debug(bool) {}
 
 func (unimplementedCollection) Fsck(any) error { return nil }
 
+// C is a Collection that can be initialised more than once, e.g. each time
+// a package-level one is used. It is only opened again if the database has
+// been closed and opened again since, as it is between tests.
 type C struct {
 	Collection
-	sync.Once
+	mu sync.Mutex
+	// The BoltDB that Collection is in.
+	in *bolt.DB
 }
 
 func (c *C) Init(db Database, name string, f func(Collection)) {
@@ -89,12 +95,14 @@ func (c *C) Init(db Database, name string, f func(Collection)) {
 		c.Collection = unimplementedCollection{}
 		return
 	}
-	c.Do(func() {
-		c.Collection = db.C(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if in := boltOf(db); c.Collection == nil || c.in != in {
+		c.Collection, c.in = db.C(name), in
 		if f != nil {
 			f(c)
 		}
-	})
+	}
 }
 
 type Elem interface {
@@ -3,10 +3,12 @@ package db
 import (
 	"bytes"
 	"encoding/binary"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/util/datetime"
 )
 
@@ -186,3 +188,45 @@ func TestK_Valid(t *testing.T) {
 		})
 	}
 }
+
+func TestCloseWaitsForBackups(t *testing.T) {
+	// Backups are logged. dbtest can't be used here as it imports db.
+	logging.InitFromFlags()
+	dir := t.TempDir()
+	if err := Bolt.Init(filepath.Join(dir, "test.db"), dir, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// Let backupLoop get going.
+	time.Sleep(10 * time.Millisecond)
+	Bolt.Close()
+	select {
+	case <-Bolt.looped:
+	default:
+		t.Errorf("Close returned while backupLoop was still running.")
+	}
+}
+
+func TestCReopened(t *testing.T) {
+	logging.InitFromFlags()
+	dir := t.TempDir()
+	open := func(name string) {
+		if err := Bolt.Init(filepath.Join(dir, name), dir, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open("first.db")
+	var c C
+	c.Init(Bolt.Keyed(), "test", nil)
+	first := c.Collection
+	c.Init(Bolt.Keyed(), "test", nil)
+	if c.Collection != first {
+		t.Errorf("Collection was opened again in the same database.")
+	}
+	Bolt.Close()
+	open("second.db")
+	defer Bolt.Close()
+	c.Init(Bolt.Keyed(), "test", nil)
+	if c.in != Bolt.DB() {
+		t.Errorf("Collection wasn't opened again in the new database.")
+	}
+}
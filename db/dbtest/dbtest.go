@@ -0,0 +1,57 @@
+// Package dbtest sets up what tests of packages that store things need:
+// logging, and a database that is thrown away afterwards.
+//
+// It doesn't import bot, so collections that bot imports can use it too.
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/db"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Open sets up logging from the flags, as main() does, and opens a new
+// database in dir.
+func Open(dir string) error {
+	logging.InitFromFlags()
+	return db.Bolt.Init(filepath.Join(dir, "sp0rkle.boltdb"),
+		filepath.Join(dir, "backup"), 24*time.Hour)
+}
+
+// Init opens a new database in a temporary directory, closing it and
+// removing the directory when the test finishes.
+func Init(t testing.TB) {
+	t.Helper()
+	if err := Open(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Bolt.Close)
+}
+
+// Wipe empties the database opened by Open. Its buckets are recreated
+// empty, so collections that were using them still work.
+func Wipe() error {
+	return db.Bolt.DB().Update(func(tx *bolt.Tx) error {
+		var names [][]byte
+		err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, append([]byte(nil), name...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -5,17 +5,20 @@ import (
 )
 
 func Init() {
-	bot.Command(calculate, "calc", "calc <expr>  -- does maths for you")
-	bot.Command(date, "date", "date <time/date> [in <zone>] -- "+
-		"works out the absolute time for <time/date> [in <zone>]")
-	bot.Command(netmask, "netmask", "netmask <ip/cidr>|<ip> <mask>"+
+	d := bot.NewDriver("calc")
+	d.Command(calculate, "calc", "calc <expr>  -- does maths for you",
+		bot.Examples("calc 2**16", "calc sqrt(2)*pi"))
+	d.Command(date, "date", "date <time/date> [in <zone>] -- "+
+		"works out the absolute time for <time/date> [in <zone>]",
+		bot.Examples("date next tuesday 3pm", "date now in Asia/Tokyo"))
+	d.Command(netmask, "netmask", "netmask <ip/cidr>|<ip> <mask>"+
 		"  -- calculate IPv4 / IPv6 netmasks")
-	bot.Command(chr, "chr", "chr <int>  -- "+
+	d.Command(chr, "chr", "chr <int>  -- "+
 		"prints the character represented by <int> in various formats")
-	bot.Command(ord, "ord", "ord <char>  -- "+
+	d.Command(ord, "ord", "ord <char>  -- "+
 		"prints the numeric and UTF-8 representations of <char>")
-	bot.Command(convertBase, "base", "base <from>to<to> <num>  -- "+
+	d.Command(convertBase, "base", "base <from>to<to> <num>  -- "+
 		"converts <num> from base <from> to base <to>")
-	bot.Command(length, "length", "length <string>  -- "+
+	d.Command(length, "length", "length <string>  -- "+
 		"prints the length of <string>")
 }
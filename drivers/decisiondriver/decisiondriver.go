@@ -18,14 +18,15 @@ import (
 var ErrUnbalanced = errors.New("unbalanced quotes")
 
 func Init() {
-	bot.Rewrite(randPlugin)
-	bot.Rewrite(decidePlugin)
+	d := bot.NewDriver("decision")
+	d.Rewrite(randPlugin)
+	d.Rewrite(decidePlugin)
 
-	bot.Command(randCmd, "rand", "rand <range>  -- "+
+	d.Command(randCmd, "rand", "rand <range>  -- "+
 		"choose a random number in range [lo-]hi")
-	bot.Command(decideCmd, "decide", "decide <options>  -- "+
+	d.Command(decideCmd, "decide", "decide <options>  -- "+
 		"choose one of the (space, pipe, quote) delimited options at random")
-	bot.Command(decideCmd, "choose", "choose <options>  -- "+
+	d.Command(decideCmd, "choose", "choose <options>  -- "+
 		"choose one of the (space, pipe, quote) delimited options at random")
 }
 
@@ -115,6 +115,7 @@ func edit(ctx *bot.Context) {
 		ctx.ReplyN("I failed to replace '%s': %s", fact.Key, err)
 		return
 	}
+	publishEdit(ctx, fact.Key, old, fact.Value)
 	ctx.ReplyN("'%s' was '%s', is now '%s'.",
 		fact.Key, old, fact.Value)
 }
@@ -132,6 +133,8 @@ func forget(ctx *bot.Context) {
 		ctx.ReplyN("I failed to forget '%s': %s", fact.Key, err)
 		return
 	}
+	n, c := ctx.Storable()
+	bot.Publish(bot.FactoidDeleted{Key: fact.Key, Value: fact.Value, Nick: n, Chan: c})
 	ctx.ReplyN("I forgot that '%s' was '%s'.",
 		fact.Key, fact.Value)
 }
@@ -188,18 +191,16 @@ func literal(ctx *bot.Context) {
 	if count := fc.GetCount(key); count == 0 {
 		ctx.ReplyN("I don't know anything about '%s'.", key)
 		return
-	} else if count > 10 && ctx.Public() {
-		ctx.ReplyN("I know too much about '%s', ask me privately.", key)
-		return
 	}
 
 	if facts := fc.GetAll(key); facts != nil {
-		for _, fact := range facts {
-			// Use Privmsg directly here so that the results aren't output
-			// via the plugin system and contain the literal data.
-			ctx.Privmsg(ctx.Target(), fmt.Sprintf(
-				"[%3.0f%%] %s", fact.Chance*100, fact.Value))
+		lines := make([]string, len(facts))
+		for i, fact := range facts {
+			lines[i] = fmt.Sprintf("[%3.0f%%] %s", fact.Chance*100, fact.Value)
 		}
+		// Page doesn't output the results via the plugin system,
+		// so they contain the literal data.
+		ctx.Page(lines...)
 	} else {
 		ctx.ReplyN("Something literally went wrong :-(")
 	}
@@ -224,10 +225,16 @@ func replace(ctx *bot.Context) {
 		ctx.ReplyN("I failed to replace '%s': %s", fact.Key, err)
 		return
 	}
+	publishEdit(ctx, fact.Key, old, fact.Value)
 	ctx.ReplyN("'%s' was '%s', now is '%s'.",
 		fact.Key, old, fact.Value)
 }
 
+func publishEdit(ctx *bot.Context, key, old, val string) {
+	n, c := ctx.Storable()
+	bot.Publish(bot.FactoidEdited{Key: key, Old: old, Value: val, Nick: n, Chan: c})
+}
+
 // Factoid search: 'fact search regexp' => list of possible key matches
 func search(ctx *bot.Context) {
 	keys := fc.GetKeysMatching(ctx.Text())
@@ -238,18 +245,6 @@ func search(ctx *bot.Context) {
 		return
 	}
 	// RESULTS.
-	count := len(keys)
-	if count > 10 {
-		res := strings.Join(keys[:10], "', '")
-		ctx.ReplyN(
-			"I found %d keys matching '%s', here's the first 10: '%s'.",
-			count, ctx.Text(), res)
-
-	} else {
-		res := strings.Join(keys, "', '")
-		ctx.ReplyN(
-			"I found %d keys matching '%s', here they are: '%s'.",
-			count, ctx.Text(), res)
-
-	}
+	ctx.PageN(fmt.Sprintf("I found %d keys matching '%s', here they are: '%s'.",
+		len(keys), ctx.Text(), strings.Join(keys, "', '")))
 }
@@ -19,28 +19,32 @@ var fc *factoids.Collection
 var lastSeen = map[string]bson.ObjectId{}
 
 func Init() {
+	d := bot.NewDriver("fact")
 	fc = factoids.Init()
 
-	bot.Handle(insert, client.PRIVMSG)
-	bot.Handle(lookup, client.PRIVMSG, client.ACTION)
+	d.Handle(insert, client.PRIVMSG)
+	d.Handle(lookup, client.PRIVMSG, client.ACTION)
+	d.Suggest(suggest)
 
-	bot.Rewrite(replaceIdentifiers)
+	d.Rewrite(replaceIdentifiers)
 
-	bot.Command(chance, "chance of that is",
+	d.Command(chance, "chance of that is",
 		"chance  -- Sets trigger chance of the last displayed factoid value.")
-	bot.Command(edit, "that =~",
+	d.Command(edit, "that =~",
 		"=~ s/regex/replacement/ -- Edits the last factoid value using regex.")
-	bot.Command(forget, "delete that",
-		"delete  -- Forgets the last displayed factoid value.")
-	bot.Command(forget, "forget that",
-		"forget  -- Forgets the last displayed factoid value.")
-	bot.Command(info, "fact info",
+	d.Command(forget, "delete that",
+		"delete  -- Forgets the last displayed factoid value.",
+		bot.Requires(bot.Trusted))
+	d.Command(forget, "forget that",
+		"forget  -- Forgets the last displayed factoid value.",
+		bot.Requires(bot.Trusted))
+	d.Command(info, "fact info",
 		"fact info <key>  -- Displays some stats about factoid <key>.")
-	bot.Command(literal, "literal",
+	d.Command(literal, "literal",
 		"literal <key>  -- Displays the factoid values stored for <key>.")
-	bot.Command(replace, "replace that with",
+	d.Command(replace, "replace that with",
 		"replace  -- Replaces the last displayed factoid value.")
-	bot.Command(search, "fact search",
+	d.Command(search, "fact search",
 		"fact search <regexp>  -- Searches for factoids matching <regexp>.")
 }
 
@@ -0,0 +1,72 @@
+package factdriver
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/fluffle/goirc/client"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	// root is the owner.
+	flag.Set("rebuilder", "root!*@*")
+	os.Exit(bottest.Run(m, Init))
+}
+
+func TestFactoids(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	b.Privmsg("alice", ch, "sp0rkle: cheese := tasty")
+	b.Expect(t, ch, "alice: Woo, I now know 1 things about 'cheese'.")
+	b.Privmsg("alice", ch, "sp0rkle: cheese :is smelly")
+	b.Expect(t, ch, "alice: Woo, I now know 2 things about 'cheese'.")
+
+	// Factoids aren't added unless the bot is addressed.
+	b.Privmsg("alice", ch, "crackers := dry")
+	b.ExpectNothing(t)
+	b.Privmsg("alice", ch, "sp0rkle: literal crackers")
+	b.Expect(t, ch, "alice: I don't know anything about 'crackers'.")
+	// Triggers address the bot too.
+	b.Privmsg("alice", ch, bottest.Trigger+"crackers := dry")
+	b.Expect(t, ch, "alice: Woo, I now know 1 things about 'crackers'.")
+	b.Privmsg("bob", ch, bottest.Trigger+"crackers")
+	b.Expect(t, ch, "dry")
+
+	b.Privmsg("bob", ch, "sp0rkle: literal cheese")
+	b.Expect(t, ch, "[100%] tasty")
+	b.Expect(t, ch, "[100%] cheese is smelly")
+
+	// Typos in lookups and commands get suggestions.
+	b.Privmsg("bob", ch, "sp0rkle: chese?")
+	b.Expect(t, ch, "bob: Did you mean 'cheese'?")
+	b.Privmsg("bob", ch, "sp0rkle: litreal cheese")
+	b.Expect(t, ch, "bob: Did you mean 'literal'?")
+	b.Privmsg("bob", ch, "sp0rkle: cheese")
+	b.ExpectMatch(t, ch, "^(tasty|cheese is smelly)$")
+	// Forgotten keys aren't suggested.
+	b.Privmsg("alice", ch, "sp0rkle: crackers")
+	b.Expect(t, ch, "dry")
+	b.Privmsg("root", ch, "sp0rkle: forget that")
+	b.Expect(t, ch, "root: I forgot that 'crackers' was 'dry'.")
+	b.Privmsg("bob", ch, "sp0rkle: crakers?")
+	b.ExpectNothing(t)
+
+	// Lookups are rewritten, and remembered for "that" commands.
+	b.Privmsg("alice", ch, "sp0rkle: greeting := <me>waves at $nick")
+	b.Expect(t, ch, "alice: Woo, I now know 1 things about 'greeting'.")
+	b.Privmsg("bob", ch, "greeting?")
+	if line := b.Next(); line == nil || line.Cmd != client.ACTION ||
+		line.Text() != "waves at bob" {
+		t.Errorf("Got %v, want the bot to wave at bob.", line)
+	}
+	b.Privmsg("bob", ch, "sp0rkle: that =~ s/waves/nods/")
+	b.Expect(t, ch, "bob: 'greeting' was 'waves at bob', is now 'nods at bob'.")
+	b.Privmsg("carol", ch, "greeting")
+	b.Expect(t, ch, "nods at carol")
+
+	// "that" is per channel.
+	b.Privmsg("carol", "#other", "sp0rkle: that =~ s/nods/bows/")
+	b.Expect(t, "#other", "carol: I've forgotten what we were talking about, sorry!")
+}
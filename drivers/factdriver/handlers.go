@@ -38,9 +38,10 @@ func insert(ctx *bot.Context) {
 	}
 
 	if err := fc.Put(fact); err != nil {
-		ctx.ReplyN("Error storing factoid: %s.", err)
+		ctx.ReportError(err)
 		return
 	}
+	bot.Publish(bot.FactoidAdded{Key: key, Value: val, Nick: n, Chan: c})
 	count := fc.GetCount(key)
 	LastSeen(ctx.Target(), fact.Id())
 	ctx.ReplyN("%s, I now know %d things about '%s'.", joy, count, key)
@@ -93,6 +94,16 @@ func lookup(ctx *bot.Context) {
 	}
 }
 
+// Offers keys close to an addressed line that isn't a key, so typos
+// in lookups can be corrected.
+func suggest(ctx *bot.Context) (bool, []string) {
+	key := ToKey(ctx.Text(), false)
+	if key == "" || fc.GetCount(key) > 0 {
+		return true, nil
+	}
+	return false, fc.GetKeysNear(key, util.MaxTypos(key))
+}
+
 // Recursively resolve pointers to other factoids
 func recurse(fact *factoids.Factoid, keys map[string]bool) {
 	val := fact.Value
@@ -8,7 +8,7 @@ import (
 func recordKarma(ctx *bot.Context) {
 	// Karma can look like some.text.string++ or (text with spaces)--
 	// and there could be multiple occurrences of it in a string.
-	nick, _ := ctx.Storable()
+	nick, c := ctx.Storable()
 	for _, kt := range karmaThings(ctx.Text()) {
 		k := kc.KarmaFor(kt.thing)
 		if k == nil {
@@ -21,6 +21,9 @@ func recordKarma(ctx *bot.Context) {
 		}
 		if err := kc.Put(k); err != nil {
 			ctx.Reply("Failed to insert Karma: %s", err)
+			continue
 		}
+		bot.Publish(bot.KarmaChanged{
+			Subject: kt.thing, Score: k.Score, Nick: nick, Chan: c})
 	}
 }
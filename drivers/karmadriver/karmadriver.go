@@ -13,11 +13,12 @@ import (
 var kc *karma.Collection
 
 func Init() {
+	d := bot.NewDriver("karma")
 	kc = karma.Init()
 
-	bot.Handle(recordKarma, client.PRIVMSG, client.ACTION)
+	d.Handle(recordKarma, client.PRIVMSG, client.ACTION)
 
-	bot.Command(karmaCmd, "karma", "karma <thing>  -- "+
+	d.Command(karmaCmd, "karma", "karma <thing>  -- "+
 		"Retrieve the karma score of <thing>.")
 }
 
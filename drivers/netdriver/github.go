@@ -7,7 +7,6 @@ import (
 
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
-	"github.com/fluffle/sp0rkle/collections/reminders"
 	"github.com/fluffle/sp0rkle/util"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
@@ -111,10 +110,8 @@ func githubWatcher(ctx *bot.Context) {
 		kv := strings.Split(*l.Name, ":")
 		if len(kv) == 2 && kv[0] == "nick" {
 			logging.Debug("Recording tell for %s about issue %d.", kv[1], issue)
-			r := reminders.NewTell("that "+text, bot.Nick(kv[1]), "github", "")
-			if err := rc.Put(r); err != nil {
-				logging.Error("Error inserting github tell: %v", err)
-			}
+			bot.Publish(bot.TellRequested{
+				Source: "github", Target: bot.Nick(kv[1]), Text: "that " + text})
 		}
 	}
 }
@@ -15,6 +15,8 @@ import (
 )
 
 type mcStatus struct {
+	// False until the server has been polled successfully.
+	polled     bool
 	motd       string
 	nump, maxp string
 	players    []string
@@ -26,9 +28,13 @@ const (
 	mcServer   = "server"
 	mcFreq     = "freq"
 	mcChan     = "chan"
+	// The name of the polling job, and how often it runs by default.
+	mcJob     = "mc"
+	mcDefFreq = 5
 )
 
 var (
+	mc          = &mcStatus{}
 	mcConf      conf.Namespace
 	mcHandshake = []byte("\xfe\xfd\x09\x00\x00\x00\x00")
 	mcGetStatus = []byte("\xfe\xfd\x00\x00\x00\x00\x00")
@@ -43,6 +49,7 @@ func mcSet(ctx *bot.Context) {
 	switch kv[0] {
 	case mcServer:
 		mcConf.String(mcServer, kv[1])
+		scheduleMC()
 	case mcChan:
 		if !strings.HasPrefix(kv[1], "#") {
 			ctx.ReplyN("Channel '%s' doesn't start with #.", kv[1])
@@ -56,6 +63,7 @@ func mcSet(ctx *bot.Context) {
 			return
 		}
 		mcConf.Int(mcFreq, freq)
+		scheduleMC()
 	default:
 		ctx.ReplyN("Valid keys are: %s, %s, %s", mcServer, mcFreq, mcChan)
 		return
@@ -63,29 +71,41 @@ func mcSet(ctx *bot.Context) {
 	ctx.ReplyN("Set %s to '%s'", kv[0], kv[1])
 }
 
-func (mcs *mcStatus) Poll(ctxs []*bot.Context) {
+// scheduleMC (re)schedules polling the minecraft server every freq
+// minutes, or stops it if there's no server to poll.
+func scheduleMC() {
+	srv := mcConf.String(mcServer)
+	if srv == "" {
+		bot.Unschedule(mcJob)
+		return
+	}
+	freq := mcConf.Int(mcFreq)
+	if freq <= 0 {
+		freq = mcDefFreq
+	}
+	logging.Info("Polling MC server '%s' every %dm.", srv, freq)
+	if err := bot.Schedule(mcJob, fmt.Sprintf("every %dm", freq), mc.Poll); err != nil {
+		logging.Error("Not polling MC server: %v", err)
+	}
+}
+
+func (mcs *mcStatus) Poll(ctxs []*bot.Context) error {
 	srv := mcConf.String(mcServer)
 	logging.Debug("polling minecraft server at %s", srv)
 	st, err := pollServer(srv)
 	if err != nil {
-		logging.Error("minecraft poll failed: %v", err)
-		return
+		return fmt.Errorf("minecraft poll failed: %v", err)
 	}
 	*mcs = *st
 	for _, ctx := range ctxs {
 		ctx.Topic(mcConf.String(mcChan))
 	}
-}
-
-func (mcs *mcStatus) Start() { /* empty */ }
-func (mcs *mcStatus) Stop()  { /* empty */ }
-func (mcs *mcStatus) Tick() time.Duration {
-	return time.Duration(mcConf.Int(mcFreq)) * time.Minute
+	return nil
 }
 
 func (mcs *mcStatus) Topic(ctx *bot.Context) {
 	ch := mcConf.String(mcChan)
-	if ctx.Args[1] != ch {
+	if !mcs.polled || ctx.Args[1] != ch {
 		return
 	}
 	topic := ctx.Text()
@@ -168,6 +188,7 @@ func pollServer(server string) (*mcStatus, error) {
 		items[kvs[i]] = kvs[i+1]
 	}
 	st := &mcStatus{
+		polled:  true,
 		motd:    items["hostname"], // meh.
 		nump:    items["numplayers"],
 		maxp:    items["maxplayers"],
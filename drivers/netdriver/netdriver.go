@@ -9,17 +9,20 @@ import (
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/conf"
 	"github.com/fluffle/sp0rkle/collections/pushes"
-	"github.com/fluffle/sp0rkle/collections/reminders"
 	"github.com/fluffle/sp0rkle/util/push"
 	"github.com/google/go-github/github"
 )
 
 var pc *pushes.Collection
-var rc *reminders.Collection
 var gh *github.Client
 
-func get(req string) ([]byte, error) {
-	res, err := http.Get(req)
+func get(url string) ([]byte, error) {
+	// Abort the request if the bot is shutting down.
+	req, err := http.NewRequestWithContext(bot.Ctx(), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -28,60 +31,60 @@ func get(req string) ([]byte, error) {
 }
 
 func Init() {
-	bot.Command(urbanDictionary, "ud", "ud <term>  -- "+
+	d := bot.NewDriver("net")
+	d.Command(urbanDictionary, "ud", "ud <term>  -- "+
 		"Look up <term> on UrbanDictionary.")
 
 	mcConf = conf.Ns("mc")
-	srv := mcConf.String(mcServer)
-	if srv != "" {
+	if srv := mcConf.String(mcServer); srv != "" {
 		if st, err := pollServer(srv); err == nil {
-			logging.Info("Starting MC poller for '%s'", srv)
-			bot.Poll(st)
-			bot.Handle(func(ctx *bot.Context) {
-				st.Topic(ctx)
-			}, "332")
+			mc = st
 		} else {
-			logging.Error("Not starting MC poller: %v", err)
+			logging.Error("Initial MC poll failed: %v", err)
 		}
 	}
-	bot.Command(mcSet, "mc set", "mc set <key> <value>  -- "+
-		"Set minecraft server polling config vars.")
-	// TODO(fluffle): Polling can only be en/disabled at reconnect.
-	//	bot.Command(mcPoll, "mc poll", "mc poll start|stop  -- "+
-	//		"Enable or disable minecraft server polling.")
+	scheduleMC()
+	d.Handle(func(ctx *bot.Context) {
+		mc.Topic(ctx)
+	}, "332")
+	// Polling can be stopped and started with "jobs disable/enable mc".
+	d.Command(mcSet, "mc set", "mc set <key> <value>  -- "+
+		"Set minecraft server polling config vars.", bot.Requires(bot.Admin))
 
 	if *githubToken != "" {
-		rc = reminders.Init()
 		gh = githubClient()
 
-		bot.Handle(githubWatcher, client.PRIVMSG)
+		d.Handle(githubWatcher, client.PRIVMSG)
 
-		bot.Command(githubCreateIssue, "file bug:", "file bug: <title>. "+
+		d.Command(githubCreateIssue, "file bug:", "file bug: <title>. "+
 			"<descriptive body>  -- Files a bug on GitHub. Abusers will be hurt.")
-		bot.Command(githubCreateIssue, "file bug", "file bug <title>. "+
+		d.Command(githubCreateIssue, "file bug", "file bug <title>. "+
 			"<descriptive body>  -- Files a bug on GitHub. Abusers will be hurt.")
-		bot.Command(githubCreateIssue, "report bug", "report bug <title>. "+
+		d.Command(githubCreateIssue, "report bug", "report bug <title>. "+
 			"<descriptive body>  -- Files a bug on GitHub. Abusers will be hurt.")
-		bot.Command(githubUpdateIssue, "update bug #", "update bug #<number> "+
+		d.Command(githubUpdateIssue, "update bug #", "update bug #<number> "+
 			"<comment>  -- Adds a comment to bug <number>. Abusers will be hurt.")
 	}
 
 	if push.Enabled() {
 		pc = pushes.Init()
-		bot.Command(pushEnable, "push enable", "push enable  -- "+
+		d.Command(pushEnable, "push enable", "push enable  -- "+
 			"Start the OAuth flow to enable pushbullet notifications.")
-		bot.Command(pushDisable, "push disable", "push disable  -- "+
+		d.Command(pushDisable, "push disable", "push disable  -- "+
 			"Disable pushbullet notifications and delete tokens.")
-		bot.Command(pushConfirm, "push auth", "push auth <pin>  -- "+
+		d.Command(pushConfirm, "push auth", "push auth <pin>  -- "+
 			"Confirm pushed PIN to finish pushbullet auth dance.")
-		bot.Command(pushAddAlias, "push add alias", "push add alias  -- "+
+		d.Command(pushAddAlias, "push add alias", "push add alias  -- "+
 			"Add a push alias for your nick.")
-		bot.Command(pushDelAlias, "push del alias", "push del alias  -- "+
+		d.Command(pushDelAlias, "push del alias", "push del alias  -- "+
 			"Delete a push alias for your nick.")
 
 		http.HandleFunc("/oauth/auth", pushAuthHTTP)
 		http.HandleFunc("/oauth/device", pushDeviceHTTP)
 		http.HandleFunc("/oauth/success", pushSuccessHTTP)
 		http.HandleFunc("/oauth/failure", pushFailureHTTP)
+
+		bot.Subscribe(pushReminder)
+		bot.Subscribe(pushTell)
 	}
 }
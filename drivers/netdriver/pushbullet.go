@@ -234,3 +234,18 @@ func pushFailureHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 	pushFailureTmpl.Execute(rw, pushFailure{pushFailures[f]})
 }
+
+// pushReminder pushes reminders to their target, if they've enabled pushes.
+func pushReminder(ev bot.ReminderFired) {
+	if s := pc.GetByNick(string(ev.Target), true); s.CanPush() {
+		push.Push(s, "Reminder from sp0rkle!", ev.Reply)
+	}
+}
+
+// pushTell lets people who've enabled pushes know someone left them a message.
+func pushTell(ev bot.TellStored) {
+	if s := pc.GetByNick(string(ev.Target), true); s.CanPush() {
+		push.Push(s, fmt.Sprintf("%s in %s asked me to tell you:",
+			ev.Source, ev.Chan), ev.Text)
+	}
+}
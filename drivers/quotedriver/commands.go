@@ -2,6 +2,7 @@ package quotedriver
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/fluffle/sp0rkle/bot"
 	"github.com/fluffle/sp0rkle/collections/quotes"
@@ -16,6 +17,8 @@ func add(ctx *bot.Context) {
 		return
 	}
 	if err = qc.Put(quote); err == nil {
+		bot.Publish(bot.QuoteAdded{
+			QID: quote.QID, Quote: quote.Quote, Nick: n, Chan: c})
 		ctx.ReplyN("Quote added succesfully, id #%d.", quote.QID)
 	} else {
 		ctx.ReplyN("Error adding quote: %s.", err)
@@ -23,14 +26,11 @@ func add(ctx *bot.Context) {
 }
 
 func del(ctx *bot.Context) {
-	txt := ctx.Text()
 	// Strip optional # before qid
-	if txt[0] == '#' {
-		txt = txt[1:]
-	}
+	txt := strings.TrimPrefix(ctx.Arg("#qID"), "#")
 	qid, err := strconv.Atoi(txt)
 	if err != nil {
-		ctx.ReplyN("'%s' doesn't look like a quote id.", ctx.Text())
+		ctx.ReplyN("'%s' doesn't look like a quote id.", ctx.Arg("#qID"))
 		return
 	}
 	if quote := qc.GetByQID(qid); quote != nil {
@@ -45,14 +45,7 @@ func del(ctx *bot.Context) {
 }
 
 func fetch(ctx *bot.Context) {
-	if RateLimit(ctx.Nick) {
-		return
-	}
-	qid, err := strconv.Atoi(ctx.Text())
-	if err != nil {
-		ctx.ReplyN("'%s' doesn't look like a quote id.", ctx.Text())
-		return
-	}
+	qid := ctx.ArgInt("qID")
 	quote := qc.GetByQID(qid)
 	if quote != nil {
 		ctx.Reply("#%d: %s", quote.QID, quote.Quote)
@@ -62,9 +55,6 @@ func fetch(ctx *bot.Context) {
 }
 
 func lookup(ctx *bot.Context) {
-	if RateLimit(ctx.Nick) {
-		return
-	}
 	quote := qc.GetPseudoRand(ctx.Text())
 	if quote == nil {
 		ctx.ReplyN("No quotes matching '%s' found.", ctx.Text())
@@ -10,7 +10,6 @@ import (
 	"github.com/fluffle/sp0rkle/collections/conf"
 	"github.com/fluffle/sp0rkle/collections/reminders"
 	"github.com/fluffle/sp0rkle/util/datetime"
-	"github.com/fluffle/sp0rkle/util/push"
 	"github.com/fluffle/sp0rkle/util/bson"
 )
 
@@ -41,18 +40,16 @@ func list(ctx *bot.Context) {
 		ctx.ReplyN("You have no reminders set.")
 		return
 	}
-	if c > 5 && ctx.Public() {
-		ctx.ReplyN("You've got lots of reminders, ask me privately.")
-		return
-	}
 	// Save an ordered list of ObjectIds for easy reminder deletion
 	ctx.ReplyN("You have %d reminders set:", c)
 	list := make([]bson.ObjectId, c)
+	lines := make([]string, c)
 	for i := range r {
-		ctx.Reply("%d: %s", i+1, r[i].List(ctx.Nick))
+		lines[i] = fmt.Sprintf("%d: %s", i+1, r[i].List(ctx.Nick))
 		list[i] = r[i].Id()
 	}
 	listed[ctx.Nick] = list
+	ctx.Page(lines...)
 }
 
 // remind
@@ -155,32 +152,25 @@ func snooze(ctx *bot.Context) {
 
 // tell
 func tell(ctx *bot.Context) {
-	// s == <target> <stuff>
-	txt := ctx.Text()
-	idx := strings.Index(txt, " ")
-	if idx == -1 {
-		ctx.ReplyN("Tell who what?")
-		return
-	}
-	tell := txt[idx+1:]
+	tell := ctx.Arg("msg")
 	n, c := ctx.Storable()
-	t := bot.Nick(txt[:idx])
+	t := bot.Nick(ctx.Arg("nick"))
 	if t.Lower() == strings.ToLower(ctx.Nick) ||
 		t.Lower() == "me" {
 		ctx.ReplyN("You're a dick. Oh, wait, that wasn't *quite* it...")
 		return
 	}
+	if ctx.Public() && ctx.IsOn(ctx.Target(), string(t)) {
+		ctx.ReplyN("%s is right here, tell them yourself!", t)
+		return
+	}
 	r := reminders.NewTell(tell, t, n, c)
 	if err := rc.Put(r); err != nil {
 		ctx.ReplyN("Error saving tell: %v", err)
 		return
 	}
-	if pc != nil {
-		if s := pc.GetByNick(txt[:idx], true); s.CanPush() {
-			push.Push(s, fmt.Sprintf("%s in %s asked me to tell you:",
-				ctx.Nick, ctx.Target()), tell)
-		}
-	}
+	bot.Publish(bot.TellStored{
+		Source: n, Target: t, Chan: bot.Chan(ctx.Target()), Text: tell})
 	// Any previously-generated list of reminders is now obsolete.
 	delete(listed, ctx.Nick)
 	ctx.ReplyN("%s", r.Acknowledge())
@@ -188,16 +178,12 @@ func tell(ctx *bot.Context) {
 
 // zone
 func zone(ctx *bot.Context) {
-	fields := strings.Fields(ctx.Text())
-	if len(fields) == 0 {
-		ctx.ReplyN("Your timezone is ... fat? Like your mum?")
-		return
-	}
-	if z := datetime.Zone(fields[0]); z != nil {
-		conf.Zone(ctx.Nick, fields[0])
+	zs := ctx.Arg("zone")
+	if z := datetime.Zone(zs); z != nil {
+		conf.Zone(ctx.Nick, zs)
 		ctx.ReplyN("Reminders will now be in %q.", z)
 	} else {
-		ctx.ReplyN("Don't recognise %q as a valid timezone, sorry.", fields[0])
+		ctx.ReplyN("Don't recognise %q as a valid timezone, sorry.", zs)
 	}
 }
 
@@ -1,6 +1,8 @@
 package reminddriver
 
 import (
+	"strings"
+
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
@@ -52,3 +54,20 @@ func tellCheck(ctx *bot.Context) {
 		delete(listed, ctx.Nick)
 	}
 }
+
+// noSuchNick keeps reminders that couldn't be sent privately because
+// their target isn't online.
+func noSuchNick(ctx *bot.Context) {
+	if len(ctx.Args) < 2 {
+		return
+	}
+	nick := strings.ToLower(ctx.Args[1])
+	unsent.Lock()
+	rs := unsent.m[nick]
+	unsent.Unlock()
+	for _, r := range rs {
+		if takeUnsent(nick, r) {
+			keep(r)
+		}
+	}
+}
@@ -3,21 +3,20 @@ package reminddriver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fluffle/goirc/client"
 	"github.com/fluffle/golog/logging"
 	"github.com/fluffle/sp0rkle/bot"
-	"github.com/fluffle/sp0rkle/collections/pushes"
 	"github.com/fluffle/sp0rkle/collections/reminders"
-	"github.com/fluffle/sp0rkle/util/push"
 	"github.com/fluffle/sp0rkle/util/bson"
 )
 
 // We use the reminders collection
 var rc *reminders.Collection
-var pc *pushes.Collection
 
 // We need to be able to kill reminder goroutines
 var running = map[bson.ObjectId]context.CancelFunc{}
@@ -28,33 +27,45 @@ var finished = map[string]*reminders.Reminder{}
 // And it's useful to index them for deletion per-person
 var listed = map[string][]bson.ObjectId{}
 
+// Reminders sent privately to nicks that aren't in our channels, in case
+// the server says there's no such nick, keyed on lowercased nick.
+var unsent = struct {
+	sync.Mutex
+	m map[string][]*reminders.Reminder
+}{m: map[string][]*reminders.Reminder{}}
+
+// How long to wait for the server to say a nick doesn't exist.
+const unsentTimeout = time.Minute
+
 func Init() {
+	d := bot.NewDriver("remind")
 	rc = reminders.Init()
-	if push.Enabled() {
-		pc = pushes.Init()
-	}
+	// Other drivers leave tells by publishing a TellRequested.
+	bot.Subscribe(storeTell)
 
 	// Set up the handlers and commands.
 	bot.Handle(load, client.CONNECTED)
 	bot.Handle(unload, client.DISCONNECTED)
-	bot.Handle(tellCheck,
+	d.Handle(tellCheck,
 		client.PRIVMSG, client.ACTION, client.JOIN, client.NICK)
+	bot.Handle(noSuchNick, "401")
 
-	bot.Command(tell, "tell", "tell <nick> <msg>  -- "+
-		"Stores a message for the (absent) nick.")
-	bot.Command(tell, "ask", "ask <nick> <msg>  -- "+
-		"Stores a message for the (absent) nick.")
-	bot.Command(list, "remind list",
+	d.Command(tell, "tell", "Stores a message for the (absent) nick.",
+		bot.Args(bot.NickArg("nick"), bot.RestArg("msg")))
+	d.Command(tell, "ask", "Stores a message for the (absent) nick.",
+		bot.Args(bot.NickArg("nick"), bot.RestArg("msg")))
+	d.Command(list, "remind list",
 		"remind list  -- Lists reminders set by or for your nick.")
-	bot.Command(del, "remind del",
+	d.Command(del, "remind del",
 		"remind del <N>  -- Deletes (previously listed) reminder N.")
-	bot.Command(set, "remind", "remind <nick> <msg> "+
-		"in|at|on <time>  -- Reminds nick about msg at time.")
-	bot.Command(snooze, "snooze", "snooze [duration]  -- "+
+	d.Command(set, "remind", "remind <nick> <msg> "+
+		"in|at|on <time>  -- Reminds nick about msg at time.",
+		bot.Examples("remind me to stretch in 1h", "remind alice about lunch at 12:30"))
+	d.Command(snooze, "snooze", "snooze [duration]  -- "+
 		"Resets the previously-triggered reminder.")
-	bot.Command(zone, "my timezone is", "my timezone is <zone>  -- "+
-		"Sets a local timezone for your nick.")
-	bot.Command(unzone, "forget my timezone", "forget my timezone  -- "+
+	d.Command(zone, "my timezone is", "Sets a local timezone for your nick.",
+		bot.Args(bot.StringArg("zone")))
+	d.Command(unzone, "forget my timezone", "forget my timezone  -- "+
 		"Unsets a local timezone for your nick.")
 }
 
@@ -65,22 +76,75 @@ func Remind(r *reminders.Reminder, ctx *bot.Context) {
 	}
 	c, cancel := context.WithDeadline(bot.Ctx(), r.RemindAt)
 	running[r.Id()] = cancel
-	go func() {
+	// Reminders are cancelled if the bot shuts down.
+	bot.Go(func() {
 		<-c.Done()
 		if errors.Is(c.Err(), context.DeadlineExceeded) {
-			ctx.Privmsg(string(r.Chan), r.Reply())
-			// TODO(fluffle): Tie this into state tracking properly.
-			ctx.Privmsg(string(r.Target), r.Reply())
+			deliver(r, ctx)
 			// This is used in snooze to reinstate reminders.
 			finished[strings.ToLower(string(r.Target))] = r
-			if pc != nil {
-				if s := pc.GetByNick(string(r.Target), true); s.CanPush() {
-					push.Push(s, "Reminder from sp0rkle!", r.Reply())
-				}
-			}
+			bot.Publish(bot.ReminderFired{
+				Source: r.Source, Target: r.Target, Chan: r.Chan,
+				Text: r.Reminder, Reply: r.Reply(),
+			})
 			Forget(r.Id(), false)
 		}
-	}()
+	})
+}
+
+// deliver sends a reminder to the channel it was set in if the target is
+// there, or privately if not. We can only see nicks in our channels, so
+// if the target isn't in one, noSuchNick keeps the reminder as a tell if
+// the server says they aren't online either.
+func deliver(r *reminders.Reminder, ctx *bot.Context) {
+	target := string(r.Target)
+	if ctx.IsOn(string(r.Chan), target) {
+		ctx.Privmsg(string(r.Chan), r.Reply())
+		return
+	}
+	if !ctx.Online(target) {
+		key := strings.ToLower(target)
+		unsent.Lock()
+		unsent.m[key] = append(unsent.m[key], r)
+		unsent.Unlock()
+		time.AfterFunc(unsentTimeout, func() { takeUnsent(key, r) })
+	}
+	ctx.Privmsg(target, r.Reply())
+}
+
+// takeUnsent stops waiting to hear whether r could be sent to nick. It
+// returns false if we already stopped.
+func takeUnsent(nick string, r *reminders.Reminder) bool {
+	unsent.Lock()
+	defer unsent.Unlock()
+	for i, u := range unsent.m[nick] {
+		if u == r {
+			unsent.m[nick] = append(unsent.m[nick][:i], unsent.m[nick][i+1:]...)
+			if len(unsent.m[nick]) == 0 {
+				delete(unsent.m, nick)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// keep stores a reminder the target missed as a tell for when they next
+// show up.
+func keep(r *reminders.Reminder) {
+	msg := fmt.Sprintf("%s (at %s)", r.Reminder, r.At())
+	t := reminders.NewTell(msg, r.Target, r.Source, r.Chan)
+	if err := rc.Put(t); err != nil {
+		logging.Error("Failed to keep reminder for %s: %v", r.Target, err)
+	}
+}
+
+// storeTell keeps a message for a nick on behalf of another driver.
+func storeTell(ev bot.TellRequested) {
+	t := reminders.NewTell(ev.Text, ev.Target, ev.Source, "")
+	if err := rc.Put(t); err != nil {
+		logging.Error("Failed to store tell for %s: %v", ev.Target, err)
+	}
 }
 
 func Forget(id bson.ObjectId, stop bool) {
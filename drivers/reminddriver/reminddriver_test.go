@@ -0,0 +1,72 @@
+package reminddriver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(bottest.Run(m, Init))
+}
+
+func TestTell(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	b.Privmsg("alice", ch, "sp0rkle: tell dave the cake is a lie")
+	b.Expect(t, ch, "alice: okay, i'll tell dave the cake is a lie when I see them")
+	b.Privmsg("alice", ch, "sp0rkle: tell alice something")
+	b.Expect(t, ch, "alice: You're a dick. Oh, wait, that wasn't *quite* it...")
+
+	// Tells are delivered when dave shows up, privately and in public.
+	b.Join("dave", ch)
+	b.Expect(t, "dave", "alice asked me to tell you the cake is a lie")
+	b.Expect(t, ch, "dave: alice asked me to tell you the cake is a lie")
+	b.Privmsg("dave", ch, "hello!")
+	b.ExpectNothing(t)
+
+	// Now dave is here, alice can tell them in person.
+	b.Privmsg("alice", ch, "sp0rkle: tell DAVE it really is")
+	b.Expect(t, ch, "alice: DAVE is right here, tell them yourself!")
+	b.Part("dave", ch)
+	b.Privmsg("alice", ch, "sp0rkle: tell dave it really is")
+	b.Expect(t, ch, "alice: okay, i'll tell dave it really is when I see them")
+	b.Nick("dave", "dave_")
+	b.ExpectNothing(t)
+	b.Join("dave_", ch)
+	b.ExpectNothing(t)
+	b.Nick("dave_", "dave")
+	b.Expect(t, ch, "dave: alice asked me to tell you it really is")
+	b.Expect(t, "dave", "alice asked me to tell you it really is")
+}
+
+func TestRemind(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	b.Join("erin", ch)
+	b.Privmsg("erin", ch, "sp0rkle: remind me to stretch in 1 second")
+	b.ExpectMatch(t, ch, "^erin: okay, i'll remind you to stretch at ")
+	b.Privmsg("erin", ch, "sp0rkle: remind list")
+	b.Expect(t, ch, "erin: You have 1 reminders set:")
+	b.ExpectMatch(t, ch, "^1: you asked me to remind you to stretch, at ")
+
+	// erin is in the channel, so that's where the reminder goes.
+	b.Expect(t, ch, "erin, you asked me to remind you to stretch")
+
+	// People who aren't in the channel might be elsewhere, so they're
+	// reminded privately.
+	b.Privmsg("erin", ch, "sp0rkle: remind grace to sit down in 1 second")
+	b.ExpectMatch(t, ch, "^erin: okay, i'll remind grace to sit down at ")
+	b.Expect(t, "grace", "grace, erin asked me to remind you to sit down")
+
+	// Unless the server says they aren't online, when it becomes a tell.
+	b.Privmsg("erin", ch, "sp0rkle: remind frank to sit down in 1 second")
+	b.ExpectMatch(t, ch, "^erin: okay, i'll remind frank to sit down at ")
+	b.Expect(t, "frank", "frank, erin asked me to remind you to sit down")
+	b.Raw(":irc.test 401 sp0rkle frank :No such nick/channel")
+	b.ExpectNothing(t)
+	b.Join("frank", ch)
+	b.ExpectMatch(t, "frank", "^erin asked me to tell you to sit down \\(at .*\\)$")
+	b.ExpectMatch(t, ch, "^frank: erin asked me to tell you to sit down \\(at ")
+}
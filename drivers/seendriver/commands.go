@@ -1,6 +1,7 @@
 package seendriver
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/fluffle/golog/logging"
@@ -17,8 +18,17 @@ func seenCmd(ctx *bot.Context) {
 		}
 	}
 	// Not specifically asking for that action, or no matching action.
+	here := ctx.Public() && ctx.IsOn(ctx.Target(), s[0])
 	if n := sc.LastSeen(s[0]); n != nil {
-		ctx.ReplyN("%s", n)
+		if here {
+			ctx.ReplyN("%s They're here right now.", n)
+		} else {
+			ctx.ReplyN("%s", n)
+		}
+		return
+	}
+	if here {
+		ctx.ReplyN("%s is right here.", s[0])
 		return
 	}
 	// No exact matches for nick found, look for possible partial matches.
@@ -27,14 +37,9 @@ func seenCmd(ctx *bot.Context) {
 			if n := sc.LastSeen(m[0]); n != nil {
 				ctx.ReplyN("1 possible match: %s", n)
 			}
-		} else if len(m) > 10 {
-			ctx.ReplyN("%d possible matches, most recent 10 are: %s.",
-				len(m), strings.Join(m[:9], ", "))
-
 		} else {
-			ctx.ReplyN("%d possible matches: %s.",
-				len(m), strings.Join(m, ", "))
-
+			ctx.PageN(fmt.Sprintf("%d possible matches: %s.",
+				len(m), strings.Join(m, ", ")))
 		}
 		return
 	}
@@ -24,7 +24,7 @@ func smoke(ctx *bot.Context) {
 	} else {
 		sn = seen.SawNick(n, c, "SMOKE", "")
 	}
-	if err := sc.Put(sn); err != nil {
+	if err := store(sn); err != nil {
 		ctx.Reply("Failed to store smoke data: %v", err)
 	}
 }
@@ -35,7 +35,7 @@ func recordPrivmsg(ctx *bot.Context) {
 	}
 	sn := seenNickFromLine(ctx)
 	sn.Text = ctx.Text()
-	if err := sc.Put(sn); err != nil {
+	if err := store(sn); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 }
@@ -46,7 +46,7 @@ func recordJoin(ctx *bot.Context) {
 		// If we have a PART message
 		sn.Text = ctx.Text()
 	}
-	if err := sc.Put(sn); err != nil {
+	if err := store(sn); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 }
@@ -55,7 +55,7 @@ func recordNick(ctx *bot.Context) {
 	sn := seenNickFromLine(ctx)
 	sn.Chan = ""
 	sn.Text = ctx.Target()
-	if err := sc.Put(sn); err != nil {
+	if err := store(sn); err != nil {
 		// We don't have anyone to reply to in this case, so log instead.
 		logging.Warn("Failed to store seen data: %v", err)
 	}
@@ -74,7 +74,7 @@ func recordKick(ctx *bot.Context) {
 		kr.Timestamp, kr.Text = time.Now(), ctx.Args[2]
 	}
 	kr.OtherNick = kn
-	if err := sc.Put(kr); err != nil {
+	if err := store(kr); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 	// Now, handle KICKED
@@ -86,7 +86,18 @@ func recordKick(ctx *bot.Context) {
 		ke.Timestamp, ke.Text = time.Now(), ctx.Args[2]
 	}
 	ke.OtherNick = n
-	if err := sc.Put(ke); err != nil {
+	if err := store(ke); err != nil {
 		ctx.Reply("Failed to store seen data: %v", err)
 	}
 }
+
+// store records what a nick was seen doing, and lets anything else that
+// is interested know.
+func store(sn *seen.Nick) error {
+	if err := sc.Put(sn); err != nil {
+		return err
+	}
+	bot.Publish(bot.NickSeen{
+		Nick: sn.Nick, Chan: sn.Chan, Action: sn.Action, Text: sn.Text})
+	return nil
+}
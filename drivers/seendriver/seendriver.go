@@ -41,15 +41,16 @@ func init() {
 var sc *seen.Collection
 
 func Init() {
+	d := bot.NewDriver("seen")
 	sc = seen.Init()
 
-	bot.Handle(smoke, client.PRIVMSG, client.ACTION)
-	bot.Handle(recordPrivmsg, client.PRIVMSG, client.ACTION)
-	bot.Handle(recordJoin, client.JOIN, client.PART)
-	bot.Handle(recordNick, client.NICK, client.QUIT)
-	bot.Handle(recordKick, client.KICK)
+	d.Handle(smoke, client.PRIVMSG, client.ACTION)
+	d.Handle(recordPrivmsg, client.PRIVMSG, client.ACTION)
+	d.Handle(recordJoin, client.JOIN, client.PART)
+	d.Handle(recordNick, client.NICK, client.QUIT)
+	d.Handle(recordKick, client.KICK)
 
-	bot.Command(seenCmd, "seen", "seen <nick> [action]  -- "+
+	d.Command(seenCmd, "seen", "seen <nick> [action]  -- "+
 		"display the last time <nick> was seen on IRC [doing action]")
 }
 
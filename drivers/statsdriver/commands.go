@@ -24,5 +24,5 @@ func topten(ctx *bot.Context) {
 	for i, n := range top {
 		s = append(s, fmt.Sprintf("#%d: %s - %d", i+1, n.Nick, n.Lines))
 	}
-	ctx.Reply("%s", strings.Join(s, ", "))
+	ctx.Page(strings.Join(s, ", "))
 }
@@ -9,16 +9,17 @@ import (
 var sc *stats.Collection
 
 func Init() {
+	d := bot.NewDriver("stats")
 	sc = stats.Init()
 
-	bot.Handle(recordStats, client.PRIVMSG, client.ACTION)
+	d.Handle(recordStats, client.PRIVMSG, client.ACTION)
 
-	bot.Command(statsCmd, "lines", "lines [nick]  -- "+
+	d.Command(statsCmd, "lines", "lines [nick]  -- "+
 		"display how many lines you [or nick] has said in the channel")
-	bot.Command(statsCmd, "stats", "stats [nick]  -- "+
+	d.Command(statsCmd, "stats", "stats [nick]  -- "+
 		"display how many lines you [or nick] has said in the channel")
-	bot.Command(topten, "topten", "topten  -- "+
+	d.Command(topten, "topten", "topten  -- "+
 		"display the nicks who have said the most in the channel")
-	bot.Command(topten, "top10", "top10  -- "+
+	d.Command(topten, "top10", "top10  -- "+
 		"display the nicks who have said the most in the channel")
 }
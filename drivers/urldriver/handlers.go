@@ -30,6 +30,7 @@ func urlScan(ctx *bot.Context) {
 				ctx.ReplyN("Couldn't insert url '%s': %s", w, err)
 				continue
 			}
+			bot.Publish(bot.UrlSeen{Url: w, Nick: n, Chan: c})
 			if u.Shortened != "" {
 				ctx.Reply("%s's URL shortened as %s%s%s",
 					ctx.Nick, bot.HttpHost(), shortenPath, u.Shortened)
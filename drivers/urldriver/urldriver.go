@@ -40,36 +40,37 @@ var uc *urls.Collection
 var lastseen = map[string]bson.ObjectId{}
 
 func Init() {
+	d := bot.NewDriver("url")
 	uc = urls.Init()
 
 	if err := os.MkdirAll(*urlCacheDir, 0700); err != nil {
 		logging.Fatal("Couldn't create URL cache dir: %v", err)
 	}
 
-	bot.Handle(urlScan, client.PRIVMSG)
+	d.Handle(urlScan, client.PRIVMSG)
 
-	bot.Command(find, "urlfind", "urlfind <regex>  -- "+
+	d.Command(find, "urlfind", "urlfind <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
-	bot.Command(find, "url find", "url find <regex>  -- "+
+	d.Command(find, "url find", "url find <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
-	bot.Command(find, "urlsearch", "urlsearch <regex>  -- "+
+	d.Command(find, "urlsearch", "urlsearch <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
-	bot.Command(find, "url search", "url search <regex>  -- "+
+	d.Command(find, "url search", "url search <regex>  -- "+
 		"searches for previously mentioned URLs matching <regex>")
 
-	bot.Command(find, "randurl", "randurl  -- displays a random URL")
-	bot.Command(find, "random url", "random url  -- displays a random URL")
+	d.Command(find, "randurl", "randurl  -- displays a random URL")
+	d.Command(find, "random url", "random url  -- displays a random URL")
 
-	bot.Command(shorten, "shorten that", "shorten that  -- "+
+	d.Command(shorten, "shorten that", "shorten that  -- "+
 		"shortens the last mentioned URL.")
-	bot.Command(shorten, "shorten", "shorten <url>  -- shortens <url>")
+	d.Command(shorten, "shorten", "shorten <url>  -- shortens <url>")
 
-	bot.Command(cache, "cache that", "cache that  -- "+
+	d.Command(cache, "cache that", "cache that  -- "+
 		"caches the last mentioned URL.")
-	bot.Command(cache, "cache", "cache <url>  -- caches <url>")
-	bot.Command(cache, "save that", "save that  -- "+
+	d.Command(cache, "cache", "cache <url>  -- caches <url>")
+	d.Command(cache, "save that", "save that  -- "+
 		"caches the last mentioned URL.")
-	bot.Command(cache, "save", "save <url>  -- caches <url>")
+	d.Command(cache, "save", "save <url>  -- caches <url>")
 
 	// This serves "shortened" urls
 	http.Handle(shortenPath, http.StripPrefix(shortenPath,
@@ -0,0 +1,93 @@
+package urldriver
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "urldriver")
+	if err != nil {
+		panic(err)
+	}
+	flag.Set("url_cache_dir", dir)
+	code := bottest.Run(m, Init)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestShorten(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	b.Privmsg("alice", ch, "look at http://example.com/kittens")
+	b.ExpectNothing(t)
+	b.Privmsg("alice", ch, "sp0rkle: urlfind kitten")
+	b.Expect(t, ch, "alice: http://example.com/kittens")
+
+	short := regexp.QuoteMeta(bot.HttpHost()+shortenPath) + "(\\S{6})$"
+	b.Privmsg("alice", ch, "sp0rkle: shorten that")
+	line := b.Next()
+	if line == nil {
+		t.Fatalf("Bot didn't say anything.")
+	}
+	m := regexp.MustCompile("^alice: http://example.com/kittens shortened to " +
+		short).FindStringSubmatch(line.Text())
+	if m == nil {
+		t.Fatalf("Got %v, want the URL shortened.", line)
+	}
+	b.Privmsg("alice", ch, "sp0rkle: shorten that")
+	b.ExpectMatch(t, ch, "^alice: That was already shortened as "+short)
+
+	// The shortened URL redirects to the original.
+	rw := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rw, httptest.NewRequest("GET", shortenPath+m[1], nil))
+	if loc := rw.Header().Get("Location"); rw.Code != 302 || loc != "http://example.com/kittens" {
+		t.Errorf("Got %d to %q, want a redirect to the URL.", rw.Code, loc)
+	}
+
+	// Long URLs are shortened automatically in public.
+	long := "https://example.com/" + strings.Repeat("puppies/", 20)
+	b.Privmsg("bob", ch, "see "+long)
+	b.ExpectMatch(t, ch, "^bob's URL shortened as "+short)
+	b.Privmsg("bob", bottest.Nick, "see "+long+"#private")
+	b.ExpectNothing(t)
+}
+
+func TestCache(t *testing.T) {
+	b := bottest.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, "hello from the page")
+	}))
+	defer srv.Close()
+
+	// The last URL seen is per channel, so this doesn't cache TestShorten's.
+	ch := "#cache"
+	b.Privmsg("carol", ch, "sp0rkle: cache that")
+	b.Expect(t, ch, "carol: I seem to have forgotten what to cache")
+	b.Privmsg("carol", ch, "is "+srv.URL+"/page still up?")
+	b.ExpectNothing(t)
+	b.Privmsg("carol", ch, "sp0rkle: cache that")
+	line := b.Next()
+	if line == nil {
+		t.Fatalf("Bot didn't say anything.")
+	}
+	m := regexp.MustCompile("^carol: " + regexp.QuoteMeta(srv.URL) + "/page cached as " +
+		regexp.QuoteMeta(bot.HttpHost()+cachePath) + "(\\S{6})$").FindStringSubmatch(line.Text())
+	if m == nil {
+		t.Fatalf("Got %v, want the URL cached.", line)
+	}
+	rw := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rw, httptest.NewRequest("GET", cachePath+m[1], nil))
+	if body := rw.Body.String(); rw.Code != 200 || body != "hello from the page" {
+		t.Errorf("Got %d %q, want the cached page.", rw.Code, body)
+	}
+}
@@ -0,0 +1,142 @@
+package webhookdriver
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/webhooks"
+	"github.com/fluffle/sp0rkle/util/datetime"
+)
+
+// webhook add
+func add(ctx *bot.Context) {
+	u, err := url.Parse(ctx.Arg("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		ctx.ReplyN("'%s' doesn't look like an http(s) URL.", ctx.Arg("url"))
+		return
+	}
+	var c bot.Chan
+	var events []string
+	for _, f := range strings.Fields(ctx.Arg("#chan|event")) {
+		if strings.HasPrefix(f, "#") || strings.HasPrefix(f, "&") {
+			if c != "" {
+				ctx.ReplyN("Webhooks can only be for one channel.")
+				return
+			}
+			c = bot.Chan(strings.ToLower(f))
+			continue
+		}
+		name, ok := hookable[strings.ToLower(f)]
+		if !ok {
+			ctx.ReplyN("'%s' isn't an event webhooks can be sent. Try one of: %s.",
+				f, strings.Join(eventNames(), ", "))
+			return
+		}
+		events = append(events, name)
+	}
+	// Events from channels people aren't in could tell them things they
+	// shouldn't know, so only admins get to see those. Nor do they get to
+	// send webhooks to addresses that aren't public.
+	admin := ctx.Can(bot.Admin)
+	switch {
+	case admin:
+	case c == "":
+		ctx.ReplyN("You need to be %s to add webhooks for every channel, "+
+			"give a #chan too.", bot.Admin)
+		return
+	case !ctx.IsOn(string(c), ctx.Nick):
+		ctx.ReplyN("You need to be in %s to add webhooks for it.", c)
+		return
+	case !publicHost(u.Hostname()):
+		ctx.ReplyN("You need to be %s to add webhooks for %s.", bot.Admin, u.Hostname())
+		return
+	}
+	wh := webhooks.New(u.String(), ctx.Identity(), c, events)
+	wh.AnyHost = admin
+	if err := wc.Put(wh); err != nil {
+		ctx.ReplyN("Couldn't add webhook: %v", err)
+		return
+	}
+	ctx.ReplyN("Added webhook %s, sending %s to %s.", wh.Id, describe(wh), wh.Url)
+	ctx.Privmsg(ctx.Nick, fmt.Sprintf("Deliveries to webhook %s are signed "+
+		"with the secret %s, see the %s header.", wh.Id, wh.Secret, signatureHeader))
+}
+
+// webhook list
+func list(ctx *bot.Context) {
+	admin := ctx.Can(bot.Admin)
+	lines := []string{}
+	for _, wh := range wc.All() {
+		if !admin && !owns(ctx, wh) {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s to %s, added by %s", wh.Id, describe(wh), wh.Url, wh.Nick)
+		switch {
+		case wh.LastDelivery.IsZero():
+			line += ", nothing delivered yet."
+		case wh.LastError != "":
+			line += fmt.Sprintf(", last delivery at %s failed: %s",
+				datetime.Format(wh.LastDelivery), wh.LastError)
+		default:
+			line += fmt.Sprintf(", last delivered at %s.", datetime.Format(wh.LastDelivery))
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		ctx.ReplyN("No webhooks have been added.")
+		return
+	}
+	ctx.PageN(lines...)
+}
+
+// webhook del
+func del(ctx *bot.Context) {
+	wh := wc.ById(ctx.Arg("id"))
+	if wh == nil || (!ctx.Can(bot.Admin) && !owns(ctx, wh)) {
+		ctx.ReplyN("You don't have a webhook '%s'.", ctx.Arg("id"))
+		return
+	}
+	if err := wc.Forget(wh.Id); err != nil {
+		ctx.ReplyN("Couldn't delete webhook %s: %v", wh.Id, err)
+		return
+	}
+	ctx.ReplyN("Deleted webhook %s for %s.", wh.Id, wh.Url)
+}
+
+// publicHost returns false if host is obviously not public. Others are
+// checked by publicOnly when webhooks are sent.
+func publicHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPublic(ip)
+	}
+	return !strings.EqualFold(host, "localhost")
+}
+
+func owns(ctx *bot.Context, wh *webhooks.Webhook) bool {
+	return wh.OwnedBy(ctx.Identity())
+}
+
+// describe says which events wh is sent, e.g. "KarmaChanged events in #chan".
+func describe(wh *webhooks.Webhook) string {
+	s := "all events"
+	if len(wh.Events) > 0 {
+		s = strings.Join(wh.Events, ", ") + " events"
+	}
+	if wh.Chan != "" {
+		s += " in " + string(wh.Chan)
+	}
+	return s
+}
+
+func eventNames() []string {
+	names := make([]string, 0, len(hookable))
+	for _, name := range hookable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,202 @@
+package webhookdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/collections/webhooks"
+	"github.com/fluffle/sp0rkle/util/metrics"
+)
+
+// Headers sent with each delivery. The signature is the hex HMAC-SHA256 of
+// the body, keyed with the webhook's secret.
+const (
+	eventHeader     = "X-Sp0rkle-Event"
+	signatureHeader = "X-Sp0rkle-Signature"
+)
+
+var wc *webhooks.Collection
+
+// The events webhooks can be sent, keyed on their lowercased names.
+var hookable = map[string]string{}
+
+// hook makes events of type E something webhooks can be sent. Only these
+// are subscribed to, as some other events are published for every line.
+func hook[E bot.Event]() {
+	var ev E
+	name := bot.EventName(ev)
+	hookable[strings.ToLower(name)] = name
+	bot.Subscribe(func(ev E) { dispatch(name, ev) })
+}
+
+var (
+	// Failed deliveries are retried, waiting retryDelay before the first
+	// retry and twice as long before each one after that.
+	maxAttempts = 5
+	retryDelay  = 10 * time.Second
+
+	// Webhooks added by non-admins are sent with publicClient, so they
+	// can't be used to poke at things on the bot's own network.
+	client       = &http.Client{Timeout: 30 * time.Second}
+	publicClient = &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{
+		// No proxy, as that would be dialled instead of the webhook.
+		DialContext:         (&net.Dialer{Timeout: 30 * time.Second, Control: publicOnly}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}}
+
+	deliveries = metrics.NewCounter("sp0rkle_webhook_deliveries_total",
+		"Webhook deliveries, by result.", "result")
+)
+
+func Init() {
+	d := bot.NewDriver("webhook")
+	wc = webhooks.Init()
+
+	hook[bot.FactoidAdded]()
+	hook[bot.FactoidEdited]()
+	hook[bot.FactoidDeleted]()
+	hook[bot.QuoteAdded]()
+	hook[bot.KarmaChanged]()
+	hook[bot.ReminderFired]()
+	hook[bot.UrlSeen]()
+
+	d.Command(add, "webhook add", "Sends bot events in a channel you're in to "+
+		"url as JSON. Give event types to only send some of them. Admins can "+
+		"leave out the channel to get events from all of them, and send them "+
+		"to private addresses.",
+		bot.Args(bot.StringArg("url"), bot.Optional(bot.RestArg("#chan|event"))),
+		bot.Examples("webhook add https://example.com/hook #chan KarmaChanged"),
+		bot.Requires(bot.Trusted))
+	d.Command(list, "webhook list", "webhook list  -- "+
+		"Lists the webhooks you've added, or all of them for admins.",
+		bot.Requires(bot.Trusted))
+	d.Command(del, "webhook del", "Deletes a webhook.",
+		bot.Args(bot.StringArg("id")), bot.Requires(bot.Trusted))
+}
+
+// A payload is the JSON that is POSTed to webhooks.
+type payload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Text  string    `json:"text"`
+	Data  bot.Event `json:"data"`
+}
+
+// dispatch delivers ev, an event called name, to every webhook that
+// wants it.
+func dispatch(name string, ev bot.Event) {
+	var c bot.Chan
+	if f := reflect.ValueOf(ev).FieldByName("Chan"); f.IsValid() {
+		c, _ = f.Interface().(bot.Chan)
+	}
+	var body []byte
+	for _, wh := range wc.All() {
+		if !wh.Wants(name, c) {
+			continue
+		}
+		if body == nil {
+			var err error
+			body, err = json.Marshal(payload{
+				Event: name, Time: time.Now(), Text: ev.String(), Data: ev})
+			if err != nil {
+				logging.Error("Couldn't marshal %s: %v", name, err)
+				return
+			}
+		}
+		bot.Go(func() { deliver(wh, name, body) })
+	}
+}
+
+// deliver POSTs body to wh, retrying with backoff if that fails.
+func deliver(wh *webhooks.Webhook, event string, body []byte) {
+	delay := retryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		if retry, err = post(wh, event, body); err == nil || !retry || attempt == maxAttempts {
+			break
+		}
+		logging.Warn("Webhook %s delivery attempt %d failed, retrying in %s: %v",
+			wh.Id, attempt, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-bot.Ctx().Done():
+			return
+		}
+		delay *= 2
+	}
+	if err != nil {
+		logging.Error("Webhook %s delivery to %s failed: %v", wh.Id, wh.Url, err)
+		deliveries.Inc("failed")
+	} else {
+		deliveries.Inc("ok")
+	}
+	wc.Delivered(wh.Id, time.Now(), err)
+}
+
+// post makes one attempt at delivering body to wh. If it fails, it also
+// returns whether it's worth trying again.
+func post(wh *webhooks.Webhook, event string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(bot.Ctx(), "POST", wh.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sp0rkle")
+	req.Header.Set(eventHeader, event)
+	req.Header.Set(signatureHeader, "sha256="+wh.Sign(body))
+	c := publicClient
+	if wh.AnyHost {
+		c = client
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode/100 == 2 {
+		return false, nil
+	}
+	err = fmt.Errorf("got %s", res.Status)
+	// Other client errors mean the receiver doesn't want what we sent,
+	// and won't change its mind if we send it again.
+	switch {
+	case res.StatusCode == http.StatusRequestTimeout,
+		res.StatusCode == http.StatusTooManyRequests,
+		res.StatusCode >= 500:
+		return true, err
+	}
+	return false, err
+}
+
+// isPublic returns false for loopback, private and link-local addresses,
+// like 127.0.0.1, 10.1.2.3 and 169.254.169.254.
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// publicOnly stops publicClient connecting to addresses that aren't public.
+// It is checked after name resolution, so names can't be used to get around it.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%s isn't a public address", host)
+	}
+	return nil
+}
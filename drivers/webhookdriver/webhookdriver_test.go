@@ -0,0 +1,237 @@
+package webhookdriver
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluffle/sp0rkle/bot"
+	"github.com/fluffle/sp0rkle/bot/bottest"
+	"github.com/fluffle/sp0rkle/collections/webhooks"
+)
+
+func TestMain(m *testing.M) {
+	// root is the owner.
+	flag.Set("rebuilder", "root!*@*")
+	retryDelay = 10 * time.Millisecond
+	os.Exit(bottest.Run(m, Init))
+}
+
+type delivery struct {
+	event, signature string
+	body             []byte
+}
+
+// receiver records deliveries, failing the first fails of them.
+func receiver(fails int) (*httptest.Server, chan delivery) {
+	got := make(chan delivery, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		got <- delivery{req.Header.Get(eventHeader), req.Header.Get(signatureHeader), body}
+		if fails > 0 {
+			fails--
+			http.Error(rw, "not yet", http.StatusServiceUnavailable)
+		}
+	}))
+	return srv, got
+}
+
+func next(t *testing.T, got chan delivery) delivery {
+	t.Helper()
+	select {
+	case d := <-got:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("Nothing delivered.")
+	}
+	return delivery{}
+}
+
+func TestWebhooks(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	srv, got := receiver(2)
+	defer srv.Close()
+
+	// The bot has to find out who alice is first.
+	b.Privmsg("alice", ch, "sp0rkle: webhook add "+srv.URL)
+	b.Expect(t, ch, "alice: I'm not sure who you are yet, try again in a moment.")
+	b.ExpectWhois(t, "alice")
+	b.Privmsg("alice", ch, "sp0rkle: webhook add "+srv.URL)
+	b.Expect(t, ch, "alice: Sorry, you need to be trusted to do that.")
+	b.Privmsg("root", ch, "sp0rkle: webhook add ftp://example.com/")
+	b.Expect(t, ch, "root: 'ftp://example.com/' doesn't look like an http(s) URL.")
+	b.Privmsg("root", ch, "sp0rkle: webhook add "+srv.URL+" NickSeen")
+	b.ExpectMatch(t, ch, "^root: 'NickSeen' isn't an event webhooks can be sent. "+
+		"Try one of: FactoidAdded, .*, UrlSeen\\.$")
+	b.Privmsg("root", ch, "sp0rkle: webhook add "+srv.URL+" karmachanged #SP0RKLE")
+	b.ExpectMatch(t, ch, "^root: Added webhook \\w{6}, sending KarmaChanged "+
+		"events in #sp0rkle to "+regexp.QuoteMeta(srv.URL)+"\\.$")
+	b.ExpectMatch(t, "root", "^Deliveries to webhook \\w{6} are signed with the secret \\w{32}")
+
+	whs := wc.All()
+	if len(whs) != 1 {
+		t.Fatalf("Got %d webhooks, want 1.", len(whs))
+	}
+	wh := whs[0]
+
+	// Only KarmaChanged events in #sp0rkle are delivered.
+	bot.Publish(bot.FactoidAdded{Key: "cake", Value: "a lie", Nick: "alice", Chan: bot.Chan(ch)})
+	bot.Publish(bot.KarmaChanged{Subject: "cake", Score: 1, Nick: "alice", Chan: "#other"})
+	bot.Publish(bot.KarmaChanged{Subject: "cake", Score: -1, Nick: "alice", Chan: bot.Chan(ch)})
+
+	// The receiver fails the first two attempts, so there are three.
+	var d delivery
+	for i := 0; i < 3; i++ {
+		d = next(t, got)
+	}
+	if d.event != "KarmaChanged" || d.signature != "sha256="+wh.Sign(d.body) {
+		t.Errorf("Delivered %s signed %q, want KarmaChanged signed with the secret.",
+			d.event, d.signature)
+	}
+	var p struct {
+		Event, Text string
+		Data        bot.KarmaChanged
+	}
+	if err := json.Unmarshal(d.body, &p); err != nil {
+		t.Fatalf("Couldn't unmarshal %s: %v", d.body, err)
+	}
+	if p.Event != "KarmaChanged" || p.Data.Subject != "cake" || p.Data.Score != -1 ||
+		p.Text != "alice changed the karma of 'cake' to -1 in #sp0rkle" {
+		t.Errorf("Delivered %s", d.body)
+	}
+	select {
+	case d := <-got:
+		t.Errorf("Unexpectedly delivered %s", d.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	b.Privmsg("root", ch, "sp0rkle: webhook list")
+	b.ExpectMatch(t, ch, "^root: "+wh.Id+": KarmaChanged events in #sp0rkle to .*, "+
+		"added by root, last delivered at ")
+
+	b.Privmsg("alice", ch, "sp0rkle: webhook del "+wh.Id)
+	b.Expect(t, ch, "alice: Sorry, you need to be trusted to do that.")
+	b.Privmsg("root", ch, "sp0rkle: webhook del "+wh.Id)
+	b.Expect(t, ch, "root: Deleted webhook "+wh.Id+" for "+srv.URL+".")
+	b.Privmsg("root", ch, "sp0rkle: webhook list")
+	b.Expect(t, ch, "root: No webhooks have been added.")
+}
+
+func TestOwners(t *testing.T) {
+	b := bottest.New(t)
+	ch := bottest.Chan
+	// Nothing is sent to it, but it has to look public.
+	url := "http://192.0.2.1/hook"
+	b.Privmsg("root", ch, "sp0rkle: grant *!carol@* trusted")
+	b.Expect(t, ch, "root: *!carol@* is now trusted.")
+	b.Privmsg("root", ch, "sp0rkle: grant *!dave@* trusted")
+	b.Expect(t, ch, "root: *!dave@* is now trusted.")
+
+	// Webhooks for every channel could see private reminders.
+	b.Privmsg("carol", ch, "sp0rkle: webhook add "+url)
+	b.Expect(t, ch, "carol: You need to be admin to add webhooks for every channel, give a #chan too.")
+	// As could webhooks for channels carol isn't in.
+	b.Privmsg("carol", ch, "sp0rkle: webhook add "+url+" "+ch)
+	b.Expect(t, ch, "carol: You need to be in "+ch+" to add webhooks for it.")
+	b.Join("carol", ch)
+	defer b.Part("carol", ch)
+	// And only admins can send them to the bot's own network.
+	for _, host := range []string{"127.0.0.1", "localhost", "169.254.169.254", "10.1.2.3", "[::1]"} {
+		b.Privmsg("carol", ch, "sp0rkle: webhook add http://"+host+"/hook "+ch)
+		b.Expect(t, ch, "carol: You need to be admin to add webhooks for "+
+			strings.Trim(host, "[]")+".")
+	}
+	b.Privmsg("carol", ch, "sp0rkle: webhook add "+url+" "+ch)
+	b.ExpectMatch(t, ch, "^carol: Added webhook ")
+	b.ExpectMatch(t, "carol", "^Deliveries to webhook ")
+	whs := wc.All()
+	if len(whs) != 1 {
+		t.Fatalf("Got %d webhooks, want 1.", len(whs))
+	}
+	id := whs[0].Id
+	if whs[0].AnyHost {
+		t.Errorf("carol's webhook can be sent anywhere.")
+	}
+
+	// dave is trusted too, but using carol's nick doesn't make the
+	// webhook theirs.
+	b.Raw(":carol!dave@example.com PRIVMSG " + ch + " :sp0rkle: webhook del " + id)
+	b.Expect(t, ch, "carol: You don't have a webhook '"+id+"'.")
+	b.Privmsg("carol", ch, "sp0rkle: webhook del "+id)
+	b.Expect(t, ch, "carol: Deleted webhook "+id+" for "+url+".")
+}
+
+func TestPublicOnly(t *testing.T) {
+	srv, got := receiver(0)
+	defer srv.Close()
+	// Names that resolve to the bot's own network are caught when sent.
+	wh := webhooks.New(strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+		&bot.Identity{Nick: "carol"}, bottest.Chan, nil)
+	if _, err := post(wh, "QuoteAdded", []byte("{}")); err == nil ||
+		!strings.Contains(err.Error(), "isn't a public address") {
+		t.Errorf("post to %s got err %v", wh.Url, err)
+	}
+	wh.AnyHost = true
+	if _, err := post(wh, "QuoteAdded", []byte("{}")); err != nil {
+		t.Errorf("post to %s got err %v", wh.Url, err)
+	}
+	next(t, got)
+}
+
+func TestPrivate(t *testing.T) {
+	b := bottest.New(t)
+	srv, got := receiver(0)
+	defer srv.Close()
+	defer func() {
+		for _, wh := range wc.All() {
+			wc.Forget(wh.Id)
+		}
+	}()
+	b.Privmsg("root", bottest.Chan, "sp0rkle: webhook add "+srv.URL+" ReminderFired")
+	b.ExpectMatch(t, bottest.Chan, "^root: Added webhook ")
+	b.ExpectMatch(t, "root", "^Deliveries to webhook ")
+
+	// Reminders set in private aren't delivered, even to admins.
+	bot.Publish(bot.ReminderFired{Source: "alice", Target: "alice", Chan: "alice", Text: "secret"})
+	bot.Publish(bot.ReminderFired{Source: "alice", Target: "bob", Chan: bottest.Chan, Text: "cake"})
+	if d := next(t, got); !strings.Contains(string(d.body), "cake") {
+		t.Errorf("Delivered %s", d.body)
+	}
+	select {
+	case d := <-got:
+		t.Errorf("Unexpectedly delivered %s", d.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	b := bottest.New(t)
+	srv, got := receiver(maxAttempts)
+	defer srv.Close()
+	defer func() {
+		for _, wh := range wc.All() {
+			wc.Forget(wh.Id)
+		}
+	}()
+	b.Privmsg("root", bottest.Chan, "sp0rkle: webhook add "+srv.URL+" QuoteAdded")
+	b.ExpectMatch(t, bottest.Chan, "^root: Added webhook ")
+	b.ExpectMatch(t, "root", "^Deliveries to webhook ")
+
+	bot.Publish(bot.QuoteAdded{QID: 1, Quote: "<alice> hi", Nick: "alice", Chan: bottest.Chan})
+	for i := 0; i < maxAttempts; i++ {
+		next(t, got)
+	}
+	// The failure is recorded after the last attempt.
+	time.Sleep(100 * time.Millisecond)
+	b.Privmsg("root", bottest.Chan, "sp0rkle: webhook list")
+	b.ExpectMatch(t, bottest.Chan, "^root: \\w{6}: QuoteAdded events to .*, last delivery "+
+		"at .* failed: got 503 Service Unavailable$")
+}
@@ -1,6 +1,7 @@
 module github.com/fluffle/sp0rkle
 
 require (
+	github.com/emersion/go-sasl v0.0.0-20220912192320-0145f2c60ead
 	github.com/fluffle/goirc v1.3.4
 	github.com/fluffle/golog v1.0.2
 	github.com/google/go-github v17.0.0+incompatible
@@ -11,7 +12,6 @@ require (
 )
 
 require (
-	github.com/emersion/go-sasl v0.0.0-20220912192320-0145f2c60ead // indirect
 	github.com/golang/mock v1.5.0 // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/go-querystring v1.0.0 // indirect
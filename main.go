@@ -30,6 +30,7 @@ import (
 	"github.com/fluffle/sp0rkle/drivers/seendriver"
 	"github.com/fluffle/sp0rkle/drivers/statsdriver"
 	"github.com/fluffle/sp0rkle/drivers/urldriver"
+	"github.com/fluffle/sp0rkle/drivers/webhookdriver"
 	"github.com/fluffle/sp0rkle/util/datetime"
 )
 
@@ -39,6 +40,7 @@ var (
 	backupDir   = flag.String("backup_dir", "backup", "Where to write BoltDB backups to.")
 	backupEvery = flag.Duration("backup_every", 24 * time.Hour, "How often to write backups.")
 	timezone    = flag.String("timezone", "Europe/London", "Default timezone for date/time.")
+	stopTimeout = flag.Duration("shutdown_timeout", 10*time.Second, "How long to wait for things to finish when shutting down.")
 )
 
 func main() {
@@ -52,8 +54,9 @@ func main() {
 	// Slightly more random than 1.
 	rand.Seed(time.Now().UnixNano() * int64(os.Getpid()))
 
-	// Initialise bot state
-	ctx := context.Background()
+	// Initialise bot state. The context is cancelled when shutting down.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	bot.Init(ctx)
 
 	// Connect to database
@@ -74,30 +77,60 @@ func main() {
 	seendriver.Init()
 	statsdriver.Init()
 	urldriver.Init()
+	webhookdriver.Init()
 
 	// Start up the HTTP server
-	go http.ListenAndServe(*httpPort, nil)
+	srv := &http.Server{Addr: *httpPort}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logging.Error("HTTP server failed: %v", err)
+		}
+	}()
 
 	// Set up a signal handler to shut things down gracefully.
-	// NOTE: net/http doesn't provide for graceful shutdown :-/
 	go func() {
 		called := new(int32)
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, syscall.SIGINT)
-		for _ = range sigint {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		for sig := range sigs {
 			if atomic.AddInt32(called, 1) > 1 {
-				logging.Fatal("Recieved multiple interrupts, dying.")
+				logging.Fatal("Recieved multiple signals, dying.")
 			}
+			logging.Info("Received %s, shutting down.", sig)
+			cancel()
 			bot.Shutdown()
 		}
 	}()
 
+	// Reload the config file on SIGHUP.
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for _ = range sighup {
+			if _, err := bot.Reload(); err != nil {
+				logging.Error("Couldn't reload config: %v", err)
+			}
+		}
+	}()
+
 	// Connect the bot to IRC and wait; reconnects are handled automatically.
 	// If we get true back from the bot, re-exec the (rebuilt) binary.
-	if <-bot.Connect() {
-		// Calling syscall.Exec probably means deferred functions won't get
-		// called, so disconnect from DBs first for politeness' sake.
-		db.Bolt.Close()
+	rebuild := <-bot.Connect()
+
+	// Either way, we're disconnected now, so stop everything else.
+	cancel()
+	stopCtx, stopped := context.WithTimeout(context.Background(), *stopTimeout)
+	defer stopped()
+	if err := srv.Shutdown(stopCtx); err != nil {
+		logging.Warn("HTTP server didn't shut down cleanly: %v", err)
+	}
+	if !bot.Wait(stopCtx) {
+		logging.Warn("Timed out waiting for handlers to finish.")
+	}
+	// This takes a final backup.
+	db.Bolt.Close()
+
+	if rebuild {
 		// If sp0rkle was run from PATH, we need to do that lookup manually.
 		fq, _ := exec.LookPath(os.Args[0])
 		logging.Warn("Re-executing sp0rkle with args '%v'.", os.Args)
@@ -0,0 +1,267 @@
+// Package metrics keeps counters, gauges and histograms, and serves them
+// in Prometheus' text format so the bot can be monitored and alerted on.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluffle/golog/logging"
+)
+
+// DefBuckets are histogram buckets, in seconds, suitable for timing how
+// long it takes to handle a line.
+var DefBuckets = []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	regLock  sync.Mutex
+	registry = map[string]metric{}
+)
+
+func register(name string, m metric) {
+	regLock.Lock()
+	defer regLock.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	registry[name] = m
+}
+
+// A series is the value of a metric for one set of label values.
+type series struct {
+	labels []string
+	value  float64
+	// Only used by histograms: the count in each bucket, and the sum of
+	// observed values. The count of all observations is in value.
+	buckets []uint64
+	sum     float64
+}
+
+// family is a metric and its series, keyed on their label values.
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newFamily(name, help, kind string, labels []string) *family {
+	return &family{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: map[string]*series{},
+	}
+}
+
+// get returns the series for values, which f.mu must be held for.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v",
+			f.name, f.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: values, buckets: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) write(w io.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range sortedKeys(f.series) {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			writeSample(w, f.name, f.labels, s.labels, s.value)
+			continue
+		}
+		labels := append(f.labels[:len(f.labels):len(f.labels)], "le")
+		for i, le := range f.buckets {
+			writeSample(w, f.name+"_bucket", labels,
+				append(s.labels[:len(s.labels):len(s.labels)], formatFloat(le)),
+				float64(s.buckets[i]))
+		}
+		writeSample(w, f.name+"_bucket", labels,
+			append(s.labels[:len(s.labels):len(s.labels)], "+Inf"), s.value)
+		writeSample(w, f.name+"_sum", f.labels, s.labels, s.sum)
+		writeSample(w, f.name+"_count", f.labels, s.labels, s.value)
+	}
+}
+
+// A Counter counts things that only ever go up, like lines handled.
+type Counter struct {
+	f *family
+}
+
+// NewCounter registers a counter, which is counted separately for each
+// combination of values of the given labels.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newFamily(name, help, "counter", labels)}
+	register(name, c.f)
+	return c
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(v float64, values ...string) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(values).value += v
+}
+
+// A Gauge is a value that can go up and down, like whether the bot is
+// connected to a network.
+type Gauge struct {
+	f *family
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newFamily(name, help, "gauge", labels)}
+	register(name, g.f)
+	return g
+}
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(values).value = v
+}
+
+// A Histogram counts observations, like how long things take, in buckets.
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram registers a histogram with the given upper bounds for its
+// buckets, in ascending order. DefBuckets are a good choice for timings.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{newFamily(name, help, "histogram", labels)}
+	h.f.buckets = buckets
+	register(name, h.f)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(values)
+	for i, le := range h.f.buckets {
+		if v <= le {
+			s.buckets[i]++
+		}
+	}
+	s.value++
+	s.sum += v
+}
+
+// Since observes the number of seconds since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// funcMetric is a metric whose value is only known when it is served.
+type funcMetric struct {
+	name, help, kind string
+	fn               func() float64
+}
+
+func (m funcMetric) write(w io.Writer) {
+	writeHeader(w, m.name, m.help, m.kind)
+	writeSample(w, m.name, nil, nil, m.fn())
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, funcMetric{name, help, "gauge", fn})
+}
+
+// NewCounterFunc registers a counter whose value is returned by fn.
+func NewCounterFunc(name, help string, fn func() float64) {
+	register(name, funcMetric{name, help, "counter", fn})
+}
+
+// Write writes every registered metric to w, in name order.
+func Write(w io.Writer) {
+	regLock.Lock()
+	names := sortedKeys(registry)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = registry[name]
+	}
+	regLock.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// ServeHTTP serves every registered metric for Prometheus to scrape.
+func ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w := bufio.NewWriter(rw)
+	Write(w)
+	if err := w.Flush(); err != nil {
+		logging.Warn("Couldn't write metrics: %v", err)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+		name, helpEscaper.Replace(help), name, kind)
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, v float64) {
+	pairs := make([]string, len(labels))
+	for i := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(values[i]))
+	}
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func written(m metric) string {
+	b := &bytes.Buffer{}
+	m.write(b)
+	return b.String()
+}
+
+// unregister forgets metrics when the test finishes, so that it can be
+// run again with -count.
+func unregister(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		regLock.Lock()
+		defer regLock.Unlock()
+		for _, name := range names {
+			delete(registry, name)
+		}
+	})
+}
+
+func TestCounter(t *testing.T) {
+	unregister(t, "test_lines_total")
+	c := NewCounter("test_lines_total", "Lines.\nWith a \\.", "network", "cmd")
+	c.Inc("b", "privmsg")
+	c.Add(2, "a", `say "hi"`)
+	c.Inc("b", "privmsg")
+	exp := `# HELP test_lines_total Lines.\nWith a \\.
+# TYPE test_lines_total counter
+test_lines_total{network="a",cmd="say \"hi\""} 2
+test_lines_total{network="b",cmd="privmsg"} 2
+`
+	if got := written(c.f); got != exp {
+		t.Errorf("Counter written as:\n%s\nexpected:\n%s", got, exp)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Wrong number of label values didn't panic.")
+		}
+	}()
+	c.Inc("a")
+}
+
+func TestGauge(t *testing.T) {
+	unregister(t, "test_connected")
+	g := NewGauge("test_connected", "Connected.")
+	g.Set(1)
+	g.Set(0)
+	exp := "# HELP test_connected Connected.\n# TYPE test_connected gauge\n" +
+		"test_connected 0\n"
+	if got := written(g.f); got != exp {
+		t.Errorf("Gauge written as:\n%s\nexpected:\n%s", got, exp)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	unregister(t, "test_seconds")
+	h := NewHistogram("test_seconds", "Timing.", []float64{0.1, 1}, "name")
+	for _, v := range []float64{0.05, 0.5, 0.1, 5} {
+		h.Observe(v, "x")
+	}
+	exp := `# HELP test_seconds Timing.
+# TYPE test_seconds histogram
+test_seconds_bucket{name="x",le="0.1"} 2
+test_seconds_bucket{name="x",le="1"} 3
+test_seconds_bucket{name="x",le="+Inf"} 4
+test_seconds_sum{name="x"} 5.65
+test_seconds_count{name="x"} 4
+`
+	if got := written(h.f); got != exp {
+		t.Errorf("Histogram written as:\n%s\nexpected:\n%s", got, exp)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	unregister(t, "test_func_a", "test_func_b")
+	NewGaugeFunc("test_func_b", "B.", func() float64 { return 1.5 })
+	NewCounterFunc("test_func_a", "A.", func() float64 { return 3 })
+
+	rec := httptest.NewRecorder()
+	ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	a := strings.Index(body, "# TYPE test_func_a counter\ntest_func_a 3\n")
+	b := strings.Index(body, "# TYPE test_func_b gauge\ntest_func_b 1.5\n")
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("Metrics missing or out of order:\n%s", body)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a metric twice didn't panic.")
+		}
+	}()
+	NewGauge("test_func_a", "Again.")
+}
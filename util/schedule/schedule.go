@@ -0,0 +1,243 @@
+// Package schedule parses specs for when recurring jobs should run. Specs
+// are either an interval, like "every 10m", or a five-field cron line,
+// like "30 9 * * mon-fri". Either can end with "~<duration>" to add up
+// to that much random jitter to each run, so that jobs scheduled for the
+// same time don't all run at once.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule says when a job should next run.
+type Schedule interface {
+	// Next returns the time after t that the job should next run.
+	Next(t time.Time) time.Time
+	// String returns the spec the schedule was parsed from, normalised.
+	String() string
+}
+
+// Shorthands for common cron lines.
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse returns the Schedule described by spec.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.ToLower(strings.Join(strings.Fields(spec), " "))
+	var jitter time.Duration
+	if i := strings.LastIndex(spec, "~"); i >= 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[i+1:]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("bad jitter %q", spec[i:])
+		}
+		spec, jitter = strings.TrimSpace(spec[:i]), d
+	}
+	s, err := parse(spec)
+	if err != nil || jitter == 0 {
+		return s, err
+	}
+	return jittered{s, jitter}, nil
+}
+
+func parse(spec string) (Schedule, error) {
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		spec = "every " + rest
+	}
+	if rest, ok := strings.CutPrefix(spec, "every "); ok {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("bad interval %q, need e.g. 30s or 1h", rest)
+		}
+		return interval(d), nil
+	}
+	name := spec
+	if line, ok := shorthands[spec]; ok {
+		spec = line
+	}
+	c, err := parseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	c.spec = name
+	return c, nil
+}
+
+// interval schedules a job to run a fixed time after it last ran.
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+func (i interval) String() string {
+	return "every " + time.Duration(i).String()
+}
+
+// jittered delays a schedule's runs by up to jitter.
+type jittered struct {
+	Schedule
+	jitter time.Duration
+}
+
+func (j jittered) Next(t time.Time) time.Time {
+	return j.Schedule.Next(t).Add(time.Duration(rand.Int63n(int64(j.jitter))))
+}
+
+func (j jittered) String() string {
+	return j.Schedule.String() + " ~" + j.jitter.String()
+}
+
+// cron schedules a job to run at minutes matching all of its fields.
+type cron struct {
+	spec                     string
+	minute, hour, dom, month uint64
+	dow                      uint64
+	anyDom, anyDow           bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "jan", "feb", "mar",
+		"apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// Both 0 and 7 are Sunday.
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon",
+		"tue", "wed", "thu", "fri", "sat"}},
+}
+
+func parseCron(spec string) (*cron, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, errors.New("need an interval like 'every 1h', " +
+			"or a cron line like '*/5 * * * *'")
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		var err error
+		if bits[i], err = f.parse(parts[i]); err != nil {
+			return nil, err
+		}
+	}
+	c := &cron{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3],
+		dow:    bits[4],
+		anyDom: parts[2] == "*", anyDow: parts[4] == "*",
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%q never happens", spec)
+	}
+	return c, nil
+}
+
+// parse returns a bitmask of the values of f that s matches. s is a comma
+// separated list of *, values, or ranges, each optionally with a /step.
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step %q in %s", stepStr, f.name)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("bad range %q in %s", rng, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && s == name {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("bad %s %q, must be %d-%d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Cron lines that can never match, like "0 0 31 2 *", give up after this.
+const maxYears = 5
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(maxYears, 0, 0)
+	for t.Before(end) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron's rule that if both the day of the month and
+// the day of the week are restricted, either one matching is enough.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec, str string
+		ok        bool
+	}{
+		{"every 10m", "every 10m0s", true},
+		{"@every   1h30m", "every 1h30m0s", true},
+		{"every 10ms", "", false},
+		{"every fortnight", "", false},
+		{"*/5 * * * *", "*/5 * * * *", true},
+		{"30  9 * *  MON-FRI", "30 9 * * mon-fri", true},
+		{"0 0 1,15 jan-jun/2 *", "0 0 1,15 jan-jun/2 *", true},
+		{"@Daily", "@daily", true},
+		{"@daily ~5m", "@daily ~5m0s", true},
+		{"every 1h ~30s", "every 1h0m0s ~30s", true},
+		{"every 1h ~", "", false},
+		{"0 0 30 2 *", "", false},
+		{"60 * * * *", "", false},
+		{"* * * *", "", false},
+		{"5-1 * * * *", "", false},
+		{"*/0 * * * *", "", false},
+		{"@fortnightly", "", false},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		if (err == nil) != test.ok {
+			t.Errorf("Parse(%q) returned error %v.", test.spec, err)
+			continue
+		}
+		if err == nil && s.String() != test.str {
+			t.Errorf("Parse(%q).String() = %q, want %q.", test.spec, s, test.str)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2024, 2, 28, 9, 31, 15, 0, time.UTC)
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"every 90s", from.Add(90 * time.Second)},
+		{"* * * * *", time.Date(2024, 2, 28, 9, 32, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 2, 28, 9, 45, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC)},
+		{"0 12 * * sun", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Either the day of the month or the day of the week will do.
+		{"0 0 1 * fri", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 10 * mon", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error %v.", test.spec, err)
+		}
+		if next := s.Next(from); !next.Equal(test.next) {
+			t.Errorf("%q.Next(%s) = %s, want %s.", test.spec, from, next, test.next)
+		}
+	}
+
+	s, _ := Parse("0 * * * * ~10m")
+	want := time.Date(2024, 2, 28, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		if next := s.Next(from); next.Before(want) || !next.Before(want.Add(10*time.Minute)) {
+			t.Errorf("Jittered next = %s, want within 10m of %s.", next, want)
+		}
+	}
+}
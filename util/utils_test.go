@@ -207,3 +207,31 @@ func TestFactPointer(t *testing.T) {
 		}
 	}
 }
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"remind", "remind", 0},
+		{"remind", "", 6},
+		{"", "list", 4},
+		{"remnid", "remind", 1},
+		{"remind", "reminds", 1},
+		{"remind", "remin", 1},
+		{"remind", "rewind", 1},
+		{"karma", "kamra", 1},
+		{"hello", "help", 2},
+		{"ça va", "ca va", 1},
+		{"abc", "cba", 2},
+	}
+	for _, test := range tests {
+		if d := EditDistance(test.a, test.b); d != test.d {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", test.a, test.b, d, test.d)
+		}
+		if d := EditDistance(test.b, test.a); d != test.d {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", test.b, test.a, d, test.d)
+		}
+	}
+}